@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+)
+
+// Handles the request to create an API key for the logged in user
+//
+// CreateAPIKey godoc
+//
+//	@Summary		Create an API key
+//	@Description	Creates a long-lived key to send as "X-API-Key" from scripts and bots. The key is only returned this once.
+//	@Tags			api-keys
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.APIKeyRequest	true	"Key name and scopes"
+//	@Success		201		{object}	models.CreatedAPIKeyResponse
+//	@Failure		400		{object}	models.Problem
+//	@Failure		403		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Router			/api-keys [post]
+func (g *Gateway) CreateAPIKeyHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var requestSerializer models.APIKeyRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&requestSerializer); err != nil {
+		return writeBadRequest(w, req, "Invalid request payload", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.apiKeyClient.CreateAPIKey(ctx, &api.CreateAPIKeyRequest{
+		Name:   requestSerializer.Name,
+		Scopes: requestSerializer.Scopes,
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to create api key", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return bunrouter.JSON(w, models.CreatedAPIKeyResponse{
+		Key:    resp.Key,
+		APIKey: serializeAPIKey(resp.ApiKey),
+	})
+}
+
+// Handles the request to list the logged in user's API keys
+//
+// ListAPIKeys godoc
+//
+//	@Summary		List API keys
+//	@Description	Fetches the logged in user's API keys, newest first, revoked ones included
+//	@Tags			api-keys
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		models.APIKeyResponse
+//	@Failure		403	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Router			/api-keys [get]
+func (g *Gateway) ListAPIKeysHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.apiKeyClient.ListAPIKeys(ctx, &api.ListAPIKeysRequest{})
+	if err != nil {
+		return writeError(w, req, "Failed to list api keys", err)
+	}
+
+	keys := make([]models.APIKeyResponse, 0, len(resp.ApiKeys))
+	for _, key := range resp.ApiKeys {
+		keys = append(keys, serializeAPIKey(key))
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"api_keys": keys})
+}
+
+// Handles the request to revoke an API key, which stops working right away
+//
+// RevokeAPIKey godoc
+//
+//	@Summary		Revoke an API key
+//	@Description	Revokes one of the logged in user's API keys
+//	@Tags			api-keys
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"API key ID"
+//	@Success		200	{object}	models.APIKeyResponse
+//	@Failure		403	{object}	models.Problem
+//	@Failure		404	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Router			/api-keys/{id} [delete]
+func (g *Gateway) RevokeAPIKeyHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.apiKeyClient.RevokeAPIKey(ctx, &api.RevokeAPIKeyRequest{Id: req.Param("id")})
+	if err != nil {
+		return writeError(w, req, "Failed to revoke api key", err)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"api_key": serializeAPIKey(resp.ApiKey)})
+}
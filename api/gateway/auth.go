@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/auth"
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/uptrace/bunrouter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// the issuer we stamp our tokens with, and expect back
+const tokenIssuer = "notes-tracker"
+
+// How long issued tokens stay valid unless JWT_TTL says otherwise
+const defaultTokenTTL = 24 * time.Hour
+
+var errMissingCredentials = errors.New(`missing "Authorization: Bearer <token>" or "X-API-Key" header`)
+
+// Signs and verifies the HS256 JWTs we hand out on login
+type tokenSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// Issues a token for the user, returning it along with its expiry
+func (s tokenSigner) issue(userID string, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(s.ttl)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    tokenIssuer,
+		Subject:   userID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+
+	signed, err := token.SignedString(s.secret)
+	return signed, expiresAt, err
+}
+
+// Checks the token's signature and expiry, returning the user it was issued to
+func (s tokenSigner) verify(raw string) (string, error) {
+	claims := new(jwt.RegisteredClaims)
+
+	_, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return "", err
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("token has no subject")
+	}
+	return claims.Subject, nil
+}
+
+// what the credentials a request came with allow it to do
+type grant struct {
+	scopes []string
+	// set for API keys, which may not manage other API keys
+	viaAPIKey bool
+}
+
+type grantKey struct{}
+
+func grantFrom(ctx context.Context) grant {
+	g, _ := ctx.Value(grantKey{}).(grant)
+	return g
+}
+
+// Middleware rejecting requests without valid credentials, either an
+// X-API-Key header or a bearer token from /auth/login. The user behind
+// them is stored on the request's context, along with what they may do.
+func (g *Gateway) authenticate(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		if key := req.Header.Get("X-API-Key"); key != "" {
+			return g.authenticateAPIKey(w, req, key, next)
+		}
+
+		raw, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !found || raw == "" {
+			return writeUnauthorized(w, req, errMissingCredentials)
+		}
+
+		userID, err := g.tokens.verify(raw)
+		if err != nil {
+			return writeUnauthorized(w, req, err)
+		}
+
+		// logged in users may do anything with their own tasks
+		ctx := auth.WithUserID(req.Context(), userID)
+		ctx = context.WithValue(ctx, grantKey{}, grant{scopes: models.AllScopes})
+		return next(w, req.WithContext(ctx))
+	}
+}
+
+func (g *Gateway) authenticateAPIKey(w http.ResponseWriter, req bunrouter.Request, key string, next bunrouter.HandlerFunc) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.userClient.AuthenticateAPIKey(ctx, &api.AuthenticateAPIKeyRequest{Key: key})
+	if status.Code(err) == codes.Unauthenticated {
+		return writeUnauthorized(w, req, errors.New("invalid or revoked api key"))
+	}
+	if err != nil {
+		return writeError(w, req, "Failed to authenticate api key", err)
+	}
+
+	authenticated := auth.WithUserID(req.Context(), resp.User.Id)
+	authenticated = context.WithValue(authenticated, grantKey{}, grant{scopes: resp.Scopes, viaAPIKey: true})
+	return next(w, req.WithContext(authenticated))
+}
+
+// Middleware checking the caller's credentials carry the scope the
+// request needs: tasks:read to look, tasks:write to change anything
+func requireScope(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		scope := models.ScopeTasksWrite
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			scope = models.ScopeTasksRead
+		}
+
+		if !slices.Contains(grantFrom(req.Context()).scopes, scope) {
+			return writeForbidden(w, req, fmt.Errorf("these credentials lack the %q scope", scope))
+		}
+		return next(w, req)
+	}
+}
+
+// Middleware only letting logged in users through, not API keys
+func requireSession(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		if grantFrom(req.Context()).viaAPIKey {
+			return writeForbidden(w, req, errors.New("api keys cannot be used here, log in instead"))
+		}
+		return next(w, req)
+	}
+}
+
+func writeForbidden(w http.ResponseWriter, req bunrouter.Request, err error) error {
+	return writeProblem(w, req, models.Problem{
+		Title:  "Permission denied",
+		Status: http.StatusForbidden,
+		Detail: err.Error(),
+		Code:   "PERMISSION_DENIED",
+	})
+}
+
+func writeUnauthorized(w http.ResponseWriter, req bunrouter.Request, err error) error {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="notes-tracker"`)
+	return writeProblem(w, req, models.Problem{
+		Title:  "Authentication required",
+		Status: http.StatusUnauthorized,
+		Detail: err.Error(),
+		Code:   "UNAUTHENTICATED",
+	})
+}
+
+// Forwards the authenticated user on the context to our gRPC service as
+// metadata, along with the token that tells the service the call is ours
+func forwardIdentity(gatewayToken string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withIdentity(ctx, gatewayToken), method, req, reply, cc, opts...)
+	}
+}
+
+// The forwardIdentity of streaming calls, i.e. WatchTasks
+func forwardIdentityStream(gatewayToken string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withIdentity(ctx, gatewayToken), desc, cc, method, opts...)
+	}
+}
+
+func withIdentity(ctx context.Context, gatewayToken string) context.Context {
+	ctx = metadata.AppendToOutgoingContext(ctx, auth.GatewayTokenMetadataKey, gatewayToken)
+	if userID, ok := auth.UserID(ctx); ok {
+		ctx = metadata.AppendToOutgoingContext(ctx, auth.UserIDMetadataKey, userID)
+	}
+	return ctx
+}
+
+// Handles the request to sign a new user up
+//
+// Register godoc
+//
+//	@Summary		Register a user
+//	@Description	Signs a new user up with an email and a password of at least 8 characters
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.CredentialsRequest	true	"Email and password"
+//	@Success		201		{object}	models.UserResponse
+//	@Failure		400		{object}	models.Problem
+//	@Failure		409		{object}	models.Problem
+//	@Router			/auth/register [post]
+func (g *Gateway) RegisterHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var credentials models.CredentialsRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&credentials); err != nil {
+		return writeBadRequest(w, req, "Invalid request payload", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.userClient.RegisterUser(ctx, &api.RegisterUserRequest{
+		Email:    credentials.Email,
+		Password: credentials.Password,
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to register user", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return bunrouter.JSON(w, bunrouter.H{
+		"message": "User registered successfully",
+		"data":    serializeUser(resp.User),
+	})
+}
+
+// Handles the request to log in, issuing a bearer token for the /api/v1 routes
+//
+// Login godoc
+//
+//	@Summary		Log in
+//	@Description	Exchanges an email and password for a signed JWT to send as "Authorization: Bearer <token>"
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.CredentialsRequest	true	"Email and password"
+//	@Success		200		{object}	models.TokenResponse
+//	@Failure		401		{object}	models.Problem
+//	@Router			/auth/login [post]
+func (g *Gateway) LoginHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var credentials models.CredentialsRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&credentials); err != nil {
+		return writeBadRequest(w, req, "Invalid request payload", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.userClient.Authenticate(ctx, &api.AuthenticateRequest{
+		Email:    credentials.Email,
+		Password: credentials.Password,
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to log in", err)
+	}
+
+	token, expiresAt, err := g.tokens.issue(resp.User.Id, time.Now())
+	if err != nil {
+		return writeError(w, req, "Failed to log in", err)
+	}
+
+	return bunrouter.JSON(w, models.TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresAt:   expiresAt.UTC().Format(time.RFC3339),
+		User:        serializeUser(resp.User),
+	})
+}
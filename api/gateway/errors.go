@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const problemContentType = "application/problem+json"
+
+// nginx's status for a request the client gave up on before it was answered
+const statusClientClosedRequest = 499
+
+// how each gRPC status code surfaces to our HTTP clients
+var httpStatusByCode = map[codes.Code]int{
+	codes.OK:                 http.StatusOK,
+	codes.Canceled:           statusClientClosedRequest,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.OutOfRange:         http.StatusBadRequest,
+	codes.NotFound:           http.StatusNotFound,
+	codes.AlreadyExists:      http.StatusConflict,
+	codes.Aborted:            http.StatusConflict,
+	codes.FailedPrecondition: http.StatusConflict,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.Unimplemented:      http.StatusNotImplemented,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+	codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+}
+
+// Translates an error coming back from the internal service into the
+// matching HTTP status and writes it out as a problem+json body.
+//
+// Anything that is not a gRPC status error is treated as an internal error.
+func writeError(w http.ResponseWriter, req bunrouter.Request, title string, err error) error {
+	return writeProblem(w, req, toProblem(title, err))
+}
+
+// The problem writeError answers with, for when a response is already
+// underway, i.e. on an event stream
+func toProblem(title string, err error) models.Problem {
+	st := status.Convert(err)
+
+	httpStatus, ok := httpStatusByCode[st.Code()]
+	if !ok {
+		httpStatus = http.StatusInternalServerError
+	}
+
+	return models.Problem{
+		Title:  title,
+		Status: httpStatus,
+		Detail: st.Message(),
+		Code:   codeName(st.Code()),
+	}
+}
+
+// Like writeError, except that a write aborted because the task changed
+// since the caller read it is answered with 412 Precondition Failed and
+// the task's current ETag, so the caller can fetch it and try again
+func (g *Gateway) writeTaskError(ctx context.Context, w http.ResponseWriter, req bunrouter.Request, title string, err error) error {
+	if status.Code(err) != codes.Aborted {
+		return writeError(w, req, title, err)
+	}
+
+	current, getErr := g.grpcClient.GetTask(ctx, &api.GetTaskRequest{Id: req.Param("id")})
+	if getErr != nil {
+		// the task is gone by now, which is the more useful thing to hear
+		return writeError(w, req, title, getErr)
+	}
+	return writePreconditionFailed(w, req, title, current.Task.Etag, errors.New(status.Convert(err).Message()))
+}
+
+func writePreconditionFailed(w http.ResponseWriter, req bunrouter.Request, title, etag string, err error) error {
+	w.Header().Set("ETag", etag)
+	return writeProblem(w, req, models.Problem{
+		Title:  title,
+		Status: http.StatusPreconditionFailed,
+		Detail: err.Error(),
+		Code:   codeName(codes.Aborted),
+		ETag:   etag,
+	})
+}
+
+// Writes a 400 Bad Request problem, for requests we reject before they
+// ever reach the internal service
+func writeBadRequest(w http.ResponseWriter, req bunrouter.Request, title string, err error) error {
+	return writeProblem(w, req, models.Problem{
+		Title:  title,
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+	})
+}
+
+// Answers requests to routes we do not serve
+func notFoundHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	return writeProblem(w, req, models.Problem{
+		Title:  "Not Found",
+		Status: http.StatusNotFound,
+		Detail: "no route matches " + req.URL.Path,
+	})
+}
+
+// Answers requests using a method the matched route does not support
+func methodNotAllowedHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	return writeProblem(w, req, models.Problem{
+		Title:  "Method Not Allowed",
+		Status: http.StatusMethodNotAllowed,
+		Detail: req.Method + " is not supported on " + req.URL.Path,
+	})
+}
+
+// Writes the problem out with its status code, filling in the defaults
+func writeProblem(w http.ResponseWriter, req bunrouter.Request, problem models.Problem) error {
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Instance == "" {
+		problem.Instance = req.URL.Path
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	return json.NewEncoder(w).Encode(problem)
+}
+
+// i.e. codes.NotFound becomes "NOT_FOUND"
+func codeName(code codes.Code) string {
+	var name strings.Builder
+	for i, r := range code.String() {
+		if i > 0 && unicode.IsUpper(r) {
+			name.WriteByte('_')
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+	return name.String()
+}
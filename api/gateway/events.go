@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+)
+
+// How often we send a comment down an idle event stream, so proxies do
+// not time it out and we notice clients that went away
+const eventHeartbeatInterval = 15 * time.Second
+
+// How long browsers wait before reconnecting to a stream that dropped
+const eventRetryDelay = 3 * time.Second
+
+// Handles the request to watch tasks for changes, relaying the core's
+// WatchTasks stream as Server-Sent Events until the client disconnects
+//
+// WatchTasks godoc
+//
+//	@Summary		Watch tasks
+//	@Description	Streams an event whenever a task matching the filters is created, updated or deleted, as text/event-stream. Each event is named after its type, carries the event's id and a TaskEventResponse as its data. Updates that take a task out of the filters are named "left", and clients should drop the task from view as they would a deleted one. Reconnecting browsers send the last id they saw in Last-Event-ID to resume after it. An id that is too old is answered with 400 and code OUT_OF_RANGE: the events since have been lost, so clients must throw away what they know of their tasks, list them afresh, then watch again without an id. Should the stream fail after it started, a final "problem" event carries the error.
+//	@Tags			tasks
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header		string	false	"Id of the last event seen, to resume after it"
+//	@Param			last_event_id	query		string	false	"Same as Last-Event-ID, for clients that cannot set headers"
+//	@Param			q				query		string	false	"Filter query, i.e. status:open tag:backend priority>=high"
+//	@Param			tz				query		string	false	"IANA time zone the days in q are taken in, defaults to UTC"
+//	@Param			tags_any		query		string	false	"Comma separated tags, tasks carrying at least one of them"
+//	@Param			tags_all		query		string	false	"Comma separated tags, tasks carrying all of them"
+//	@Param			project_id		query		string	false	"Only tasks filed under this project"
+//	@Success		200				{object}	models.TaskEventResponse
+//	@Failure		400				{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tasks/events [get]
+func (g *Gateway) WatchTasksHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	query := req.URL.Query()
+
+	// browsers send Last-Event-ID by themselves when reconnecting
+	cursor := req.Header.Get("Last-Event-ID")
+	if cursor == "" {
+		cursor = query.Get("last_event_id")
+	}
+
+	// the watch lasts as long as the client stays connected, cancelling it
+	// on the way out hangs up on the core too
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	stream, err := g.grpcClient.WatchTasks(ctx, &api.WatchTasksRequest{
+		Cursor:    cursor,
+		Query:     query.Get("q"),
+		ProjectId: query.Get("project_id"),
+		TagsAny:   parseList(query["tags_any"]),
+		TagsAll:   parseList(query["tags_all"]),
+		TimeZone:  query.Get("tz"),
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to watch tasks", err)
+	}
+	// the core sends headers once it accepts the watch, so a rejected one
+	// can still be answered with a proper status. A watch turned away comes
+	// back without any, its status left for Recv to tell.
+	md, err := stream.Header()
+	if err == nil && md == nil {
+		_, err = stream.Recv()
+	}
+	if err != nil {
+		return writeError(w, req, "Failed to watch tasks", err)
+	}
+
+	events := make(chan *api.TaskEvent)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// keeps nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sse := eventWriter{w: w, rc: http.NewResponseController(w)}
+	if err := sse.write("retry: %d\n\n", eventRetryDelay.Milliseconds()); err != nil {
+		return nil
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// the client went away
+			return nil
+
+		case <-heartbeat.C:
+			if err := sse.write(": heartbeat\n\n"); err != nil {
+				return nil
+			}
+
+		case event := <-events:
+			serialized := serializeTaskEvent(event)
+			if serialized.Type == "" {
+				// a type of event newer than us, which clients could not make sense of
+				continue
+			}
+			data, err := json.Marshal(serialized)
+			if err != nil {
+				return err
+			}
+			if err := sse.write("id: %s\nevent: %s\ndata: %s\n\n", serialized.ID, serialized.Type, data); err != nil {
+				return nil
+			}
+
+		case err := <-failed:
+			// too late for a status, so the client gets the problem as an
+			// event and reconnects after the last event it saw
+			problem := toProblem("Task events stopped", err)
+			problem.Type = "about:blank"
+			problem.Instance = req.URL.Path
+			data, _ := json.Marshal(problem)
+			_ = sse.write("event: problem\ndata: %s\n\n", data)
+			return nil
+		}
+	}
+}
+
+// Writes Server-Sent Events, flushing each one out straight away
+type eventWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (e eventWriter) write(format string, args ...interface{}) error {
+	if _, err := fmt.Fprintf(e.w, format, args...); err != nil {
+		return err
+	}
+	return e.rc.Flush()
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+)
+
+// Handles the request to list our projects
+//
+// ListProjects godoc
+//
+//	@Summary		List all projects
+//	@Description	Fetches every project by name, archived ones only when asked for
+//	@Tags			projects
+//	@Accept			json
+//	@Produce		json
+//	@Param			include_archived	query		bool	false	"Also return archived projects"
+//	@Success		200					{array}		models.ProjectResponse
+//	@Failure		503					{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/projects [get]
+func (g *Gateway) ListProjectsHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	includeArchived := req.URL.Query().Get("include_archived") == "true"
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.projectClient.ListProjects(ctx, &api.ListProjectsRequest{IncludeArchived: includeArchived})
+	if err != nil {
+		return writeError(w, req, "Failed to list projects", err)
+	}
+
+	projects := make([]models.ProjectResponse, 0, len(resp.Projects))
+	for _, project := range resp.Projects {
+		projects = append(projects, serializeProject(project))
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"projects": projects})
+}
+
+// Handles the request to create a new project
+//
+// CreateProject godoc
+//
+//	@Summary		Create a project
+//	@Description	Creates a new project to file tasks under
+//	@Tags			projects
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.ProjectRequest	true	"Project payload"
+//	@Success		201		{object}	models.ProjectResponse
+//	@Failure		400		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/projects [post]
+func (g *Gateway) CreateProjectHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var requestSerializer models.ProjectRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&requestSerializer); err != nil {
+		return writeBadRequest(w, req, "Failed to create project", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.projectClient.CreateProject(ctx, &api.CreateProjectRequest{
+		Name:        requestSerializer.Name,
+		Description: requestSerializer.Description,
+		Color:       requestSerializer.Color,
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to create project", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return bunrouter.JSON(w, bunrouter.H{
+		"message": "Project created successfully",
+		"data":    serializeProject(resp.Project),
+	})
+}
+
+// Handles the request to get a specific project
+//
+// GetProject godoc
+//
+//	@Summary		Get a project
+//	@Description	Fetches a project by ID
+//	@Tags			projects
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Project ID"
+//	@Success		200	{object}	models.ProjectResponse
+//	@Failure		404	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/projects/{id} [get]
+func (g *Gateway) GetProjectHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.projectClient.GetProject(ctx, &api.GetProjectRequest{Id: req.Param("id")})
+	if err != nil {
+		return writeError(w, req, "Failed to fetch project", err)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"project": serializeProject(resp.Project)})
+}
+
+// Handles the request to update a project, replacing every field
+//
+// UpdateProject godoc
+//
+//	@Summary		Update a project
+//	@Description	Updates an existing project, archiving or unarchiving it along the way
+//	@Tags			projects
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"Project ID"
+//	@Param			request	body		models.ProjectRequest	true	"Updated Project Data"
+//	@Success		200		{object}	models.ProjectResponse
+//	@Failure		400		{object}	models.Problem
+//	@Failure		404		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/projects/{id} [put]
+func (g *Gateway) UpdateProjectHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var requestSerializer models.ProjectRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&requestSerializer); err != nil {
+		return writeBadRequest(w, req, "Invalid request payload", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.projectClient.UpdateProject(ctx, &api.UpdateProjectRequest{
+		Id:          req.Param("id"),
+		Name:        requestSerializer.Name,
+		Description: requestSerializer.Description,
+		Color:       requestSerializer.Color,
+		Archived:    requestSerializer.Archived,
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to update project", err)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"project": serializeProject(resp.Project)})
+}
+
+// Handles the request to delete a project, its tasks are kept outside of any project
+//
+// DeleteProject godoc
+//
+//	@Summary		Delete a project
+//	@Description	Deletes a project by ID, keeping its tasks
+//	@Tags			projects
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	string	true	"Project ID"
+//	@Success		204
+//	@Failure		404	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/projects/{id} [delete]
+func (g *Gateway) DeleteProjectHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	_, err := g.projectClient.DeleteProject(ctx, &api.DeleteProjectRequest{Id: req.Param("id")})
+	if err != nil {
+		return writeError(w, req, "Failed to delete project", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Handles the request to list the tasks filed under a project. It takes
+// the same filters as listing every task.
+//
+// ListProjectTasks godoc
+//
+//	@Summary		List the tasks of a project
+//	@Description	Fetches the tasks filed under a project, filtered and sorted like /tasks
+//	@Tags			projects
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		string	true	"Project ID"
+//	@Param			due			query		string	false	"Due window"	Enums(overdue, today, week, none)
+//	@Param			tz			query		string	false	"IANA time zone used for today and week, defaults to UTC"
+//	@Param			title		query		string	false	"Only tasks whose title contains this text"
+//	@Param			order_by	query		string	false	"Sort order, i.e. priority desc,due_at"
+//	@Param			page_size	query		int		false	"Tasks per page, at most 200"
+//	@Param			page_token	query		string	false	"Token of the page to fetch, taken from next_page_token"
+//	@Param			tags_any	query		string	false	"Comma separated tags, tasks carrying at least one of them"
+//	@Param			tags_all	query		string	false	"Comma separated tags, tasks carrying all of them"
+//	@Param			q			query		string	false	"Filter query, i.e. status:open tag:backend priority>=high"
+//	@Success		200			{object}	models.TaskListResponse
+//	@Failure		400			{object}	models.Problem
+//	@Failure		404			{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/projects/{id}/tasks [get]
+func (g *Gateway) ListProjectTasksHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	listRequest, err := parseListTasksQuery(req.URL.Query())
+	if err != nil {
+		return writeBadRequest(w, req, "Invalid query parameters", err)
+	}
+	// the project always comes from the route
+	listRequest.ProjectId = req.Param("id")
+
+	ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
+	defer cancel()
+
+	// we look the project up first so a missing project is not mistaken for an empty one
+	if _, err := g.projectClient.GetProject(ctx, &api.GetProjectRequest{Id: listRequest.ProjectId}); err != nil {
+		return writeError(w, req, "Failed to fetch project", err)
+	}
+
+	resp, err := g.grpcClient.ListTasks(ctx, listRequest)
+	if err != nil {
+		return writeError(w, req, "Failed to list tasks", err)
+	}
+
+	return bunrouter.JSON(w, toTaskListResponse(req, resp.Tasks, resp.NextPageToken))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+)
+
+var errMissingQuery = errors.New("q is required")
+
+// Handles the request to search tasks by their title and description
+//
+// SearchTasks godoc
+//
+//	@Summary		Search tasks
+//	@Description	Searches the titles and descriptions of tasks, best matches first. Every word of q has to match, "quoted phrases" match words in a row and a trailing * matches words by prefix, i.e. plan* matches planning. Matches are wrapped in <mark></mark> in the title and in a snippet of the description.
+//	@Tags			tasks
+//	@Accept			json
+//	@Produce		json
+//	@Param			q			query		string	true	"Search query, i.e. \"sprint review\" plan*"
+//	@Param			page_size	query		int		false	"Results per page, at most 200"
+//	@Param			page_token	query		string	false	"Token of the page to fetch, taken from next_page_token"
+//	@Success		200			{object}	models.TaskSearchResponse
+//	@Failure		400			{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tasks/search [get]
+func (g *Gateway) SearchTasksHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	query := req.URL.Query()
+
+	q := strings.TrimSpace(query.Get("q"))
+	if q == "" {
+		return writeBadRequest(w, req, "Invalid query parameters", errMissingQuery)
+	}
+	pageSize, err := parsePageSize(query)
+	if err != nil {
+		return writeBadRequest(w, req, "Invalid query parameters", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.SearchTasks(ctx, &api.SearchTasksRequest{
+		Query:     q,
+		PageSize:  pageSize,
+		PageToken: query.Get("page_token"),
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to search tasks", err)
+	}
+
+	results := make([]models.TaskMatchResponse, 0, len(resp.Matches))
+	for _, match := range resp.Matches {
+		results = append(results, models.TaskMatchResponse{
+			Task:           serializeTask(match.Task),
+			Rank:           match.Rank,
+			TitleHighlight: match.TitleHighlight,
+			Snippet:        match.Snippet,
+		})
+	}
+
+	return bunrouter.JSON(w, models.TaskSearchResponse{
+		Results:       results,
+		NextPageToken: resp.NextPageToken,
+		Next:          nextPageLink(req, resp.NextPageToken),
+	})
+}
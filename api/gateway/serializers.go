@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const statusEnumPrefix = "TASK_STATUS_"
+
+var errInvalidPageSize = errors.New("page_size must be a positive number")
+
+// Serializes a task coming off the wire into our HTTP response payload
+func serializeTask(task *api.Task) models.TaskResponse {
+	return models.TaskResponse{
+		ID:          task.Id,
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      serializeStatus(task.Status),
+		Priority:    serializePriority(task.Priority),
+		CreatedAt:   serializeTimestamp(task.CreatedAt),
+		UpdatedAt:   serializeTimestamp(task.UpdatedAt),
+		CompletedAt: serializeTimestamp(task.CompletedAt),
+		StartAt:     serializeTimestamp(task.StartAt),
+		DueAt:       serializeTimestamp(task.DueAt),
+		Tags:        append([]string{}, task.Tags...),
+		ParentID:    task.ParentId,
+		ProjectID:   task.ProjectId,
+		ETag:        task.Etag,
+		DeletedAt:   serializeTimestamp(task.DeletedAt),
+		Subtasks:    serializeSubtasks(task.Subtasks),
+	}
+}
+
+// The names task events go by, which the events of our SSE stream are
+// named after too
+var taskEventTypes = map[api.TaskEventType]string{
+	api.TaskEventType_TASK_EVENT_TYPE_CREATED: "created",
+	api.TaskEventType_TASK_EVENT_TYPE_UPDATED: "updated",
+	api.TaskEventType_TASK_EVENT_TYPE_DELETED: "deleted",
+	api.TaskEventType_TASK_EVENT_TYPE_LEFT:    "left",
+}
+
+func serializeTaskEvent(event *api.TaskEvent) models.TaskEventResponse {
+	serialized := models.TaskEventResponse{
+		ID:         event.Id,
+		Type:       taskEventTypes[event.Type],
+		TaskID:     event.TaskId,
+		OccurredAt: serializeTimestamp(event.OccurredAt),
+	}
+	if event.Task != nil {
+		task := serializeTask(event.Task)
+		serialized.Task = &task
+	}
+	return serialized
+}
+
+// keeps subtasks out of the payload entirely unless a subtree was loaded
+func serializeSubtasks(subtasks []*api.Task) []models.TaskResponse {
+	if len(subtasks) == 0 {
+		return nil
+	}
+	return serializeTasks(subtasks)
+}
+
+func serializeTasks(tasks []*api.Task) []models.TaskResponse {
+	serialized := make([]models.TaskResponse, 0, len(tasks))
+	for _, task := range tasks {
+		serialized = append(serialized, serializeTask(task))
+	}
+	return serialized
+}
+
+// Maps the JSON payload of a create request onto its gRPC request
+func toCreateTaskRequest(payload models.TaskRequest) (*api.CreateTaskRequest, error) {
+	priority, err := parsePriority(payload.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	startAt, dueAt, err := parseSchedule(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.CreateTaskRequest{
+		Title:       payload.Title,
+		Description: payload.Description,
+		StartAt:     startAt,
+		DueAt:       dueAt,
+		Tags:        payload.Tags,
+		Priority:    priority,
+		ParentId:    payload.ParentID,
+		ProjectId:   payload.ProjectID,
+	}, nil
+}
+
+// Maps the JSON payload of an update request onto its gRPC request
+func toUpdateTaskRequest(id string, payload models.TaskRequest) (*api.UpdateTaskRequest, error) {
+	status, err := parseStatus(payload.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	priority, err := parsePriority(payload.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	startAt, dueAt, err := parseSchedule(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.UpdateTaskRequest{
+		Id:          id,
+		Title:       payload.Title,
+		Description: payload.Description,
+		Status:      status,
+		StartAt:     startAt,
+		DueAt:       dueAt,
+		Tags:        payload.Tags,
+		Priority:    priority,
+		ParentId:    payload.ParentID,
+		ProjectId:   payload.ProjectID,
+	}, nil
+}
+
+// the fields of a task a merge patch may carry, named as in our JSON
+// payloads and in the update mask alike
+var patchableTaskFields = []string{"title", "description", "status", "priority", "start_at", "due_at", "tags", "parent_id", "project_id"}
+
+// Maps a JSON Merge Patch of a task onto an UpdateTask request whose update
+// mask names every field the patch carries. A null decodes to the field's
+// zero value, which clears it.
+func toPatchTaskRequest(id string, patch map[string]json.RawMessage) (*api.UpdateTaskRequest, error) {
+	mask := make([]string, 0, len(patch))
+	for field := range patch {
+		if !slices.Contains(patchableTaskFields, field) {
+			return nil, fmt.Errorf("unknown field %q, expected any of %s", field, strings.Join(patchableTaskFields, ", "))
+		}
+		mask = append(mask, field)
+	}
+	slices.Sort(mask)
+
+	raw, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	var payload models.TaskRequest
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+
+	updateRequest, err := toUpdateTaskRequest(id, payload)
+	if err != nil {
+		return nil, err
+	}
+	updateRequest.UpdateMask = &fieldmaskpb.FieldMask{Paths: mask}
+	return updateRequest, nil
+}
+
+// Formats a timestamp coming off the wire as RFC3339 in UTC, leaving unset ones empty
+func serializeTimestamp(timestamp *timestamppb.Timestamp) string {
+	if timestamp == nil {
+		return ""
+	}
+	return timestamp.AsTime().UTC().Format(time.RFC3339Nano)
+}
+
+// Parses an RFC3339 timestamp sent by our HTTP clients, an empty one being unset
+func parseTimestamp(field, timestamp string) (*timestamppb.Timestamp, error) {
+	if timestamp == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 timestamp, i.e. 2025-03-21T17:00:00Z", field)
+	}
+	return timestamppb.New(parsed), nil
+}
+
+func parseSchedule(payload models.TaskRequest) (startAt, dueAt *timestamppb.Timestamp, err error) {
+	if startAt, err = parseTimestamp("start_at", payload.StartAt); err != nil {
+		return
+	}
+	dueAt, err = parseTimestamp("due_at", payload.DueAt)
+	return
+}
+
+// Reads the entity tag a conditional write is made against. Without an
+// If-Match, or with "*", the write goes ahead whatever the task is at.
+// A weak tag never matches, as If-Match compares strongly.
+func parseIfMatch(req bunrouter.Request) (string, error) {
+	ifMatch := strings.TrimSpace(req.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return "", nil
+	}
+	if strings.Contains(ifMatch, ",") {
+		return "", errors.New("If-Match takes a single entity tag")
+	}
+	return ifMatch, nil
+}
+
+// i.e. TASK_STATUS_IN_PROGRESS becomes "in_progress"
+func serializeStatus(status api.TaskStatus) string {
+	if status == api.TaskStatus_TASK_STATUS_UNSPECIFIED {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(status.String(), statusEnumPrefix))
+}
+
+// Parses the status sent by our HTTP clients, i.e. "in_progress".
+// An empty status parses to TASK_STATUS_UNSPECIFIED.
+func parseStatus(status string) (api.TaskStatus, error) {
+	if status == "" {
+		return api.TaskStatus_TASK_STATUS_UNSPECIFIED, nil
+	}
+
+	value, ok := api.TaskStatus_value[statusEnumPrefix+strings.ToUpper(status)]
+	if !ok || !models.TaskStatus(status).IsValid() {
+		return api.TaskStatus_TASK_STATUS_UNSPECIFIED, fmt.Errorf("unknown status %q", status)
+	}
+	return api.TaskStatus(value), nil
+}
+
+// i.e. TASK_PRIORITY_HIGH becomes "high"
+func serializePriority(priority api.TaskPriority) string {
+	return models.TaskPriority(priority).String()
+}
+
+// Parses the priority sent by our HTTP clients, i.e. "high".
+// An empty priority parses to TASK_PRIORITY_NONE.
+func parsePriority(priority string) (api.TaskPriority, error) {
+	if priority == "" {
+		return api.TaskPriority_TASK_PRIORITY_NONE, nil
+	}
+
+	parsed, err := models.ParsePriority(priority)
+	if err != nil {
+		return api.TaskPriority_TASK_PRIORITY_NONE, err
+	}
+	return api.TaskPriority(parsed), nil
+}
+
+// our HTTP clients pick a due window with ?due=
+var dueFilters = map[string]api.DueFilter{
+	"overdue": api.DueFilter_DUE_FILTER_OVERDUE,
+	"today":   api.DueFilter_DUE_FILTER_DUE_TODAY,
+	"week":    api.DueFilter_DUE_FILTER_DUE_THIS_WEEK,
+	"none":    api.DueFilter_DUE_FILTER_NO_DUE_DATE,
+}
+
+// Parses the ?due= query parameter. An empty value means no due filter.
+func parseDueFilter(due string) (api.DueFilter, error) {
+	if due == "" {
+		return api.DueFilter_DUE_FILTER_UNSPECIFIED, nil
+	}
+
+	filter, ok := dueFilters[due]
+	if !ok {
+		return api.DueFilter_DUE_FILTER_UNSPECIFIED, fmt.Errorf("unknown due filter %q, expected one of overdue, today, week or none", due)
+	}
+	return filter, nil
+}
+
+// Maps the query parameters of GET /tasks onto a ListTasks request
+func parseListTasksQuery(query url.Values) (*api.ListTasksRequest, error) {
+	dueFilter, err := parseDueFilter(query.Get("due"))
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize, err := parsePageSize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &api.ListTasksRequest{
+		DueFilter:     dueFilter,
+		TimeZone:      query.Get("tz"),
+		PageSize:      pageSize,
+		PageToken:     query.Get("page_token"),
+		TitleContains: query.Get("title"),
+		OrderBy:       query.Get("order_by"),
+		TagsAny:       parseList(query["tags_any"]),
+		TagsAll:       parseList(query["tags_all"]),
+		ProjectId:     query.Get("project_id"),
+		Query:         query.Get("q"),
+	}
+
+	bounds := []struct {
+		field string
+		dest  **timestamppb.Timestamp
+	}{
+		{"created_after", &req.CreatedAfter},
+		{"created_before", &req.CreatedBefore},
+		{"updated_after", &req.UpdatedAfter},
+		{"updated_before", &req.UpdatedBefore},
+	}
+	for _, bound := range bounds {
+		if *bound.dest, err = parseTimestamp(bound.field, query.Get(bound.field)); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// Reads the ?page_size= query parameter, zero leaving it up to the server
+func parsePageSize(query url.Values) (int32, error) {
+	raw := query.Get("page_size")
+	if raw == "" {
+		return 0, nil
+	}
+
+	pageSize, err := strconv.ParseInt(raw, 10, 32)
+	if err != nil || pageSize < 0 {
+		return 0, errInvalidPageSize
+	}
+	return int32(pageSize), nil
+}
+
+// Builds a page of tasks, linking to the next page with every other
+// query parameter of the current request kept as is
+func toTaskListResponse(req bunrouter.Request, tasks []*api.Task, nextPageToken string) models.TaskListResponse {
+	return models.TaskListResponse{
+		Tasks:         serializeTasks(tasks),
+		NextPageToken: nextPageToken,
+		Next:          nextPageLink(req, nextPageToken),
+	}
+}
+
+// Builds the link to the page after the one requested, the same request
+// with its page_token swapped out. There is none on the last page.
+func nextPageLink(req bunrouter.Request, nextPageToken string) string {
+	if nextPageToken == "" {
+		return ""
+	}
+	query := req.URL.Query()
+	query.Set("page_token", nextPageToken)
+	return req.URL.Path + "?" + query.Encode()
+}
+
+// Reads a list query parameter, which our clients may either repeat
+// (?tag=a&tag=b) or send comma separated (?tag=a,b)
+func parseList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+func serializeProject(project *api.Project) models.ProjectResponse {
+	return models.ProjectResponse{
+		ID:          project.Id,
+		Name:        project.Name,
+		Description: project.Description,
+		Color:       project.Color,
+		Archived:    project.Archived,
+		CreatedAt:   project.CreatedAt,
+		UpdatedAt:   project.UpdatedAt,
+	}
+}
+
+func serializeSavedView(view *api.SavedView) models.SavedViewResponse {
+	return models.SavedViewResponse{
+		ID:        view.Id,
+		Name:      view.Name,
+		Query:     view.Query,
+		OrderBy:   view.OrderBy,
+		GroupBy:   view.GroupBy,
+		TimeZone:  view.TimeZone,
+		CreatedAt: view.CreatedAt,
+		UpdatedAt: view.UpdatedAt,
+	}
+}
+
+// Serializes a page of a saved view's tasks along with its counts
+func toSavedViewTasksResponse(req bunrouter.Request, resp *api.ExecuteSavedViewResponse) models.SavedViewTasksResponse {
+	var groups []models.TaskGroupResponse
+	for _, group := range resp.Groups {
+		groups = append(groups, models.TaskGroupResponse{Key: group.Key, Count: int(group.Count)})
+	}
+
+	return models.SavedViewTasksResponse{
+		View:          serializeSavedView(resp.View),
+		Tasks:         serializeTasks(resp.Tasks),
+		TotalCount:    int(resp.TotalCount),
+		Groups:        groups,
+		NextPageToken: resp.NextPageToken,
+		Next:          nextPageLink(req, resp.NextPageToken),
+	}
+}
+
+func serializeUser(user *api.User) models.UserResponse {
+	return models.UserResponse{
+		ID:        user.Id,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func serializeAPIKey(key *api.APIKey) models.APIKeyResponse {
+	return models.APIKeyResponse{
+		ID:         key.Id,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     append([]string{}, key.Scopes...),
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
+
+func serializeTag(tag *api.Tag) models.TagResponse {
+	return models.TagResponse{
+		ID:        tag.Id,
+		Name:      tag.Name,
+		TaskCount: int(tag.TaskCount),
+	}
+}
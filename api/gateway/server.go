@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -21,20 +22,39 @@ import (
 
 // Represents an API Gateway that will be used to route requests to the appropriate service
 type Gateway struct {
-	grpcClient api.TaskServiceClient
+	grpcClient    api.TaskServiceClient
+	projectClient api.ProjectServiceClient
+	userClient    api.UserServiceClient
+	apiKeyClient  api.APIKeyServiceClient
+	viewClient    api.SavedViewServiceClient
+	tokens        tokenSigner
 }
 
 // creates a new Task API Gateway instance
 // grpcAddress: the address of the gRPC server
+// jwtSecret: the key our login tokens are signed with
+// tokenTTL: how long a login token stays valid
+// gatewayToken: the token the gRPC server lets our calls in by
 // returns a new Gateway instance and an error if any
-func NewGateway(grpcAddress string) (*Gateway, error) {
-	conn, err := grpc.Dial(grpcAddress, grpc.WithInsecure())
+func NewGateway(grpcAddress string, jwtSecret []byte, tokenTTL time.Duration, gatewayToken string) (*Gateway, error) {
+	conn, err := grpc.Dial(grpcAddress,
+		grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(forwardIdentity(gatewayToken)),
+		grpc.WithStreamInterceptor(forwardIdentityStream(gatewayToken)),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to gRPC server: %w", err)
 	}
 
 	client := api.NewTaskServiceClient(conn)
-	return &Gateway{grpcClient: client}, nil
+	return &Gateway{
+		grpcClient:    client,
+		projectClient: api.NewProjectServiceClient(conn),
+		userClient:    api.NewUserServiceClient(conn),
+		apiKeyClient:  api.NewAPIKeyServiceClient(conn),
+		viewClient:    api.NewSavedViewServiceClient(conn),
+		tokens:        tokenSigner{secret: jwtSecret, ttl: tokenTTL},
+	}, nil
 }
 
 /// --- API Gateway Handlers ---
@@ -51,15 +71,15 @@ func NewGateway(grpcAddress string) (*Gateway, error) {
 // Returns:
 //
 //	error: An error if the operation fails, or nil if successful.
-//	  - If the gRPC call fails, it returns a 500 Internal Server Error with a JSON payload
-//	    containing an "error" and "error_message" field.
+//	  - If the gRPC call fails, its status code is translated into the matching HTTP status
+//	    (see writeError) with an application/problem+json payload.
 //	  - If successful, it returns a 200 OK with a JSON payload containing the list of tasks.
 //
 // Example:
 //
-//	Request: GET /tasks
-//	Response (Success): 200 OK, JSON: {"tasks": [...]}
-//	Response (Error):   500 Internal Server Error, JSON: {"error": "Failed to list tasks", "error_message": "grpc: ..."}
+//	Request: GET /tasks?page_size=20&order_by=due_at
+//	Response (Success): 200 OK, JSON: {"tasks": [...], "next_page_token": "...", "next": "/api/v1/tasks?..."}
+//	Response (Error):   503 Service Unavailable, JSON: {"title": "Failed to list tasks", "status": 503, "detail": "...", "code": "UNAVAILABLE"}
 //
 // ListTasks godoc
 //
@@ -68,22 +88,41 @@ func NewGateway(grpcAddress string) (*Gateway, error) {
 //	@Tags			tasks
 //	@Accept			json
 //	@Produce		json
-//	@Success		200	{array}	models.Task
+//	@Param			due				query		string	false	"Due window"	Enums(overdue, today, week, none)
+//	@Param			tz				query		string	false	"IANA time zone used for today and week, defaults to UTC"
+//	@Param			title			query		string	false	"Only tasks whose title contains this text"
+//	@Param			created_after	query		string	false	"RFC3339 lower bound on created_at"
+//	@Param			created_before	query		string	false	"RFC3339 upper bound on created_at"
+//	@Param			updated_after	query		string	false	"RFC3339 lower bound on updated_at"
+//	@Param			updated_before	query		string	false	"RFC3339 upper bound on updated_at"
+//	@Param			order_by		query		string	false	"Sort order, i.e. priority desc,due_at"
+//	@Param			page_size		query		int		false	"Tasks per page, at most 200"
+//	@Param			page_token		query		string	false	"Token of the page to fetch, taken from next_page_token"
+//	@Param			tags_any		query		string	false	"Comma separated tags, tasks carrying at least one of them"
+//	@Param			tags_all		query		string	false	"Comma separated tags, tasks carrying all of them"
+//	@Param			project_id		query		string	false	"Only tasks filed under this project"
+//	@Param			q				query		string	false	"Filter query, i.e. status:open tag:backend due<2026-11-01 priority>=high"
+//	@Success		200				{object}	models.TaskListResponse
+//	@Failure		400				{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
 //	@Router			/tasks [get]
 func (g *Gateway) ListTasksHandler(w http.ResponseWriter, req bunrouter.Request) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	listRequest, err := parseListTasksQuery(req.URL.Query())
+	if err != nil {
+		return writeBadRequest(w, req, "Invalid query parameters", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
 	defer cancel()
 
-	resp, err := g.grpcClient.ListTasks(ctx, &api.ListTasksRequest{})
+	resp, err := g.grpcClient.ListTasks(ctx, listRequest)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return bunrouter.JSON(w, bunrouter.H{
-			"error":         "Failed to list tasks",
-			"error_message": err.Error(),
-		})
+		return writeError(w, req, "Failed to list tasks", err)
 	}
 
-	return bunrouter.JSON(w, resp)
+	// the next link keeps every filter of this request, only moving the page along
+	return bunrouter.JSON(w, toTaskListResponse(req, resp.Tasks, resp.NextPageToken))
 }
 
 // Handles the request to create a new task
@@ -98,8 +137,8 @@ func (g *Gateway) ListTasksHandler(w http.ResponseWriter, req bunrouter.Request)
 // Returns:
 //
 //	error: An error if the operation fails, or nil if successful.
-//	  - If the gRPC call fails, it returns a 500 Internal Server Error with a JSON payload
-//	    containing an "error" and "error_message" field.
+//	  - If the gRPC call fails, its status code is translated into the matching HTTP status
+//	    (see writeError) with an application/problem+json payload.
 //	  - If successful, it returns a 201 Created with a JSON payload containing the created task.
 //
 // Example:
@@ -107,7 +146,7 @@ func (g *Gateway) ListTasksHandler(w http.ResponseWriter, req bunrouter.Request)
 //	Request: POST /tasks
 //	Body: {"title": "Task 1", "description": "Description 1"}
 //	Response (Success): 201 Created, JSON: {"task": {...}}
-//	Response (Error):   500 Internal Server Error, JSON: {"error": "Failed to create task", "error_message": "grpc: ..."}
+//	Response (Error):   400 Bad Request, JSON: {"title": "Failed to create task", "status": 400, "detail": "Error creating task: invalid task: title is required", "code": "INVALID_ARGUMENT"}
 
 // CreateTask godoc
 //
@@ -118,57 +157,38 @@ func (g *Gateway) ListTasksHandler(w http.ResponseWriter, req bunrouter.Request)
 //	@Produce		json
 //	@Param			request	body		models.TaskRequest	true	"Task payload"
 //	@Success		201		{object}	models.TaskResponse
-//	@Failure		400		{object}	map[string]string
+//	@Header			201		{string}	ETag	"The new task's entity tag"
+//	@Failure		400		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
 //	@Router			/tasks [post]
 func (g *Gateway) CreateTaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
 	// Serializer for the request body
-	var requestSerializer struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-	}
+	var requestSerializer models.TaskRequest
 
 	if err := json.NewDecoder(req.Body).Decode(&requestSerializer); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return bunrouter.JSON(w, bunrouter.H{
-			"error":         "Failed to create task",
-			"error_message": err.Error(),
-		})
+		return writeBadRequest(w, req, "Failed to create task", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	createRequest, err := toCreateTaskRequest(requestSerializer)
+	if err != nil {
+		return writeBadRequest(w, req, "Failed to create task", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
 	defer cancel()
 
-	resp, err := g.grpcClient.CreateTask(ctx, &api.CreateTaskRequest{
-		Title:       requestSerializer.Title,
-		Description: requestSerializer.Description,
-	})
+	resp, err := g.grpcClient.CreateTask(ctx, createRequest)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return bunrouter.JSON(w, bunrouter.H{
-			"error":         "Failed to create task",
-			"error_message": err.Error(),
-		})
+		return writeError(w, req, "Failed to create task", err)
 	}
 
-	// let's serialize our response with "data" field and "message" field
-	taskResponseData := models.TaskResponse{
-		ID:          resp.Task.Id,
-		Title:       resp.Task.Title,
-		Description: resp.Task.Description,
-		CreatedAt:   resp.Task.CreatedAt,
-		UpdatedAt:   resp.Task.UpdatedAt,
-	}
+	w.Header().Set("ETag", resp.Task.Etag)
 
-	// our output serializer
+	// let's serialize our response with "data" field and "message" field
 	responseSerializer := bunrouter.H{
 		"message": "Task created successfully",
-		"data": map[string]interface{}{
-			"id":          taskResponseData.ID,
-			"title":       taskResponseData.Title,
-			"description": taskResponseData.Description,
-			"created_at":  taskResponseData.CreatedAt,
-			"updated_at":  taskResponseData.UpdatedAt,
-		},
+		"data":    serializeTask(resp.Task),
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -187,15 +207,16 @@ func (g *Gateway) CreateTaskHandler(w http.ResponseWriter, req bunrouter.Request
 // Returns:
 //
 //	error: An error if the operation fails, or nil if successful.
-//	  - If the gRPC call fails, it returns a 500 Internal Server Error with a JSON payload
-//	    containing an "error" and "error_message" field.
-//	  - If successful, it returns a 200 OK with a JSON payload containing the task.
+//	  - If the gRPC call fails, its status code is translated into the matching HTTP status
+//	    (see writeError) with an application/problem+json payload.
+//	  - If successful, it returns a 200 OK with a JSON payload containing the task, along with
+//	    its ETag header for conditional updates.
 //
 // Example:
 //
 //	Request: GET /tasks/1
 //	Response (Success): 200 OK, JSON: {"task": {...}}
-//	Response (Error):   500 Internal Server Error, JSON: {"error": "Failed to get task", "error_message": "grpc: ..."}
+//	Response (Error):   404 Not Found, JSON: {"title": "Failed to get task", "status": 404, "detail": "...", "code": "NOT_FOUND"}
 //
 // GetTask godoc
 // @Summary		Get task by ID
@@ -203,26 +224,26 @@ func (g *Gateway) CreateTaskHandler(w http.ResponseWriter, req bunrouter.Request
 // @Tags			tasks
 // @Accept			json
 // @Produce		json
-// @Param			id	path		string	true	"Task ID"
-// @Success		200	{object}	models.TaskResponse
-// @Failure		404	{object}	map[string]string
+// @Param			id				path		string	true	"Task ID"
+// @Param			include_subtree	query		bool	false	"Also return every subtask below the task"
+// @Success		200				{object}	models.TaskResponse
+// @Header			200				{string}	ETag	"The task's current entity tag, to send back in If-Match"
+// @Failure		404				{object}	models.Problem
 // @Router			/tasks/{id} [get]
 func (g *Gateway) GetTaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
 	id := req.Param("id")
+	includeSubtree := req.URL.Query().Get("include_subtree") == "true"
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
 	defer cancel()
 
-	resp, err := g.grpcClient.GetTask(ctx, &api.GetTaskRequest{Id: id})
+	resp, err := g.grpcClient.GetTask(ctx, &api.GetTaskRequest{Id: id, IncludeSubtree: includeSubtree})
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		return bunrouter.JSON(w, bunrouter.H{
-			"error":         "Task not found",
-			"error_message": err.Error(),
-		})
+		return writeError(w, req, "Failed to get task", err)
 	}
 
-	return bunrouter.JSON(w, resp)
+	w.Header().Set("ETag", resp.Task.Etag)
+	return bunrouter.JSON(w, bunrouter.H{"task": serializeTask(resp.Task)})
 }
 
 // Handles the request to update a task
@@ -237,8 +258,10 @@ func (g *Gateway) GetTaskHandler(w http.ResponseWriter, req bunrouter.Request) e
 // Returns:
 //
 //	error: An error if the operation fails, or nil if successful.
-//	  - If the gRPC call fails, it returns a 500 Internal Server Error with a JSON payload
-//	    containing an "error" and "error_message" field.
+//	  - If an If-Match header is sent and the task has changed since, it returns a 412
+//	    Precondition Failed carrying the task's current ETag.
+//	  - If the gRPC call fails, its status code is translated into the matching HTTP status
+//	    (see writeError) with an application/problem+json payload.
 //	  - If successful, it returns a 200 OK with a JSON payload containing the updated task.
 //
 // UpdateTask godoc
@@ -248,51 +271,140 @@ func (g *Gateway) GetTaskHandler(w http.ResponseWriter, req bunrouter.Request) e
 //	@Tags			tasks
 //	@Accept			json
 //	@Produce		json
-//	@Param			id		path		string		true	"Task ID"
-//	@Param			request	body		models.TaskRequest	true	"Updated Task Data"
-//	@Success		200		{object}	models.TaskResponse
-//	@Failure		400		{object}	map[string]string
-//	@Failure		404		{object}	map[string]string
+//	@Param			id			path		string		true	"Task ID"
+//	@Param			If-Match	header		string		false	"Only update the task if it is still at this entity tag"
+//	@Param			request		body		models.TaskRequest	true	"Updated Task Data"
+//	@Success		200			{object}	models.TaskResponse
+//	@Header			200			{string}	ETag	"The task's new entity tag"
+//	@Failure		400			{object}	models.Problem
+//	@Failure		404			{object}	models.Problem
+//	@Failure		412			{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
 //	@Router			/tasks/{id} [put]
 func (g *Gateway) UpdateTaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
 	id := req.Param("id")
 
-	var updateRequestSerializer struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-	}
+	var updateRequestSerializer models.TaskRequest
 
 	if err := json.NewDecoder(req.Body).Decode(&updateRequestSerializer); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return bunrouter.JSON(w, bunrouter.H{
-			"error":         "Invalid request payload. Please review the request body and try again",
-			"error_message": err.Error(),
-		})
+		return writeBadRequest(w, req, "Invalid request payload", err)
+	}
+
+	updateRequest, err := toUpdateTaskRequest(id, updateRequestSerializer)
+	if err != nil {
+		return writeBadRequest(w, req, "Invalid request payload", err)
+	}
+
+	if updateRequest.Etag, err = parseIfMatch(req); err != nil {
+		return writeBadRequest(w, req, "Invalid If-Match header", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
 	defer cancel()
 
-	resp, err := g.grpcClient.UpdateTask(ctx, &api.UpdateTaskRequest{
-		Id:          id,
-		Title:       updateRequestSerializer.Title,
-		Description: updateRequestSerializer.Description,
-	})
+	resp, err := g.grpcClient.UpdateTask(ctx, updateRequest)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return bunrouter.JSON(w, bunrouter.H{
-			"error":         "Internal Server Error. Failed to update task",
-			"error_message": err.Error(),
-		})
+		return g.writeTaskError(ctx, w, req, "Failed to update task", err)
 	}
 
 	// TODO: add a "message" field to the response
-	return bunrouter.JSON(w, resp)
+	w.Header().Set("ETag", resp.Task.Etag)
+	return bunrouter.JSON(w, bunrouter.H{"task": serializeTask(resp.Task)})
+}
+
+// Handles the request to partially update a task
+//
+// The body is a JSON Merge Patch (RFC 7396): only the fields it carries are changed, a null
+// clearing the field, and every other field of the task is left as it is. Use PUT to replace
+// the whole task instead.
+//
+// Parameters:
+//
+//	w: The http.ResponseWriter to write the response to.
+//	req: The bunrouter.Request containing the HTTP request.
+//
+// Returns:
+//
+//	error: An error if the operation fails, or nil if successful.
+//	  - If the patch is malformed or names a field we do not know, it returns a 400 Bad Request.
+//	  - If an If-Match header is sent and the task has changed since, it returns a 412
+//	    Precondition Failed carrying the task's current ETag.
+//	  - If the gRPC call fails, its status code is translated into the matching HTTP status
+//	    (see writeError) with an application/problem+json payload.
+//	  - If successful, it returns a 200 OK with a JSON payload containing the updated task.
+//
+// Example:
+//
+//	Request: PATCH /tasks/1
+//	Body: {"title": "Task 1, renamed", "due_at": null}
+//	Response (Success): 200 OK, JSON: {"task": {...}}
+
+// PatchTask godoc
+//
+//	@Summary		Partially update a task
+//	@Description	Applies a JSON Merge Patch to an existing task, changing only the fields present in the body. A null clears the field.
+//	@Tags			tasks
+//	@Accept			json
+//	@Accept			application/merge-patch+json
+//	@Produce		json
+//	@Param			id			path		string		true	"Task ID"
+//	@Param			If-Match	header		string		false	"Only update the task if it is still at this entity tag"
+//	@Param			request		body		models.TaskRequest	true	"The fields to change"
+//	@Success		200			{object}	models.TaskResponse
+//	@Header			200			{string}	ETag	"The task's new entity tag"
+//	@Failure		400			{object}	models.Problem
+//	@Failure		404			{object}	models.Problem
+//	@Failure		412			{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tasks/{id} [patch]
+func (g *Gateway) PatchTaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	id := req.Param("id")
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(req.Body).Decode(&patch); err != nil || patch == nil {
+		return writeBadRequest(w, req, "Invalid merge patch", errors.New("the body must be a JSON object"))
+	}
+
+	patchRequest, err := toPatchTaskRequest(id, patch)
+	if err != nil {
+		return writeBadRequest(w, req, "Invalid merge patch", err)
+	}
+
+	if patchRequest.Etag, err = parseIfMatch(req); err != nil {
+		return writeBadRequest(w, req, "Invalid If-Match header", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	// an empty patch changes nothing, whereas an empty update mask would replace the whole task
+	if len(patch) == 0 {
+		resp, err := g.grpcClient.GetTask(ctx, &api.GetTaskRequest{Id: id})
+		if err != nil {
+			return writeError(w, req, "Failed to get task", err)
+		}
+		if patchRequest.Etag != "" && patchRequest.Etag != resp.Task.Etag {
+			return writePreconditionFailed(w, req, "Failed to update task", resp.Task.Etag, fmt.Errorf("task %s is at %s, not %s", id, resp.Task.Etag, patchRequest.Etag))
+		}
+		w.Header().Set("ETag", resp.Task.Etag)
+		return bunrouter.JSON(w, bunrouter.H{"task": serializeTask(resp.Task)})
+	}
+
+	resp, err := g.grpcClient.UpdateTask(ctx, patchRequest)
+	if err != nil {
+		return g.writeTaskError(ctx, w, req, "Failed to update task", err)
+	}
+
+	w.Header().Set("ETag", resp.Task.Etag)
+	return bunrouter.JSON(w, bunrouter.H{"task": serializeTask(resp.Task)})
 }
 
 // Handles the request to delete a task
 //
-// It deletes a task by ID using the gRPC service.
+// It moves a task by ID to the trash using the gRPC service, from where it
+// can be restored until the trash is purged (see ListTrashHandler).
 //
 // Parameters:
 //
@@ -302,34 +414,41 @@ func (g *Gateway) UpdateTaskHandler(w http.ResponseWriter, req bunrouter.Request
 // Returns:
 //
 //	error: An error if the operation fails, or nil if successful.
-//	  - If the gRPC call fails, it returns a 500 Internal Server Error with a JSON payload
-//	    containing an "error" and "error_message" field.
+//	  - If the gRPC call fails, its status code is translated into the matching HTTP status
+//	    (see writeError) with an application/problem+json payload.
+//	  - If an If-Match header is sent and the task has changed since, it returns a 412
+//	    Precondition Failed carrying the task's current ETag.
 //	  - If successful, it returns a 204 No Content response.
 //
 // DeleteTask godoc
 //
 //	@Summary		Delete a task
-//	@Description	Deletes a task by ID
+//	@Description	Moves a task and its subtasks to the trash, see /trash
 //	@Tags			tasks
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path	string	true	"Task ID"
+//	@Param			id			path	string	true	"Task ID"
+//	@Param			If-Match	header	string	false	"Only delete the task if it is still at this entity tag"
 //	@Success		204
-//	@Failure		404	{object}	map[string]string
+//	@Failure		404	{object}	models.Problem
+//	@Failure		412	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
 //	@Router			/tasks/{id} [delete]
 func (g *Gateway) DeleteTaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
 	taskID := req.Param("id")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	etag, err := parseIfMatch(req)
+	if err != nil {
+		return writeBadRequest(w, req, "Invalid If-Match header", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
 	defer cancel()
 
-	_, err := g.grpcClient.DeleteTask(ctx, &api.DeleteTaskRequest{Id: taskID})
+	_, err = g.grpcClient.DeleteTask(ctx, &api.DeleteTaskRequest{Id: taskID, Etag: etag})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		return bunrouter.JSON(w, bunrouter.H{
-			"error":         "Internal Server Error. Failed to delete task",
-			"error_message": err.Error(),
-		})
+		return g.writeTaskError(ctx, w, req, "Failed to delete task", err)
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -338,6 +457,66 @@ func (g *Gateway) DeleteTaskHandler(w http.ResponseWriter, req bunrouter.Request
 	})
 }
 
+// Handles the request to mark a task as done
+//
+// CompleteTask godoc
+//
+//	@Summary		Complete a task
+//	@Description	Marks a task as done and records when it was completed. Tasks with open subtasks are only completed with force=true, which completes those subtasks too.
+//	@Tags			tasks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string	true	"Task ID"
+//	@Param			force	query		bool	false	"Complete open subtasks along with the task"
+//	@Success		200		{object}	models.TaskResponse
+//	@Failure		404		{object}	models.Problem
+//	@Failure		409		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tasks/{id}/complete [post]
+func (g *Gateway) CompleteTaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.CompleteTask(ctx, &api.CompleteTaskRequest{
+		Id:    req.Param("id"),
+		Force: req.URL.Query().Get("force") == "true",
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to complete task", err)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"task": serializeTask(resp.Task)})
+}
+
+// Handles the request to reopen a done or cancelled task
+//
+// ReopenTask godoc
+//
+//	@Summary		Reopen a task
+//	@Description	Moves a done or cancelled task back to todo
+//	@Tags			tasks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Task ID"
+//	@Success		200	{object}	models.TaskResponse
+//	@Failure		404	{object}	models.Problem
+//	@Failure		409	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tasks/{id}/reopen [post]
+func (g *Gateway) ReopenTaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.ReopenTask(ctx, &api.ReopenTaskRequest{Id: req.Param("id")})
+	if err != nil {
+		return writeError(w, req, "Failed to reopen task", err)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"task": serializeTask(resp.Task)})
+}
+
 // Initalizes a HTTP router with gRPC integration
 //
 //	@title			Notes Tracker API
@@ -348,8 +527,21 @@ func (g *Gateway) DeleteTaskHandler(w http.ResponseWriter, req bunrouter.Request
 //	@license		MIT
 //	@BasePath		/api/v1
 //	@schemes		http
+//
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				"Bearer" followed by the access_token returned from /auth/login
+//
+//	@securityDefinitions.apikey	ApiKeyAuth
+//	@in							header
+//	@name						X-API-Key
+//	@description				A key created through /api-keys, limited to its scopes
 func NewServer(gateway *Gateway) *bunrouter.Router {
-	router := bunrouter.New()
+	router := bunrouter.New(
+		bunrouter.WithNotFoundHandler(notFoundHandler),
+		bunrouter.WithMethodNotAllowedHandler(methodNotAllowedHandler),
+	)
 
 	// Health Check Endpoint godoc
 	//	@Summary		Health check
@@ -363,12 +555,69 @@ func NewServer(gateway *Gateway) *bunrouter.Router {
 		return bunrouter.JSON(w, map[string]string{"message": "Hello, World!"})
 	})
 
-	router.WithGroup("/api/v1/tasks", func(r *bunrouter.Group) {
-		r.GET("", gateway.ListTasksHandler)
-		r.POST("", gateway.CreateTaskHandler)
-		r.GET("/:id", gateway.GetTaskHandler)
-		r.PUT("/:id", gateway.UpdateTaskHandler)
-		r.DELETE("/:id", gateway.DeleteTaskHandler)
+	router.WithGroup("/api/v1", func(v1 *bunrouter.Group) {
+		v1.WithGroup("/auth", func(r *bunrouter.Group) {
+			r.POST("/register", gateway.RegisterHandler)
+			r.POST("/login", gateway.LoginHandler)
+		})
+
+		// everything else needs a logged in user or an API key
+		v1 = v1.Use(gateway.authenticate)
+
+		// managing API keys takes a logged in user, a leaked key must not mint more
+		v1.Use(requireSession).WithGroup("/api-keys", func(r *bunrouter.Group) {
+			r.GET("", gateway.ListAPIKeysHandler)
+			r.POST("", gateway.CreateAPIKeyHandler)
+			r.DELETE("/:id", gateway.RevokeAPIKeyHandler)
+		})
+
+		v1 = v1.Use(requireScope)
+
+		v1.WithGroup("/tasks", func(r *bunrouter.Group) {
+			r.GET("", gateway.ListTasksHandler)
+			r.POST("", gateway.CreateTaskHandler)
+			r.GET("/search", gateway.SearchTasksHandler)
+			r.GET("/events", gateway.WatchTasksHandler)
+			r.GET("/:id", gateway.GetTaskHandler)
+			r.PUT("/:id", gateway.UpdateTaskHandler)
+			r.PATCH("/:id", gateway.PatchTaskHandler)
+			r.DELETE("/:id", gateway.DeleteTaskHandler)
+			r.POST("/:id/complete", gateway.CompleteTaskHandler)
+			r.POST("/:id/reopen", gateway.ReopenTaskHandler)
+			r.GET("/:id/subtasks", gateway.ListSubtasksHandler)
+			r.POST("/:id/subtasks", gateway.CreateSubtaskHandler)
+		})
+
+		v1.WithGroup("/trash", func(r *bunrouter.Group) {
+			r.GET("", gateway.ListTrashHandler)
+			r.POST("/:id/restore", gateway.RestoreTaskHandler)
+			r.DELETE("/:id", gateway.PermanentlyDeleteTaskHandler)
+		})
+
+		v1.WithGroup("/projects", func(r *bunrouter.Group) {
+			r.GET("", gateway.ListProjectsHandler)
+			r.POST("", gateway.CreateProjectHandler)
+			r.GET("/:id", gateway.GetProjectHandler)
+			r.PUT("/:id", gateway.UpdateProjectHandler)
+			r.DELETE("/:id", gateway.DeleteProjectHandler)
+			r.GET("/:id/tasks", gateway.ListProjectTasksHandler)
+		})
+
+		v1.WithGroup("/views", func(r *bunrouter.Group) {
+			r.GET("", gateway.ListSavedViewsHandler)
+			r.POST("", gateway.CreateSavedViewHandler)
+			r.GET("/:id", gateway.GetSavedViewHandler)
+			r.PUT("/:id", gateway.UpdateSavedViewHandler)
+			r.DELETE("/:id", gateway.DeleteSavedViewHandler)
+			r.GET("/:id/tasks", gateway.ExecuteSavedViewHandler)
+		})
+
+		v1.WithGroup("/tags", func(r *bunrouter.Group) {
+			r.GET("", gateway.ListTagsHandler)
+			r.POST("/merge", gateway.MergeTagsHandler)
+			r.PUT("/:name", gateway.RenameTagHandler)
+			r.DELETE("/:name", gateway.DeleteTagHandler)
+		})
 	})
 
 	// OpenAPI documentation
@@ -414,8 +663,27 @@ func main() {
 		gatewayPort = "8080"
 	}
 
+	jwtSecret, secretExists := os.LookupEnv("JWT_SECRET")
+	if !secretExists || jwtSecret == "" {
+		log.Fatal("JWT_SECRET not set in environment")
+	}
+
+	gatewayToken, tokenExists := os.LookupEnv("GATEWAY_TOKEN")
+	if !tokenExists || gatewayToken == "" {
+		log.Fatal("GATEWAY_TOKEN not set in environment")
+	}
+
+	tokenTTL := defaultTokenTTL
+	if raw, ttlExists := os.LookupEnv("JWT_TTL"); ttlExists && raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("JWT_TTL must be a duration such as 24h: %v", err)
+		}
+		tokenTTL = parsed
+	}
+
 	grpcAddress := fmt.Sprintf("%s:%s", grpcServerHost, grpcServerPort)
-	gateway, err := NewGateway(grpcAddress)
+	gateway, err := NewGateway(grpcAddress, []byte(jwtSecret), tokenTTL, gatewayToken)
 	if err != nil {
 		log.Fatalf("Failed to start API Gateway: %v", err)
 	}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+)
+
+// Handles the request to list the direct subtasks of a task
+//
+// ListSubtasks godoc
+//
+//	@Summary		List subtasks
+//	@Description	Fetches the direct subtasks of a task, oldest first
+//	@Tags			tasks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		string	true	"Parent task ID"
+//	@Param			page_size	query		int		false	"Tasks per page, at most 200"
+//	@Param			page_token	query		string	false	"Token of the page to fetch, taken from next_page_token"
+//	@Success		200			{object}	models.TaskListResponse
+//	@Failure		404			{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tasks/{id}/subtasks [get]
+func (g *Gateway) ListSubtasksHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	query := req.URL.Query()
+
+	pageSize, err := parsePageSize(query)
+	if err != nil {
+		return writeBadRequest(w, req, "Invalid query parameters", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.ListSubtasks(ctx, &api.ListSubtasksRequest{
+		ParentId:  req.Param("id"),
+		PageSize:  pageSize,
+		PageToken: query.Get("page_token"),
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to list subtasks", err)
+	}
+
+	return bunrouter.JSON(w, toTaskListResponse(req, resp.Tasks, resp.NextPageToken))
+}
+
+// Handles the request to create a task as a subtask of another one
+//
+// CreateSubtask godoc
+//
+//	@Summary		Create a subtask
+//	@Description	Creates a new task under the given parent task
+//	@Tags			tasks
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string				true	"Parent task ID"
+//	@Param			request	body		models.TaskRequest	true	"Task payload"
+//	@Success		201		{object}	models.TaskResponse
+//	@Failure		400		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tasks/{id}/subtasks [post]
+func (g *Gateway) CreateSubtaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var requestSerializer models.TaskRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&requestSerializer); err != nil {
+		return writeBadRequest(w, req, "Failed to create subtask", err)
+	}
+
+	// the parent always comes from the route
+	requestSerializer.ParentID = req.Param("id")
+
+	createRequest, err := toCreateTaskRequest(requestSerializer)
+	if err != nil {
+		return writeBadRequest(w, req, "Failed to create subtask", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.CreateTask(ctx, createRequest)
+	if err != nil {
+		return writeError(w, req, "Failed to create subtask", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return bunrouter.JSON(w, bunrouter.H{
+		"message": "Subtask created successfully",
+		"data":    serializeTask(resp.Task),
+	})
+}
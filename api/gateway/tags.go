@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+)
+
+// Handles the request to list the caller's tags along with how many tasks carry each
+//
+// ListTags godoc
+//
+//	@Summary		List tags
+//	@Description	Fetches the caller's tags along with the number of their tasks carrying each
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		models.TagResponse
+//	@Failure		503	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tags [get]
+func (g *Gateway) ListTagsHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.ListTags(ctx, &api.ListTagsRequest{})
+	if err != nil {
+		return writeError(w, req, "Failed to list tags", err)
+	}
+
+	tags := make([]models.TagResponse, 0, len(resp.Tags))
+	for _, tag := range resp.Tags {
+		tags = append(tags, serializeTag(tag))
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"tags": tags})
+}
+
+// Handles the request to rename a tag, every task carrying it follows along
+//
+// RenameTag godoc
+//
+//	@Summary		Rename a tag
+//	@Description	Renames a tag across every task carrying it
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path		string					true	"Tag name"
+//	@Param			request	body		models.RenameTagRequest	true	"New tag name"
+//	@Success		200		{object}	models.TagResponse
+//	@Failure		400		{object}	models.Problem
+//	@Failure		404		{object}	models.Problem
+//	@Failure		409		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{name} [put]
+func (g *Gateway) RenameTagHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var requestSerializer models.RenameTagRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&requestSerializer); err != nil {
+		return writeBadRequest(w, req, "Invalid request payload", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.RenameTag(ctx, &api.RenameTagRequest{
+		Name:    req.Param("name"),
+		NewName: requestSerializer.Name,
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to rename tag", err)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"tag": serializeTag(resp.Tag)})
+}
+
+// Handles the request to merge several tags into a single one
+//
+// MergeTags godoc
+//
+//	@Summary		Merge tags
+//	@Description	Moves every task tagged with one of the sources over to the target tag and deletes the sources
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.MergeTagsRequest	true	"Tags to merge"
+//	@Success		200		{object}	models.TagResponse
+//	@Failure		400		{object}	models.Problem
+//	@Failure		404		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tags/merge [post]
+func (g *Gateway) MergeTagsHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var requestSerializer models.MergeTagsRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&requestSerializer); err != nil {
+		return writeBadRequest(w, req, "Invalid request payload", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.MergeTags(ctx, &api.MergeTagsRequest{
+		Sources: requestSerializer.Sources,
+		Target:  requestSerializer.Target,
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to merge tags", err)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"tag": serializeTag(resp.Tag)})
+}
+
+// Handles the request to delete a tag, untagging every task carrying it
+//
+// DeleteTag godoc
+//
+//	@Summary		Delete a tag
+//	@Description	Deletes a tag and removes it from every task
+//	@Tags			tags
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	path	string	true	"Tag name"
+//	@Success		204
+//	@Failure		404	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/tags/{name} [delete]
+func (g *Gateway) DeleteTagHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := g.grpcClient.DeleteTag(ctx, &api.DeleteTagRequest{Name: req.Param("name")}); err != nil {
+		return writeError(w, req, "Failed to delete tag", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
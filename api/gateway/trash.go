@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+)
+
+// Handles the request to list the tasks in the trash
+//
+// ListTrash godoc
+//
+//	@Summary		List the trash
+//	@Description	Fetches the deleted tasks still in the trash, most recently deleted first. Subtasks deleted along with their parent are left out, they are restored along with it.
+//	@Tags			trash
+//	@Accept			json
+//	@Produce		json
+//	@Param			page_size	query		int		false	"Tasks per page, at most 200"
+//	@Param			page_token	query		string	false	"Token of the page to fetch, taken from next_page_token"
+//	@Success		200			{object}	models.TaskListResponse
+//	@Failure		400			{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/trash [get]
+func (g *Gateway) ListTrashHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	query := req.URL.Query()
+
+	pageSize, err := parsePageSize(query)
+	if err != nil {
+		return writeBadRequest(w, req, "Invalid query parameters", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.ListTrash(ctx, &api.ListTrashRequest{
+		PageSize:  pageSize,
+		PageToken: query.Get("page_token"),
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to list the trash", err)
+	}
+
+	return bunrouter.JSON(w, toTaskListResponse(req, resp.Tasks, resp.NextPageToken))
+}
+
+// Handles the request to take a task back out of the trash
+//
+// RestoreTask godoc
+//
+//	@Summary		Restore a task
+//	@Description	Takes a task out of the trash along with its subtasks. A subtask can only be restored once its parent is.
+//	@Tags			trash
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"Task ID"
+//	@Success		200	{object}	models.TaskResponse
+//	@Header			200	{string}	ETag	"The restored task's entity tag"
+//	@Failure		400	{object}	models.Problem
+//	@Failure		404	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/trash/{id}/restore [post]
+func (g *Gateway) RestoreTaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.grpcClient.RestoreTask(ctx, &api.RestoreTaskRequest{Id: req.Param("id")})
+	if err != nil {
+		return writeError(w, req, "Failed to restore task", err)
+	}
+
+	w.Header().Set("ETag", resp.Task.Etag)
+	return bunrouter.JSON(w, bunrouter.H{"task": serializeTask(resp.Task)})
+}
+
+// Handles the request to delete a task in the trash for good
+//
+// PermanentlyDeleteTask godoc
+//
+//	@Summary		Delete a task for good
+//	@Description	Deletes a task in the trash along with its subtasks. There is no way back from this.
+//	@Tags			trash
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	string	true	"Task ID"
+//	@Success		204
+//	@Failure		404	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/trash/{id} [delete]
+func (g *Gateway) PermanentlyDeleteTaskHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	if _, err := g.grpcClient.PermanentlyDeleteTask(ctx, &api.PermanentlyDeleteTaskRequest{Id: req.Param("id")}); err != nil {
+		return writeError(w, req, "Failed to delete task", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
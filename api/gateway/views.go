@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bunrouter"
+)
+
+// Handles the request to list the caller's saved views
+//
+// ListSavedViews godoc
+//
+//	@Summary		List saved views
+//	@Description	Fetches every view the caller saved, by name
+//	@Tags			views
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		models.SavedViewResponse
+//	@Failure		503	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/views [get]
+func (g *Gateway) ListSavedViewsHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.viewClient.ListSavedViews(ctx, &api.ListSavedViewsRequest{})
+	if err != nil {
+		return writeError(w, req, "Failed to list views", err)
+	}
+
+	views := make([]models.SavedViewResponse, 0, len(resp.Views))
+	for _, view := range resp.Views {
+		views = append(views, serializeSavedView(view))
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"views": views})
+}
+
+// Handles the request to save a new view
+//
+// CreateSavedView godoc
+//
+//	@Summary		Save a view
+//	@Description	Saves a filter query, sort order and grouping under a name, to list tasks by later
+//	@Tags			views
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		models.SavedViewRequest	true	"View payload"
+//	@Success		201		{object}	models.SavedViewResponse
+//	@Failure		400		{object}	models.Problem
+//	@Failure		409		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/views [post]
+func (g *Gateway) CreateSavedViewHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var requestSerializer models.SavedViewRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&requestSerializer); err != nil {
+		return writeBadRequest(w, req, "Failed to save view", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.viewClient.CreateSavedView(ctx, &api.CreateSavedViewRequest{
+		Name:     requestSerializer.Name,
+		Query:    requestSerializer.Query,
+		OrderBy:  requestSerializer.OrderBy,
+		GroupBy:  requestSerializer.GroupBy,
+		TimeZone: requestSerializer.TimeZone,
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to save view", err)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return bunrouter.JSON(w, bunrouter.H{
+		"message": "View saved successfully",
+		"data":    serializeSavedView(resp.View),
+	})
+}
+
+// Handles the request to get a specific saved view
+//
+// GetSavedView godoc
+//
+//	@Summary		Get a saved view
+//	@Description	Fetches a saved view by ID
+//	@Tags			views
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"View ID"
+//	@Success		200	{object}	models.SavedViewResponse
+//	@Failure		404	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/views/{id} [get]
+func (g *Gateway) GetSavedViewHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.viewClient.GetSavedView(ctx, &api.GetSavedViewRequest{Id: req.Param("id")})
+	if err != nil {
+		return writeError(w, req, "Failed to fetch view", err)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"view": serializeSavedView(resp.View)})
+}
+
+// Handles the request to update a saved view, replacing every field
+//
+// UpdateSavedView godoc
+//
+//	@Summary		Update a saved view
+//	@Description	Updates the name, query, sort order or grouping of a saved view
+//	@Tags			views
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"View ID"
+//	@Param			request	body		models.SavedViewRequest	true	"Updated View Data"
+//	@Success		200		{object}	models.SavedViewResponse
+//	@Failure		400		{object}	models.Problem
+//	@Failure		404		{object}	models.Problem
+//	@Failure		409		{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/views/{id} [put]
+func (g *Gateway) UpdateSavedViewHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	var requestSerializer models.SavedViewRequest
+
+	if err := json.NewDecoder(req.Body).Decode(&requestSerializer); err != nil {
+		return writeBadRequest(w, req, "Invalid request payload", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	resp, err := g.viewClient.UpdateSavedView(ctx, &api.UpdateSavedViewRequest{
+		Id:       req.Param("id"),
+		Name:     requestSerializer.Name,
+		Query:    requestSerializer.Query,
+		OrderBy:  requestSerializer.OrderBy,
+		GroupBy:  requestSerializer.GroupBy,
+		TimeZone: requestSerializer.TimeZone,
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to update view", err)
+	}
+
+	return bunrouter.JSON(w, bunrouter.H{"view": serializeSavedView(resp.View)})
+}
+
+// Handles the request to delete a saved view, its tasks are left alone
+//
+// DeleteSavedView godoc
+//
+//	@Summary		Delete a saved view
+//	@Description	Deletes a saved view by ID, keeping the tasks it lists
+//	@Tags			views
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path	string	true	"View ID"
+//	@Success		204
+//	@Failure		404	{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/views/{id} [delete]
+func (g *Gateway) DeleteSavedViewHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), 5*time.Second)
+	defer cancel()
+
+	_, err := g.viewClient.DeleteSavedView(ctx, &api.DeleteSavedViewRequest{Id: req.Param("id")})
+	if err != nil {
+		return writeError(w, req, "Failed to delete view", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// Handles the request to run a saved view, listing a page of its tasks
+// along with how many there are in total and in each group
+//
+// ExecuteSavedView godoc
+//
+//	@Summary		Run a saved view
+//	@Description	Fetches a page of the tasks matching a saved view, in its order, with the total count and the count of each group
+//	@Tags			views
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		string	true	"View ID"
+//	@Param			page_size	query		int		false	"Tasks per page, at most 200"
+//	@Param			page_token	query		string	false	"Token of the page to fetch, taken from next_page_token"
+//	@Success		200			{object}	models.SavedViewTasksResponse
+//	@Failure		400			{object}	models.Problem
+//	@Failure		404			{object}	models.Problem
+//	@Security		BearerAuth
+//	@Security		ApiKeyAuth
+//	@Router			/views/{id}/tasks [get]
+func (g *Gateway) ExecuteSavedViewHandler(w http.ResponseWriter, req bunrouter.Request) error {
+	query := req.URL.Query()
+	pageSize, err := parsePageSize(query)
+	if err != nil {
+		return writeBadRequest(w, req, "Invalid query parameters", err)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
+	defer cancel()
+
+	resp, err := g.viewClient.ExecuteSavedView(ctx, &api.ExecuteSavedViewRequest{
+		Id:        req.Param("id"),
+		PageSize:  pageSize,
+		PageToken: query.Get("page_token"),
+	})
+	if err != nil {
+		return writeError(w, req, "Failed to run view", err)
+	}
+
+	return bunrouter.JSON(w, toSavedViewTasksResponse(req, resp))
+}
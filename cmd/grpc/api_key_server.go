@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+)
+
+type APIKeyServiceServer struct {
+	api.UnimplementedAPIKeyServiceServer
+	repo *repository.APIKeyRepository
+}
+
+// Creates new instance of APIKeyServiceServer
+func NewAPIKeyServiceServer(repo *repository.APIKeyRepository) *APIKeyServiceServer {
+	return &APIKeyServiceServer{repo: repo}
+}
+
+// Handles our CreateAPIKey RPC call, generating a key for the caller
+func (s *APIKeyServiceServer) CreateAPIKey(ctx context.Context, req *api.CreateAPIKeyRequest) (*api.CreateAPIKeyResponse, error) {
+	key, secret, err := models.NewAPIKey(req.Name, req.Scopes)
+	if err != nil {
+		return nil, toStatusError(err, "Error creating api key")
+	}
+	key.CreatedAt = time.Now()
+
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, toStatusError(err, "Error creating api key")
+	}
+
+	return &api.CreateAPIKeyResponse{ApiKey: toProtoAPIKey(key), Key: secret}, nil
+}
+
+// Lists the caller's API keys, newest first
+func (s *APIKeyServiceServer) ListAPIKeys(ctx context.Context, req *api.ListAPIKeysRequest) (*api.ListAPIKeysResponse, error) {
+	keys, err := s.repo.ListAPIKeys(ctx)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching api keys")
+	}
+
+	grpcKeys := make([]*api.APIKey, 0, len(keys))
+	for _, key := range keys {
+		grpcKeys = append(grpcKeys, toProtoAPIKey(key))
+	}
+
+	return &api.ListAPIKeysResponse{ApiKeys: grpcKeys}, nil
+}
+
+// Handles our RevokeAPIKey RPC call. Revoked keys stop working right away.
+func (s *APIKeyServiceServer) RevokeAPIKey(ctx context.Context, req *api.RevokeAPIKeyRequest) (*api.RevokeAPIKeyResponse, error) {
+	key, err := s.repo.RevokeAPIKey(ctx, req.Id, time.Now())
+	if err != nil {
+		return nil, toStatusError(err, "Error revoking api key")
+	}
+
+	return &api.RevokeAPIKeyResponse{ApiKey: toProtoAPIKey(key)}, nil
+}
@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/50-Course/notes-tracker/shared/auth"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Turns away every call that does not carry the token our gateway shares
+// with us, then reads the identity the gateway forwards in the x-user-id
+// metadata into the call's context, where our repositories pick it up to
+// scope their queries. Only UserService may be called without a user, as
+// that is where users sign up and log in, though still only by the
+// gateway: AuthenticateAPIKey would otherwise let anyone try keys.
+//
+// The gateway is trusted to have authenticated the user, which is what the
+// token vouches for.
+func identityInterceptor(gatewayToken string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := identify(ctx, gatewayToken, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// The identityInterceptor of streaming calls, i.e. WatchTasks
+func identityStreamInterceptor(gatewayToken string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := identify(stream.Context(), gatewayToken, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &identifiedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// Returns ctx carrying the caller's identity, or an Unauthenticated error
+// when the call does not come from our gateway, or comes without a user
+// and the method requires one
+func identify(ctx context.Context, gatewayToken, fullMethod string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if !fromGateway(md, gatewayToken) {
+		return nil, status.Errorf(codes.Unauthenticated, "%s may only be called by our gateway", fullMethod)
+	}
+
+	if values := md.Get(auth.UserIDMetadataKey); len(values) > 0 && values[0] != "" {
+		return auth.WithUserID(ctx, values[0]), nil
+	}
+	if strings.HasPrefix(fullMethod, "/"+api.UserService_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+	return nil, status.Errorf(codes.Unauthenticated, "%s requires an authenticated user", fullMethod)
+}
+
+// Reports whether the call carries the gateway's token. An empty token
+// never matches, so a service started without one lets nobody in.
+func fromGateway(md metadata.MD, gatewayToken string) bool {
+	values := md.Get(auth.GatewayTokenMetadataKey)
+	if gatewayToken == "" || len(values) != 1 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(values[0]), []byte(gatewayToken)) == 1
+}
+
+// A stream whose handler sees the context identify returned
+type identifiedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identifiedStream) Context() context.Context {
+	return s.ctx
+}
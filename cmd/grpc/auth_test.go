@@ -0,0 +1,59 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/50-Course/notes-tracker/shared/auth"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestIdentify(t *testing.T) {
+	const token = "gateway-token"
+	listTasks := api.TaskService_ListTasks_FullMethodName
+	authenticateAPIKey := api.UserService_AuthenticateAPIKey_FullMethodName
+
+	// an incoming call carrying the given metadata, in pairs of key and value
+	call := func(kv ...string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+	}
+
+	t.Run("Turn Away Calls Not From Our Gateway", func(t *testing.T) {
+		for name, ctx := range map[string]context.Context{
+			"without metadata":   context.Background(),
+			"without the token":  call(auth.UserIDMetadataKey, "alice"),
+			"with another token": call(auth.GatewayTokenMetadataKey, "guess", auth.UserIDMetadataKey, "alice"),
+		} {
+			for _, method := range []string{listTasks, authenticateAPIKey} {
+				if _, err := identify(ctx, token, method); status.Code(err) != codes.Unauthenticated {
+					t.Errorf("Expected a call %s to %s to be Unauthenticated, got %v", name, method, err)
+				}
+			}
+		}
+
+		// a service started without a token lets nobody in
+		if _, err := identify(call(auth.GatewayTokenMetadataKey, ""), "", authenticateAPIKey); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Expected an empty token to match nothing, got %v", err)
+		}
+	})
+
+	t.Run("Read The User Our Gateway Forwards", func(t *testing.T) {
+		ctx, err := identify(call(auth.GatewayTokenMetadataKey, token, auth.UserIDMetadataKey, "alice"), token, listTasks)
+		if err != nil {
+			t.Fatalf("Failed to identify the caller: %v", err)
+		}
+		if userID, _ := auth.UserID(ctx); userID != "alice" {
+			t.Errorf("Expected the call to be made by alice, got %q", userID)
+		}
+
+		if _, err := identify(call(auth.GatewayTokenMetadataKey, token), token, listTasks); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("Expected tasks to require a user, got %v", err)
+		}
+		if _, err := identify(call(auth.GatewayTokenMetadataKey, token), token, authenticateAPIKey); err != nil {
+			t.Errorf("Expected UserService to be callable without a user, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,218 @@
+package grpc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/service"
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bun"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const statusEnumPrefix = "TASK_STATUS_"
+
+// maps our storage model onto its wire representation
+func toProtoTask(task *models.Task) *api.Task {
+	protoTask := &api.Task{
+		Id:          task.ID,
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      toProtoStatus(task.Status),
+		Priority:    api.TaskPriority(task.Priority),
+		CreatedAt:   timestamppb.New(task.CreatedAt),
+		UpdatedAt:   toProtoTimestamp(task.UpdatedAt),
+		CompletedAt: toProtoTimestamp(task.CompletedAt),
+		StartAt:     toProtoTimestamp(task.StartAt),
+		DueAt:       toProtoTimestamp(task.DueAt),
+		DeletedAt:   toProtoTimestamp(task.DeletedAt),
+	}
+
+	protoTask.Tags = models.TagNames(task.Tags)
+	protoTask.ParentId = task.ParentID
+	protoTask.ProjectId = task.ProjectID
+	protoTask.Etag = task.ETag()
+
+	for _, subtask := range task.Subtasks {
+		protoTask.Subtasks = append(protoTask.Subtasks, toProtoTask(subtask))
+	}
+
+	return protoTask
+}
+
+// i.e. "updated" becomes TASK_EVENT_TYPE_UPDATED
+func toProtoTaskEvent(event *models.TaskEvent) *api.TaskEvent {
+	protoEvent := &api.TaskEvent{
+		Id:         strconv.FormatInt(event.ID, 10),
+		Type:       api.TaskEventType(api.TaskEventType_value["TASK_EVENT_TYPE_"+strings.ToUpper(string(event.Type))]),
+		TaskId:     event.TaskID,
+		OccurredAt: timestamppb.New(event.CreatedAt),
+	}
+	if event.Task != nil {
+		protoEvent.Task = toProtoTask(event.Task)
+	}
+
+	return protoEvent
+}
+
+func toProtoTag(tag *models.Tag) *api.Tag {
+	return &api.Tag{
+		Id:        tag.ID,
+		Name:      tag.Name,
+		TaskCount: int32(tag.TaskCount),
+	}
+}
+
+func toProtoProject(project *models.Project) *api.Project {
+	return &api.Project{
+		Id:          project.ID,
+		Name:        project.Name,
+		Description: project.Description,
+		Color:       project.Color,
+		Archived:    project.Archived,
+		CreatedAt:   project.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt:   formatTimestamp(project.UpdatedAt),
+	}
+}
+
+func toProtoSavedView(view *models.SavedView) *api.SavedView {
+	return &api.SavedView{
+		Id:        view.ID,
+		Name:      view.Name,
+		Query:     view.Query,
+		OrderBy:   view.OrderBy,
+		GroupBy:   view.GroupBy,
+		TimeZone:  view.TimeZone,
+		CreatedAt: view.CreatedAt.UTC().Format(time.RFC3339),
+		UpdatedAt: formatTimestamp(view.UpdatedAt),
+	}
+}
+
+func toProtoUser(user *models.User) *api.User {
+	return &api.User{
+		Id:        user.ID,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func toProtoAPIKey(key *models.APIKey) *api.APIKey {
+	return &api.APIKey{
+		Id:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt.UTC().Format(time.RFC3339),
+		LastUsedAt: formatTimestamp(key.LastUsedAt),
+		RevokedAt:  formatTimestamp(key.RevokedAt),
+	}
+}
+
+// Translates a CreateTask request into the fields our service validates
+func createTaskFields(req *api.CreateTaskRequest) (service.TaskFields, error) {
+	startAt, dueAt, err := fromProtoSchedule(req.StartAt, req.DueAt)
+	if err != nil {
+		return service.TaskFields{}, err
+	}
+
+	priority, err := fromProtoPriority(req.Priority)
+	if err != nil {
+		return service.TaskFields{}, err
+	}
+
+	return service.TaskFields{
+		Title:       req.Title,
+		Description: req.Description,
+		Priority:    priority,
+		StartAt:     startAt,
+		DueAt:       dueAt,
+		Tags:        req.Tags,
+		ParentID:    req.ParentId,
+		ProjectID:   req.ProjectId,
+	}, nil
+}
+
+// Translates an UpdateTask request into the fields our service validates
+func updateTaskFields(req *api.UpdateTaskRequest) (service.TaskFields, error) {
+	startAt, dueAt, err := fromProtoSchedule(req.StartAt, req.DueAt)
+	if err != nil {
+		return service.TaskFields{}, err
+	}
+
+	priority, err := fromProtoPriority(req.Priority)
+	if err != nil {
+		return service.TaskFields{}, err
+	}
+
+	return service.TaskFields{
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      fromProtoStatus(req.Status),
+		Priority:    priority,
+		StartAt:     startAt,
+		DueAt:       dueAt,
+		Tags:        req.Tags,
+		ParentID:    req.ParentId,
+		ProjectID:   req.ProjectId,
+	}, nil
+}
+
+// formats an optional timestamp as RFC3339, leaving unset ones empty
+func formatTimestamp(timestamp bun.NullTime) string {
+	if timestamp.IsZero() {
+		return ""
+	}
+	return timestamp.UTC().Format(time.RFC3339)
+}
+
+// converts an optional timestamp onto the wire, leaving unset ones nil
+func toProtoTimestamp(timestamp bun.NullTime) *timestamppb.Timestamp {
+	if timestamp.IsZero() {
+		return nil
+	}
+	return timestamppb.New(timestamp.Time)
+}
+
+// converts an optional timestamp sent by our clients, nil meaning unset
+func fromProtoTimestamp(field string, timestamp *timestamppb.Timestamp) (bun.NullTime, error) {
+	if timestamp == nil {
+		return bun.NullTime{}, nil
+	}
+	if err := timestamp.CheckValid(); err != nil {
+		return bun.NullTime{}, fmt.Errorf("%s is not a valid timestamp: %v", field, err)
+	}
+	return bun.NullTime{Time: timestamp.AsTime()}, nil
+}
+
+// converts the scheduling window of a task; whether it makes sense is up to our service
+func fromProtoSchedule(startAt, dueAt *timestamppb.Timestamp) (start, due bun.NullTime, err error) {
+	if start, err = fromProtoTimestamp("start_at", startAt); err != nil {
+		return
+	}
+	due, err = fromProtoTimestamp("due_at", dueAt)
+	return
+}
+
+// i.e. "in_progress" becomes TASK_STATUS_IN_PROGRESS
+func toProtoStatus(status models.TaskStatus) api.TaskStatus {
+	return api.TaskStatus(api.TaskStatus_value[statusEnumPrefix+strings.ToUpper(string(status))])
+}
+
+// i.e. TASK_STATUS_IN_PROGRESS becomes "in_progress"
+func fromProtoStatus(status api.TaskStatus) models.TaskStatus {
+	if status == api.TaskStatus_TASK_STATUS_UNSPECIFIED {
+		return ""
+	}
+	return models.TaskStatus(strings.ToLower(strings.TrimPrefix(status.String(), statusEnumPrefix)))
+}
+
+// our priority levels share their numbering with the proto enum
+func fromProtoPriority(priority api.TaskPriority) (models.TaskPriority, error) {
+	if p := models.TaskPriority(priority); p.IsValid() {
+		return p, nil
+	}
+	return models.PriorityNone, fmt.Errorf("unknown priority %v", priority)
+}
@@ -0,0 +1,61 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/cmd/service"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// how our domain errors map onto gRPC status codes
+var domainErrorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{repository.ErrNotFound, codes.NotFound},
+	{repository.ErrInvalidArgument, codes.InvalidArgument},
+	{repository.ErrConflict, codes.AlreadyExists},
+	{repository.ErrUnavailable, codes.Unavailable},
+	{repository.ErrStale, codes.Aborted},
+	{repository.ErrCursorExpired, codes.OutOfRange},
+	{models.ErrInvalidTransition, codes.FailedPrecondition},
+	{service.ErrInvalidTask, codes.InvalidArgument},
+	{service.ErrOpenSubtasks, codes.FailedPrecondition},
+	{service.ErrUnauthenticated, codes.Unauthenticated},
+	{models.ErrInvalidTag, codes.InvalidArgument},
+	{models.ErrInvalidProject, codes.InvalidArgument},
+	{models.ErrInvalidUser, codes.InvalidArgument},
+	{models.ErrInvalidAPIKey, codes.InvalidArgument},
+	{models.ErrInvalidView, codes.InvalidArgument},
+	{context.DeadlineExceeded, codes.DeadlineExceeded},
+	{context.Canceled, codes.Canceled},
+}
+
+// Converts an error from our repositories or models into a gRPC status
+// error, prefixing its message with what we were trying to do.
+// Errors that already carry a status keep their code.
+func toStatusError(err error, message string) error {
+	if st, ok := status.FromError(err); ok {
+		return status.Errorf(st.Code(), "%s: %s", message, st.Message())
+	}
+
+	return status.Errorf(statusCode(err), "%s: %v", message, err)
+}
+
+// Reports an invalid request, for input we reject before touching storage
+func invalidArgument(err error, message string) error {
+	return status.Errorf(codes.InvalidArgument, "%s: %v", message, err)
+}
+
+func statusCode(err error) codes.Code {
+	for _, mapping := range domainErrorCodes {
+		if errors.Is(err, mapping.err) {
+			return mapping.code
+		}
+	}
+	return codes.Internal
+}
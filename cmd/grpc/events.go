@@ -0,0 +1,42 @@
+package grpc
+
+import (
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Handles our WatchTasks RPC call, streaming an event for every change to
+// the caller's tasks matching the request until the caller hangs up
+func (s *TaskServiceServer) WatchTasks(req *api.WatchTasksRequest, stream grpc.ServerStreamingServer[api.TaskEvent]) error {
+	filter, err := toTaskFilter(&api.ListTasksRequest{
+		Query:     req.Query,
+		ProjectId: req.ProjectId,
+		TagsAny:   req.TagsAny,
+		TagsAll:   req.TagsAll,
+		TimeZone:  req.TimeZone,
+	}, time.Now())
+	if err != nil {
+		return invalidArgument(err, "Error watching tasks")
+	}
+
+	// headers go out once the watch is accepted, so callers can tell a
+	// rejected watch apart from one waiting on its first event
+	if err := s.tasks.CheckEventCursor(stream.Context(), req.Cursor); err != nil {
+		return toStatusError(err, "Error watching tasks")
+	}
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	err = s.tasks.WatchTasks(stream.Context(), filter, req.Cursor, func(event *models.TaskEvent) error {
+		return stream.Send(toProtoTaskEvent(event))
+	})
+	if err != nil {
+		return toStatusError(err, "Error watching tasks")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/query"
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Translates a ListTasks request into the filter our repository understands.
+// Relative windows such as "today" are resolved against now in the
+// caller's time zone.
+func toTaskFilter(req *api.ListTasksRequest, now time.Time) (repository.TaskFilter, error) {
+	filter := repository.TaskFilter{ProjectID: req.ProjectId}
+
+	location := time.UTC
+	if req.TimeZone != "" {
+		loc, err := time.LoadLocation(req.TimeZone)
+		if err != nil {
+			return filter, fmt.Errorf("unknown time zone %q", req.TimeZone)
+		}
+		location = loc
+	}
+
+	now = now.In(location)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	switch req.DueFilter {
+	case api.DueFilter_DUE_FILTER_UNSPECIFIED:
+	case api.DueFilter_DUE_FILTER_OVERDUE:
+		filter.DueBefore = now
+		filter.OpenOnly = true
+	case api.DueFilter_DUE_FILTER_DUE_TODAY:
+		filter.DueAfter = startOfDay
+		filter.DueBefore = startOfDay.AddDate(0, 0, 1)
+	case api.DueFilter_DUE_FILTER_DUE_THIS_WEEK:
+		// our weeks start on Monday; time.Weekday counts from Sunday
+		daysSinceMonday := (int(now.Weekday()) + 6) % 7
+		startOfWeek := startOfDay.AddDate(0, 0, -daysSinceMonday)
+		filter.DueAfter = startOfWeek
+		filter.DueBefore = startOfWeek.AddDate(0, 0, 7)
+	case api.DueFilter_DUE_FILTER_NO_DUE_DATE:
+		filter.NoDueDate = true
+	default:
+		return filter, fmt.Errorf("unknown due filter %v", req.DueFilter)
+	}
+
+	filter.TitleContains = req.TitleContains
+
+	var err error
+	if filter.TagsAny, err = models.NormalizeTags(req.TagsAny); err != nil {
+		return filter, err
+	}
+	if filter.TagsAll, err = models.NormalizeTags(req.TagsAll); err != nil {
+		return filter, err
+	}
+
+	bounds := []struct {
+		field string
+		value *timestamppb.Timestamp
+		dest  *time.Time
+	}{
+		{"created_after", req.CreatedAfter, &filter.CreatedAfter},
+		{"created_before", req.CreatedBefore, &filter.CreatedBefore},
+		{"updated_after", req.UpdatedAfter, &filter.UpdatedAfter},
+		{"updated_before", req.UpdatedBefore, &filter.UpdatedBefore},
+	}
+	for _, bound := range bounds {
+		parsed, err := fromProtoTimestamp(bound.field, bound.value)
+		if err != nil {
+			return filter, err
+		}
+		*bound.dest = parsed.Time
+	}
+
+	if req.Query != "" {
+		if filter.Query, err = query.Parse(req.Query); err != nil {
+			return filter, err
+		}
+		filter.Today = startOfDay
+	}
+
+	return filter, nil
+}
+
+// Translates the paging and ordering fields of a ListTasks request
+func toTaskPage(req *api.ListTasksRequest) (repository.TaskPage, error) {
+	orderBy, err := repository.ParseOrderBy(req.OrderBy)
+	if err != nil {
+		return repository.TaskPage{}, err
+	}
+
+	return repository.TaskPage{
+		Size:    int(req.PageSize),
+		Token:   req.PageToken,
+		OrderBy: orderBy,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bun"
+)
+
+type ProjectServiceServer struct {
+	api.UnimplementedProjectServiceServer
+	repo *repository.ProjectRepository
+}
+
+// Creates new instance of ProjectServiceServer
+func NewProjectServiceServer(repo *repository.ProjectRepository) *ProjectServiceServer {
+	return &ProjectServiceServer{repo: repo}
+}
+
+// Handles our CreateProject RPC call
+func (s *ProjectServiceServer) CreateProject(ctx context.Context, req *api.CreateProjectRequest) (*api.CreateProjectResponse, error) {
+	project := &models.Project{
+		Name:        req.Name,
+		Description: req.Description,
+		Color:       req.Color,
+		CreatedAt:   time.Now(),
+	}
+	if err := project.Normalize(); err != nil {
+		return nil, invalidArgument(err, "Error creating project")
+	}
+
+	if err := s.repo.CreateProject(ctx, project); err != nil {
+		return nil, toStatusError(err, "Error creating project")
+	}
+
+	return &api.CreateProjectResponse{Project: toProtoProject(project)}, nil
+}
+
+// Handles call to get a specific project
+func (s *ProjectServiceServer) GetProject(ctx context.Context, req *api.GetProjectRequest) (*api.GetProjectResponse, error) {
+	project, err := s.repo.GetProject(ctx, req.Id)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching project")
+	}
+
+	return &api.GetProjectResponse{Project: toProtoProject(project)}, nil
+}
+
+// Lists our projects by name
+func (s *ProjectServiceServer) ListProjects(ctx context.Context, req *api.ListProjectsRequest) (*api.ListProjectsResponse, error) {
+	projects, err := s.repo.ListProjects(ctx, req.IncludeArchived)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching projects")
+	}
+
+	grpcProjects := make([]*api.Project, 0, len(projects))
+	for _, project := range projects {
+		grpcProjects = append(grpcProjects, toProtoProject(project))
+	}
+
+	return &api.ListProjectsResponse{Projects: grpcProjects}, nil
+}
+
+// Handles our UpdateProject RPC call, replacing every field of the project
+func (s *ProjectServiceServer) UpdateProject(ctx context.Context, req *api.UpdateProjectRequest) (*api.UpdateProjectResponse, error) {
+	project, err := s.repo.GetProject(ctx, req.Id)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching project")
+	}
+
+	project.Name = req.Name
+	project.Description = req.Description
+	project.Color = req.Color
+	project.Archived = req.Archived
+	project.UpdatedAt = bun.NullTime{Time: time.Now()}
+	if err := project.Normalize(); err != nil {
+		return nil, invalidArgument(err, "Error updating project")
+	}
+
+	if err := s.repo.UpdateProject(ctx, project); err != nil {
+		return nil, toStatusError(err, "Error updating project")
+	}
+
+	return &api.UpdateProjectResponse{Project: toProtoProject(project)}, nil
+}
+
+// Handles our DeleteProject RPC call. The project's tasks are kept.
+func (s *ProjectServiceServer) DeleteProject(ctx context.Context, req *api.DeleteProjectRequest) (*api.DeleteProjectResponse, error) {
+	if err := s.repo.DeleteProject(ctx, req.Id); err != nil {
+		return nil, toStatusError(err, "Error deleting project")
+	}
+
+	return &api.DeleteProjectResponse{Success: true}, nil
+}
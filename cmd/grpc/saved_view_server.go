@@ -0,0 +1,178 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/cmd/service"
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"github.com/uptrace/bun"
+)
+
+type SavedViewServiceServer struct {
+	api.UnimplementedSavedViewServiceServer
+	repo *repository.SavedViewRepository
+	// runs the views, scoped to the caller like any other task listing
+	tasks *service.TaskService
+}
+
+// Creates new instance of SavedViewServiceServer
+func NewSavedViewServiceServer(repo *repository.SavedViewRepository, tasks repository.TaskStore) *SavedViewServiceServer {
+	return &SavedViewServiceServer{repo: repo, tasks: service.NewTaskService(tasks)}
+}
+
+// Handles our CreateSavedView RPC call
+func (s *SavedViewServiceServer) CreateSavedView(ctx context.Context, req *api.CreateSavedViewRequest) (*api.CreateSavedViewResponse, error) {
+	view := &models.SavedView{
+		Name:      req.Name,
+		Query:     req.Query,
+		OrderBy:   req.OrderBy,
+		GroupBy:   req.GroupBy,
+		TimeZone:  req.TimeZone,
+		CreatedAt: time.Now(),
+	}
+	if err := checkSavedView(view); err != nil {
+		return nil, invalidArgument(err, "Error saving view")
+	}
+
+	if err := s.repo.CreateSavedView(ctx, view); err != nil {
+		return nil, toStatusError(err, "Error saving view")
+	}
+
+	return &api.CreateSavedViewResponse{View: toProtoSavedView(view)}, nil
+}
+
+// Handles call to get a specific saved view
+func (s *SavedViewServiceServer) GetSavedView(ctx context.Context, req *api.GetSavedViewRequest) (*api.GetSavedViewResponse, error) {
+	view, err := s.repo.GetSavedView(ctx, req.Id)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching view")
+	}
+
+	return &api.GetSavedViewResponse{View: toProtoSavedView(view)}, nil
+}
+
+// Lists the caller's saved views by name
+func (s *SavedViewServiceServer) ListSavedViews(ctx context.Context, req *api.ListSavedViewsRequest) (*api.ListSavedViewsResponse, error) {
+	views, err := s.repo.ListSavedViews(ctx)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching views")
+	}
+
+	grpcViews := make([]*api.SavedView, 0, len(views))
+	for _, view := range views {
+		grpcViews = append(grpcViews, toProtoSavedView(view))
+	}
+
+	return &api.ListSavedViewsResponse{Views: grpcViews}, nil
+}
+
+// Handles our UpdateSavedView RPC call, replacing every field of the view
+func (s *SavedViewServiceServer) UpdateSavedView(ctx context.Context, req *api.UpdateSavedViewRequest) (*api.UpdateSavedViewResponse, error) {
+	view, err := s.repo.GetSavedView(ctx, req.Id)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching view")
+	}
+
+	view.Name = req.Name
+	view.Query = req.Query
+	view.OrderBy = req.OrderBy
+	view.GroupBy = req.GroupBy
+	view.TimeZone = req.TimeZone
+	view.UpdatedAt = bun.NullTime{Time: time.Now()}
+	if err := checkSavedView(view); err != nil {
+		return nil, invalidArgument(err, "Error updating view")
+	}
+
+	if err := s.repo.UpdateSavedView(ctx, view); err != nil {
+		return nil, toStatusError(err, "Error updating view")
+	}
+
+	return &api.UpdateSavedViewResponse{View: toProtoSavedView(view)}, nil
+}
+
+// Handles our DeleteSavedView RPC call. The tasks it lists are left alone.
+func (s *SavedViewServiceServer) DeleteSavedView(ctx context.Context, req *api.DeleteSavedViewRequest) (*api.DeleteSavedViewResponse, error) {
+	if err := s.repo.DeleteSavedView(ctx, req.Id); err != nil {
+		return nil, toStatusError(err, "Error deleting view")
+	}
+
+	return &api.DeleteSavedViewResponse{Success: true}, nil
+}
+
+// Runs a saved view, fetching a page of its tasks along with how many
+// there are in total and in each of its groups
+func (s *SavedViewServiceServer) ExecuteSavedView(ctx context.Context, req *api.ExecuteSavedViewRequest) (*api.ExecuteSavedViewResponse, error) {
+	view, err := s.repo.GetSavedView(ctx, req.Id)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching view")
+	}
+
+	listReq := viewListRequest(view)
+	listReq.PageSize = req.PageSize
+	listReq.PageToken = req.PageToken
+
+	// the view was checked when saved, but a time zone can vanish from
+	// the zone database between releases
+	filter, err := toTaskFilter(listReq, time.Now())
+	if err != nil {
+		return nil, toStatusError(err, "Error running view")
+	}
+	page, err := toTaskPage(listReq)
+	if err != nil {
+		return nil, toStatusError(err, "Error running view")
+	}
+
+	tasks, nextPageToken, err := s.tasks.ListTasks(ctx, filter, page)
+	if err != nil {
+		return nil, toStatusError(err, "Error running view")
+	}
+	total, groups, err := s.tasks.CountTasks(ctx, filter, view.GroupBy)
+	if err != nil {
+		return nil, toStatusError(err, "Error running view")
+	}
+
+	grpcTasks := make([]*api.Task, 0, len(tasks))
+	for _, task := range tasks {
+		grpcTasks = append(grpcTasks, toProtoTask(task))
+	}
+	grpcGroups := make([]*api.TaskGroup, 0, len(groups))
+	for _, group := range groups {
+		grpcGroups = append(grpcGroups, &api.TaskGroup{Key: group.Key, Count: int32(group.Count)})
+	}
+
+	return &api.ExecuteSavedViewResponse{
+		View:          toProtoSavedView(view),
+		Tasks:         grpcTasks,
+		NextPageToken: nextPageToken,
+		TotalCount:    int32(total),
+		Groups:        grpcGroups,
+	}, nil
+}
+
+// Checks a view before saving it, down to whether its query, order and
+// time zone would be accepted by ListTasks
+func checkSavedView(view *models.SavedView) error {
+	if err := view.Normalize(); err != nil {
+		return err
+	}
+
+	req := viewListRequest(view)
+	if _, err := toTaskFilter(req, time.Now()); err != nil {
+		return err
+	}
+	_, err := toTaskPage(req)
+	return err
+}
+
+// Builds the ListTasks request a view stands for, so views are run
+// exactly like the listing they were saved from
+func viewListRequest(view *models.SavedView) *api.ListTasksRequest {
+	return &api.ListTasksRequest{
+		Query:    view.Query,
+		OrderBy:  view.OrderBy,
+		TimeZone: view.TimeZone,
+	}
+}
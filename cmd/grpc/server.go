@@ -8,7 +8,7 @@ import (
 	"time"
 
 	"github.com/50-Course/notes-tracker/cmd/repository"
-	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/50-Course/notes-tracker/cmd/service"
 	api "github.com/50-Course/notes-tracker/shared/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
@@ -16,116 +16,193 @@ import (
 
 type TaskServiceServer struct {
 	api.UnimplementedTaskServiceServer
-	repo *repository.TaskRepository
+	tasks *service.TaskService
 }
 
-// Creates new instance of TaskServiceServer
-func NewTaskServiceServer(repo *repository.TaskRepository) *TaskServiceServer {
-	return &TaskServiceServer{repo: repo}
+// Creates new instance of TaskServiceServer, backed by any of our task
+// stores; the database in production, memory in tests
+func NewTaskServiceServer(repo repository.TaskStore) *TaskServiceServer {
+	return &TaskServiceServer{tasks: service.NewTaskService(repo)}
 }
 
 // Handles our CreateTask RPC call for creating tasks
 func (s *TaskServiceServer) CreateTask(ctx context.Context, req *api.CreateTaskRequest) (*api.CreateTaskResponse, error) {
-	if req.Title == "" {
-		return nil, fmt.Errorf("Title is required")
-	}
-
-	task := &models.Task{
-		Title:       req.Title,
-		Description: req.Description,
-		CreatedAt:   time.Now(),
+	fields, err := createTaskFields(req)
+	if err != nil {
+		return nil, invalidArgument(err, "Error creating task")
 	}
 
-	err := s.repo.CreateTask(ctx, task)
+	task, err := s.tasks.CreateTask(ctx, fields)
 	if err != nil {
 		// just propagate that error up our handler
-		return nil, fmt.Errorf("Error creating task: %v", err)
+		return nil, toStatusError(err, "Error creating task")
 	}
 
 	return &api.CreateTaskResponse{
-		Task: &api.Task{
-			Id:          task.ID,
-			Title:       task.Title,
-			Description: task.Description,
-			CreatedAt:   task.CreatedAt.String(),
-		},
+		Task: toProtoTask(task),
 	}, nil
 }
 
-// Handles call to get a specific task
+// Handles call to get a specific task, optionally along with its whole subtree
 func (s *TaskServiceServer) GetTask(ctx context.Context, req *api.GetTaskRequest) (*api.GetTaskResponse, error) {
-	task, err := s.repo.GetTask(ctx, req.Id)
+	task, err := s.tasks.GetTask(ctx, req.Id, req.IncludeSubtree)
 	if err != nil {
-		return nil, fmt.Errorf("Task not found: %w", err)
+		return nil, toStatusError(err, "Error fetching task")
 	}
 
 	return &api.GetTaskResponse{
-		Task: &api.Task{
-			Id:          task.ID,
-			Title:       task.Title,
-			Description: task.Description,
-			CreatedAt:   task.CreatedAt.String(),
-		},
+		Task: toProtoTask(task),
 	}, nil
 }
 
-// Fetches all tasks
+// Fetches a page of the direct subtasks of a task
+func (s *TaskServiceServer) ListSubtasks(ctx context.Context, req *api.ListSubtasksRequest) (*api.ListSubtasksResponse, error) {
+	page := repository.TaskPage{Size: int(req.PageSize), Token: req.PageToken}
+	tasks, nextPageToken, err := s.tasks.ListSubtasks(ctx, req.ParentId, page)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching subtasks")
+	}
+
+	grpcTasks := make([]*api.Task, 0, len(tasks))
+	for _, task := range tasks {
+		grpcTasks = append(grpcTasks, toProtoTask(task))
+	}
+
+	return &api.ListSubtasksResponse{Tasks: grpcTasks, NextPageToken: nextPageToken}, nil
+}
+
+// Fetches a page of tasks matching the request's filters
 func (s *TaskServiceServer) ListTasks(ctx context.Context, req *api.ListTasksRequest) (*api.ListTasksResponse, error) {
-	tasks, err := s.repo.ListTasks(ctx)
+	filter, err := toTaskFilter(req, time.Now())
+	if err != nil {
+		return nil, invalidArgument(err, "Error fetching tasks")
+	}
+
+	page, err := toTaskPage(req)
 	if err != nil {
-		return nil, fmt.Errorf("Error fetching tasks: %v", err)
+		return nil, invalidArgument(err, "Error fetching tasks")
+	}
+
+	tasks, nextPageToken, err := s.tasks.ListTasks(ctx, filter, page)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching tasks")
 	}
 
 	var grpcTasks []*api.Task
 	for _, task := range tasks {
-		grpcTasks = append(grpcTasks, &api.Task{
-			Id:          task.ID,
-			Title:       task.Title,
-			Description: task.Description,
-			CreatedAt:   task.CreatedAt.String(),
-		})
+		grpcTasks = append(grpcTasks, toProtoTask(task))
 	}
 
-	return &api.ListTasksResponse{Tasks: grpcTasks}, nil
+	return &api.ListTasksResponse{Tasks: grpcTasks, NextPageToken: nextPageToken}, nil
 }
 
-// Handles our UpdateTask RPC call for updating tasks
+// Handles our UpdateTask RPC call for updating tasks, either replacing the
+// whole task or only the fields named in its update mask
 func (s *TaskServiceServer) UpdateTask(ctx context.Context, req *api.UpdateTaskRequest) (*api.UpdateTaskResponse, error) {
-	task, err := s.repo.GetTask(ctx, req.Id)
+	fields, err := updateTaskFields(req)
 	if err != nil {
-		return nil, fmt.Errorf("Task not found: %w", err)
+		return nil, invalidArgument(err, "Error updating task")
 	}
 
-	task.Title = req.Title
-	task.Description = req.Description
-
-	err = s.repo.UpdateTask(ctx, task)
+	task, err := s.tasks.UpdateTask(ctx, req.Id, fields, req.UpdateMask.GetPaths(), req.Etag)
 	if err != nil {
-		return nil, fmt.Errorf("Error updating task: %v", err)
+		return nil, toStatusError(err, "Error updating task")
 	}
 
 	return &api.UpdateTaskResponse{
-		Task: &api.Task{
-			Id:          task.ID,
-			Title:       task.Title,
-			Description: task.Description,
-			CreatedAt:   task.CreatedAt.String(),
-		},
+		Task: toProtoTask(task),
 	}, nil
 }
 
 // Handles our RPC call for deleting tasks
 func (s *TaskServiceServer) DeleteTask(ctx context.Context, req *api.DeleteTaskRequest) (*api.DeleteTaskResponse, error) {
-	err := s.repo.DeleteTask(ctx, req.Id)
+	err := s.tasks.DeleteTask(ctx, req.Id, req.Etag)
 	if err != nil {
-		return nil, fmt.Errorf("Error deleting task: %v", err)
+		return nil, toStatusError(err, "Error deleting task")
 	}
 
 	return &api.DeleteTaskResponse{Success: true}, nil
 }
 
+// Handles our SearchTasks RPC call, searching the caller's tasks best match first
+func (s *TaskServiceServer) SearchTasks(ctx context.Context, req *api.SearchTasksRequest) (*api.SearchTasksResponse, error) {
+	page := repository.TaskPage{Size: int(req.PageSize), Token: req.PageToken}
+	matches, nextPageToken, err := s.tasks.SearchTasks(ctx, req.Query, page)
+	if err != nil {
+		return nil, toStatusError(err, "Error searching tasks")
+	}
+
+	grpcMatches := make([]*api.TaskMatch, 0, len(matches))
+	for _, match := range matches {
+		grpcMatches = append(grpcMatches, &api.TaskMatch{
+			Task:           toProtoTask(match.Task),
+			Rank:           match.Rank,
+			TitleHighlight: match.Title,
+			Snippet:        match.Snippet,
+		})
+	}
+
+	return &api.SearchTasksResponse{Matches: grpcMatches, NextPageToken: nextPageToken}, nil
+}
+
+// Lists a page of the caller's trash, most recently deleted first
+func (s *TaskServiceServer) ListTrash(ctx context.Context, req *api.ListTrashRequest) (*api.ListTrashResponse, error) {
+	page := repository.TaskPage{Size: int(req.PageSize), Token: req.PageToken}
+	tasks, nextPageToken, err := s.tasks.ListTrash(ctx, page)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching the trash")
+	}
+
+	grpcTasks := make([]*api.Task, 0, len(tasks))
+	for _, task := range tasks {
+		grpcTasks = append(grpcTasks, toProtoTask(task))
+	}
+
+	return &api.ListTrashResponse{Tasks: grpcTasks, NextPageToken: nextPageToken}, nil
+}
+
+// Handles our RestoreTask RPC call, taking a task out of the trash
+func (s *TaskServiceServer) RestoreTask(ctx context.Context, req *api.RestoreTaskRequest) (*api.RestoreTaskResponse, error) {
+	task, err := s.tasks.RestoreTask(ctx, req.Id)
+	if err != nil {
+		return nil, toStatusError(err, "Error restoring task")
+	}
+
+	return &api.RestoreTaskResponse{Task: toProtoTask(task)}, nil
+}
+
+// Handles our PermanentlyDeleteTask RPC call, deleting a task in the trash for good
+func (s *TaskServiceServer) PermanentlyDeleteTask(ctx context.Context, req *api.PermanentlyDeleteTaskRequest) (*api.PermanentlyDeleteTaskResponse, error) {
+	if err := s.tasks.PermanentlyDeleteTask(ctx, req.Id); err != nil {
+		return nil, toStatusError(err, "Error deleting task")
+	}
+
+	return &api.PermanentlyDeleteTaskResponse{Success: true}, nil
+}
+
+// Handles our CompleteTask RPC call, marking a task as done.
+// Open subtasks are only completed along with it when forced.
+func (s *TaskServiceServer) CompleteTask(ctx context.Context, req *api.CompleteTaskRequest) (*api.CompleteTaskResponse, error) {
+	task, err := s.tasks.CompleteTask(ctx, req.Id, req.Force)
+	if err != nil {
+		return nil, toStatusError(err, "Error completing task")
+	}
+
+	return &api.CompleteTaskResponse{Task: toProtoTask(task)}, nil
+}
+
+// Handles our ReopenTask RPC call, moving a done or cancelled task back to todo
+func (s *TaskServiceServer) ReopenTask(ctx context.Context, req *api.ReopenTaskRequest) (*api.ReopenTaskResponse, error) {
+	task, err := s.tasks.ReopenTask(ctx, req.Id)
+	if err != nil {
+		return nil, toStatusError(err, "Error reopening task")
+	}
+
+	return &api.ReopenTaskResponse{Task: toProtoTask(task)}, nil
+}
+
 // StartServer starts the gRPC server
-func RunGRPCServer(repo *repository.TaskRepository, port string) {
+func RunGRPCServer(repo repository.TaskStore, projects *repository.ProjectRepository, users *repository.UserRepository, apiKeys *repository.APIKeyRepository, views *repository.SavedViewRepository, port, gatewayToken string) {
 	address := fmt.Sprintf(":%s", port)
 	listen, err := net.Listen("tcp", address)
 
@@ -134,8 +211,15 @@ func RunGRPCServer(repo *repository.TaskRepository, port string) {
 		log.Fatalf("[gRPC] Failed to start GRPC server on %s: %v", address, err)
 	}
 
-	server := grpc.NewServer()
-	api.RegisterTaskServiceServer(server, &TaskServiceServer{repo: repo})
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(identityInterceptor(gatewayToken)),
+		grpc.StreamInterceptor(identityStreamInterceptor(gatewayToken)),
+	)
+	api.RegisterTaskServiceServer(server, NewTaskServiceServer(repo))
+	api.RegisterProjectServiceServer(server, &ProjectServiceServer{repo: projects})
+	api.RegisterUserServiceServer(server, &UserServiceServer{repo: users, apiKeys: apiKeys})
+	api.RegisterAPIKeyServiceServer(server, &APIKeyServiceServer{repo: apiKeys})
+	api.RegisterSavedViewServiceServer(server, NewSavedViewServiceServer(views, repo))
 
 	reflection.Register(server)
 
@@ -0,0 +1,51 @@
+package grpc
+
+import (
+	"context"
+
+	api "github.com/50-Course/notes-tracker/shared/proto"
+)
+
+// Handles our ListTags RPC call, listing the caller's tags with their usage
+func (s *TaskServiceServer) ListTags(ctx context.Context, req *api.ListTagsRequest) (*api.ListTagsResponse, error) {
+	tags, err := s.tasks.ListTags(ctx)
+	if err != nil {
+		return nil, toStatusError(err, "Error fetching tags")
+	}
+
+	grpcTags := make([]*api.Tag, 0, len(tags))
+	for _, tag := range tags {
+		grpcTags = append(grpcTags, toProtoTag(tag))
+	}
+
+	return &api.ListTagsResponse{Tags: grpcTags}, nil
+}
+
+// Handles our RenameTag RPC call
+func (s *TaskServiceServer) RenameTag(ctx context.Context, req *api.RenameTagRequest) (*api.RenameTagResponse, error) {
+	tag, err := s.tasks.RenameTag(ctx, req.Name, req.NewName)
+	if err != nil {
+		return nil, toStatusError(err, "Error renaming tag")
+	}
+
+	return &api.RenameTagResponse{Tag: toProtoTag(tag)}, nil
+}
+
+// Handles our MergeTags RPC call
+func (s *TaskServiceServer) MergeTags(ctx context.Context, req *api.MergeTagsRequest) (*api.MergeTagsResponse, error) {
+	tag, err := s.tasks.MergeTags(ctx, req.Sources, req.Target)
+	if err != nil {
+		return nil, toStatusError(err, "Error merging tags")
+	}
+
+	return &api.MergeTagsResponse{Tag: toProtoTag(tag)}, nil
+}
+
+// Handles our DeleteTag RPC call, untagging every task carrying it
+func (s *TaskServiceServer) DeleteTag(ctx context.Context, req *api.DeleteTagRequest) (*api.DeleteTagResponse, error) {
+	if err := s.tasks.DeleteTag(ctx, req.Name); err != nil {
+		return nil, toStatusError(err, "Error deleting tag")
+	}
+
+	return &api.DeleteTagResponse{Success: true}, nil
+}
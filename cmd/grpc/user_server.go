@@ -0,0 +1,91 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/shared/models"
+	api "github.com/50-Course/notes-tracker/shared/proto"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// compared against when the email is unknown, so a failed login takes as
+// long whether or not the account exists
+var decoyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("decoy password"), bcrypt.DefaultCost)
+
+type UserServiceServer struct {
+	api.UnimplementedUserServiceServer
+	repo    *repository.UserRepository
+	apiKeys *repository.APIKeyRepository
+}
+
+// Creates new instance of UserServiceServer
+func NewUserServiceServer(repo *repository.UserRepository, apiKeys *repository.APIKeyRepository) *UserServiceServer {
+	return &UserServiceServer{repo: repo, apiKeys: apiKeys}
+}
+
+// Handles our RegisterUser RPC call, signing a new user up
+func (s *UserServiceServer) RegisterUser(ctx context.Context, req *api.RegisterUserRequest) (*api.RegisterUserResponse, error) {
+	email, err := models.NormalizeEmail(req.Email)
+	if err != nil {
+		return nil, invalidArgument(err, "Error registering user")
+	}
+
+	user := &models.User{Email: email, CreatedAt: time.Now()}
+	if err := user.SetPassword(req.Password); err != nil {
+		return nil, toStatusError(err, "Error registering user")
+	}
+
+	if err := s.repo.CreateUser(ctx, user); err != nil {
+		return nil, toStatusError(err, "Error registering user")
+	}
+
+	return &api.RegisterUserResponse{User: toProtoUser(user)}, nil
+}
+
+// Handles our Authenticate RPC call, checking the user's credentials.
+// Unknown emails and wrong passwords are reported the same way.
+func (s *UserServiceServer) Authenticate(ctx context.Context, req *api.AuthenticateRequest) (*api.AuthenticateResponse, error) {
+	email, err := models.NormalizeEmail(req.Email)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Invalid email or password")
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(decoyPasswordHash, []byte(req.Password))
+		return nil, status.Error(codes.Unauthenticated, "Invalid email or password")
+	}
+	if err != nil {
+		return nil, toStatusError(err, "Error authenticating user")
+	}
+
+	if !user.CheckPassword(req.Password) {
+		return nil, status.Error(codes.Unauthenticated, "Invalid email or password")
+	}
+
+	return &api.AuthenticateResponse{User: toProtoUser(user)}, nil
+}
+
+// Handles our AuthenticateAPIKey RPC call, resolving a key to its user and
+// recording that it was used. Unknown and revoked keys look the same.
+func (s *UserServiceServer) AuthenticateAPIKey(ctx context.Context, req *api.AuthenticateAPIKeyRequest) (*api.AuthenticateAPIKeyResponse, error) {
+	key, err := s.apiKeys.UseAPIKey(ctx, models.HashAPIKey(req.Key), time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "Invalid api key")
+	}
+	if err != nil {
+		return nil, toStatusError(err, "Error authenticating api key")
+	}
+
+	user, err := s.repo.GetUser(ctx, key.UserID)
+	if err != nil {
+		return nil, toStatusError(err, "Error authenticating api key")
+	}
+
+	return &api.AuthenticateAPIKeyResponse{User: toProtoUser(user), Scopes: key.Scopes}, nil
+}
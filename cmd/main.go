@@ -1,31 +1,38 @@
 package main
 
 import (
-	_ "context"
+	"context"
 	_ "database/sql"
+	"flag"
 	_ "fmt"
 	"log"
 	_ "net"
 	"os"
+	"time"
 
 	grpcserver "github.com/50-Course/notes-tracker/cmd/grpc"
 	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/cmd/service"
 	"github.com/50-Course/notes-tracker/scripts/migrations"
 	_ "github.com/50-Course/notes-tracker/shared/proto"
 	"github.com/50-Course/notes-tracker/shared/utils"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 	_ "github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 	_ "github.com/uptrace/bun/dialect/pgdialect"
 	_ "github.com/uptrace/bun/driver/pgdriver"
 )
 
 func main() {
+	// production runs `migrate up` as its own step before rolling out the core
+	skipMigrations := flag.Bool("skip-migrations", os.Getenv("SKIP_MIGRATIONS") == "true", "do not apply pending migrations on startup, defaults to $SKIP_MIGRATIONS")
+	flag.Parse()
+
 	if err := godotenv.Load("config/.env"); err != nil {
 		log.Fatal("Error loading .env file. Please confirm file exists in the right file path and try again.")
 	}
 
-
 	internalServerPort, exists := os.LookupEnv("INTERNAL_SERVER_PORT")
 	if !exists {
 		log.Printf("INTERNAL_SERVER_PORT not set in environment. Defaulting to 50051")
@@ -33,20 +40,74 @@ func main() {
 		internalServerPort = "50051"
 	}
 
-	dbUrl := utils.BuildDatabaseURL()
-	db, err := utils.ConnectToDB(dbUrl)
+	// only our gateway, which sends the same token, gets to call us
+	gatewayToken, exists := os.LookupEnv("GATEWAY_TOKEN")
+	if !exists || gatewayToken == "" {
+		log.Fatal("GATEWAY_TOKEN not set in environment")
+	}
+
+	// postgres unless DB_DRIVER=sqlite
+	db, err := utils.ConnectFromEnv()
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// run migrations
-	log.Println("[Startup] Running database migrations...")
-	if err := migrations.RunMigrations(db); err != nil {
-		log.Fatalf("[Startup] Migrations failed: %v", err)
+	if *skipMigrations {
+		log.Println("[Startup] Skipping database migrations, apply them with the migrate command")
+	} else {
+		log.Println("[Startup] Running database migrations...")
+		if err := migrations.RunMigrations(db); err != nil {
+			log.Fatalf("[Startup] Migrations failed: %v", err)
+		}
+	}
+
+	// tasks without an owner are hidden from everyone, which we do not do
+	// quietly. We still start, as nothing else is wrong.
+	if err := migrations.CheckOwners(context.Background(), db); err != nil {
+		log.Printf("[Startup] Warning: %v", err)
 	}
 
 	// we would then initialize our grpc server here
 	repo := repository.NewTaskRepository(db)
-	grpcserver.RunGRPCServer(repo, internalServerPort)
+	projects := repository.NewProjectRepository(db)
+	users := repository.NewUserRepository(db)
+	apiKeys := repository.NewAPIKeyRepository(db)
+	views := repository.NewSavedViewRepository(db)
+
+	// deleted tasks sit in the trash for TRASH_RETENTION, checked on every TRASH_PURGE_INTERVAL
+	trashRetention := durationFromEnv("TRASH_RETENTION", service.DefaultTrashRetention)
+	purgeInterval := durationFromEnv("TRASH_PURGE_INTERVAL", time.Hour)
+	go service.NewTaskService(repo).RunTrashPurge(context.Background(), trashRetention, purgeInterval)
+
+	// watchers can resume from events up to TASK_EVENT_RETENTION old
+	eventRetention := durationFromEnv("TASK_EVENT_RETENTION", service.DefaultEventRetention)
+	go service.NewTaskService(repo).RunEventPrune(context.Background(), eventRetention, time.Hour)
+
+	// on Postgres, watchers hear of the events of every core instance as they happen
+	if db.Dialect().Name() == dialect.PG {
+		go func() {
+			if err := repo.ListenForEvents(context.Background(), utils.BuildDatabaseURL()); err != nil {
+				log.Printf("[Events] Stopped listening for task events: %v", err)
+			}
+		}()
+	}
+
+	grpcserver.RunGRPCServer(repo, projects, users, apiKeys, views, internalServerPort, gatewayToken)
 	log.Printf("gRPC Server started on port %s", internalServerPort)
 }
+
+// Reads a duration such as 720h from the environment, falling back to
+// fallback when it is not set
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	raw, exists := os.LookupEnv(key)
+	if !exists || raw == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(raw)
+	if err != nil || parsed <= 0 {
+		log.Fatalf("%s must be a positive duration such as %s, got %q", key, fallback, raw)
+	}
+	return parsed
+}
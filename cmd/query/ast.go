@@ -0,0 +1,150 @@
+// Package query parses the filter language our task lists and saved views
+// take, i.e. `status:open tag:backend due<2026-11-01 priority>=high "login bug"`,
+// into a typed syntax tree. Storage compiles the tree into its own queries.
+package query
+
+import (
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+)
+
+// A node of a parsed query, one of And, Or, Not, Comparison or Text
+type Node interface {
+	// where the node starts in the query, counting characters from 1
+	Pos() int
+	node()
+}
+
+// Matches tasks matching every one of its terms, written next to each
+// other or joined with AND
+type And struct {
+	Position int
+	Terms    []Node
+}
+
+// Matches tasks matching any one of its terms, joined with OR
+type Or struct {
+	Position int
+	Terms    []Node
+}
+
+// Matches tasks not matching its term, written as -term or NOT term
+type Not struct {
+	Position int
+	Term     Node
+}
+
+// Compares a field of a task against a value, i.e. priority>=high
+type Comparison struct {
+	Position int
+	Field    Field
+	Op       Operator
+	// typed by the field: StatusValue, PriorityValue, DateValue, NoneValue
+	// or StringValue
+	Value Value
+}
+
+// Matches tasks with the text somewhere in their title or description,
+// regardless of case. Quoting keeps the words of a phrase together.
+type Text struct {
+	Position int
+	Text     string
+}
+
+func (n *And) Pos() int        { return n.Position }
+func (n *Or) Pos() int         { return n.Position }
+func (n *Not) Pos() int        { return n.Position }
+func (n *Comparison) Pos() int { return n.Position }
+func (n *Text) Pos() int       { return n.Position }
+
+func (*And) node()        {}
+func (*Or) node()         {}
+func (*Not) node()        {}
+func (*Comparison) node() {}
+func (*Text) node()       {}
+
+// A field of a task that can be compared against
+type Field string
+
+const (
+	FieldStatus   Field = "status"
+	FieldPriority Field = "priority"
+	FieldTag      Field = "tag"
+	FieldDue      Field = "due"
+	FieldCreated  Field = "created"
+	FieldUpdated  Field = "updated"
+	// case-insensitive substring match, like Text but on the title alone
+	FieldTitle   Field = "title"
+	FieldProject Field = "project"
+)
+
+// Reports whether the field holds a date
+func (f Field) IsDate() bool {
+	return f == FieldDue || f == FieldCreated || f == FieldUpdated
+}
+
+type Operator string
+
+const (
+	// written as either : or =
+	OpEqual        Operator = ":"
+	OpNotEqual     Operator = "!="
+	OpLess         Operator = "<"
+	OpLessEqual    Operator = "<="
+	OpGreater      Operator = ">"
+	OpGreaterEqual Operator = ">="
+)
+
+// Reports whether the operator orders its operands, rather than just
+// telling them apart
+func (o Operator) IsOrdering() bool {
+	return o != OpEqual && o != OpNotEqual
+}
+
+// The value of a Comparison
+type Value interface {
+	value()
+}
+
+// One or more statuses, as open and closed stand for several
+type StatusValue []models.TaskStatus
+
+type PriorityValue models.TaskPriority
+
+// A calendar day, either a fixed one or one relative to the day the query
+// runs on, so saved queries like due<today keep up with the calendar
+type DateValue struct {
+	// the fixed day, or zero for days relative to today
+	Year  int
+	Month time.Month
+	Day   int
+	// how far from today a relative day lies, i.e. -1 for yesterday
+	DaysFromToday int
+}
+
+// Reports whether the day is relative to today
+func (d DateValue) IsRelative() bool {
+	return d.Year == 0
+}
+
+// Works out the start of the day, given the start of today in the time
+// zone the query runs in. Fixed days are taken in that same time zone.
+func (d DateValue) Resolve(today time.Time) time.Time {
+	if d.IsRelative() {
+		return today.AddDate(0, 0, d.DaysFromToday)
+	}
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, today.Location())
+}
+
+// Stands for a date that is not set, i.e. due:none
+type NoneValue struct{}
+
+// A tag name, a project id or the text to look for in titles
+type StringValue string
+
+func (StatusValue) value()   {}
+func (PriorityValue) value() {}
+func (DateValue) value()     {}
+func (NoneValue) value()     {}
+func (StringValue) value()   {}
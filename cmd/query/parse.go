@@ -0,0 +1,374 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/google/uuid"
+)
+
+// Longest query we bother parsing, well past anything typed by hand
+const MaxQueryLength = 1000
+
+// Reports where in a query parsing it failed
+type Error struct {
+	// counting characters from 1
+	Position int
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Position, e.Message)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	// a "quoted string", its text unquoted
+	tokenString
+	tokenOperator
+	tokenMinus
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// counting characters from 1
+	position int
+	// whether whitespace separates the token from the one before it
+	spaced bool
+}
+
+// characters that end a bare word
+const delimiters = `()":=<>!`
+
+// Splits the query into its tokens
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	// the position of input[i], counting characters from 1
+	position := func(i int) int { return utf8.RuneCountInString(input[:i]) + 1 }
+
+	spaced := true
+	for i := 0; i < len(input); {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		if unicode.IsSpace(r) {
+			spaced = true
+			i += size
+			continue
+		}
+
+		t := token{position: position(i), spaced: spaced}
+		spaced = false
+		switch {
+		case r == '(':
+			t.kind, t.text = tokenOpen, "("
+			i++
+		case r == ')':
+			t.kind, t.text = tokenClose, ")"
+			i++
+		case r == '"':
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return nil, &Error{t.position, "unterminated quote"}
+			}
+			t.kind, t.text = tokenString, input[i+1:i+1+end]
+			i += end + 2
+		case strings.ContainsRune(":=<>!", r):
+			t.kind = tokenOperator
+			t.text = input[i : i+1]
+			if i+1 < len(input) && input[i+1] == '=' && r != ':' && r != '=' {
+				t.text = input[i : i+2]
+			}
+			if t.text == "!" {
+				return nil, &Error{t.position, `expected != after "!"`}
+			}
+			i += len(t.text)
+		// a minus negates the term it is stuck to, elsewhere it is part of a word
+		case r == '-' && t.spaced && i+1 < len(input) && !unicode.IsSpace(rune(input[i+1])):
+			t.kind, t.text = tokenMinus, "-"
+			i++
+		default:
+			end := strings.IndexFunc(input[i:], func(r rune) bool {
+				return unicode.IsSpace(r) || strings.ContainsRune(delimiters, r)
+			})
+			if end < 0 {
+				end = len(input) - i
+			}
+			t.kind, t.text = tokenWord, input[i:i+end]
+			i += end
+		}
+		tokens = append(tokens, t)
+	}
+
+	return append(tokens, token{kind: tokenEOF, position: position(len(input)), spaced: true}), nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// Reports whether the next token is the given keyword. Keywords are upper
+// case only, so "or" on its own is still searched for.
+func (p *parser) atKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && t.text == keyword
+}
+
+// Parses a query, returning an *Error pointing at the culprit when it is
+// malformed. Terms are matched in full unless joined with OR; AND binds
+// tighter than OR and parentheses group terms, i.e.
+//
+//	status:open (tag:backend OR tag:api) -tag:wontfix
+//
+// Comparisons take a field, an operator out of : = != < <= > >= and a
+// value, quoted if it has spaces in it:
+//
+//	status   todo, in_progress, blocked, done, cancelled, open or closed
+//	priority none, low, medium, high or urgent, ordered in that sequence
+//	tag      a tag name
+//	due, created, updated
+//	         a day such as 2026-11-01, today, yesterday or tomorrow, or none
+//	title    text the title contains
+//	project  a project id
+//
+// Anything else is text to look for in titles and descriptions.
+func Parse(input string) (Node, error) {
+	if utf8.RuneCountInString(input) > MaxQueryLength {
+		return nil, &Error{MaxQueryLength + 1, fmt.Sprintf("queries are limited to %d characters", MaxQueryLength)}
+	}
+
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, &Error{1, "the query is empty"}
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &Error{t.position, fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return node, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := []Node{first}
+	for p.atKeyword("OR") {
+		p.advance()
+		term, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &Or{Position: first.Pos(), Terms: terms}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := []Node{first}
+	for {
+		t := p.peek()
+		if t.kind == tokenEOF || t.kind == tokenClose || p.atKeyword("OR") {
+			break
+		}
+		if p.atKeyword("AND") {
+			p.advance()
+		}
+		term, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &And{Position: first.Pos(), Terms: terms}, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if t := p.peek(); t.kind == tokenMinus || p.atKeyword("NOT") {
+		p.advance()
+		term, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Position: t.position, Term: term}, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *parser) parseTerm() (Node, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokenOpen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.peek(); closing.kind != tokenClose {
+			return nil, &Error{closing.position, fmt.Sprintf("expected ) to close the ( at position %d", t.position)}
+		}
+		p.advance()
+		return node, nil
+	case tokenString:
+		if strings.TrimSpace(t.text) == "" {
+			return nil, &Error{t.position, "expected text between the quotes"}
+		}
+		return &Text{Position: t.position, Text: t.text}, nil
+	case tokenWord:
+		if operator := p.peek(); operator.kind == tokenOperator && !operator.spaced {
+			return p.parseComparison(t)
+		}
+		if t.text == "AND" || t.text == "OR" {
+			return nil, &Error{t.position, fmt.Sprintf("expected a term before %s", t.text)}
+		}
+		return &Text{Position: t.position, Text: t.text}, nil
+	case tokenOperator:
+		return nil, &Error{t.position, fmt.Sprintf("expected a field before %q", t.text)}
+	case tokenEOF:
+		return nil, &Error{t.position, "unexpected end of query, expected a term"}
+	default:
+		return nil, &Error{t.position, fmt.Sprintf("unexpected %q", t.text)}
+	}
+}
+
+var fields = map[string]Field{
+	"status":   FieldStatus,
+	"priority": FieldPriority,
+	"tag":      FieldTag,
+	"due":      FieldDue,
+	"created":  FieldCreated,
+	"updated":  FieldUpdated,
+	"title":    FieldTitle,
+	"project":  FieldProject,
+}
+
+// Parses field, operator and value into a comparison, given the field
+func (p *parser) parseComparison(name token) (Node, error) {
+	field, ok := fields[strings.ToLower(name.text)]
+	if !ok {
+		return nil, &Error{name.position, fmt.Sprintf("unknown field %q, expected one of status, priority, tag, due, created, updated, title or project", name.text)}
+	}
+
+	operator := p.advance()
+	op := Operator(operator.text)
+	if op == "=" {
+		op = OpEqual
+	}
+
+	raw := p.advance()
+	if (raw.kind != tokenWord && raw.kind != tokenString) || raw.spaced {
+		return nil, &Error{raw.position, fmt.Sprintf("expected a value after %s%s", name.text, operator.text)}
+	}
+
+	value, err := parseValue(field, raw.text)
+	if err != nil {
+		return nil, &Error{raw.position, err.Error()}
+	}
+	if _, none := value.(NoneValue); none && op.IsOrdering() {
+		return nil, &Error{operator.position, fmt.Sprintf("%s:none only takes : or !=", field)}
+	}
+	if op.IsOrdering() && !field.IsDate() && field != FieldPriority {
+		return nil, &Error{operator.position, fmt.Sprintf("%s only takes : or !=", field)}
+	}
+	if op == OpNotEqual && field == FieldTitle {
+		return nil, &Error{operator.position, "title only takes :, negate it with -title: instead"}
+	}
+
+	return &Comparison{Position: name.position, Field: field, Op: op, Value: value}, nil
+}
+
+var relativeDays = map[string]int{"yesterday": -1, "today": 0, "tomorrow": 1}
+
+// Types the value of a comparison by the field it is compared against
+func parseValue(field Field, raw string) (Value, error) {
+	switch field {
+	case FieldStatus:
+		switch status := models.TaskStatus(strings.ToLower(raw)); {
+		case status == "open":
+			return StatusValue{models.StatusTodo, models.StatusInProgress, models.StatusBlocked}, nil
+		case status == "closed":
+			return StatusValue{models.StatusDone, models.StatusCancelled}, nil
+		case status.IsValid():
+			return StatusValue{status}, nil
+		}
+		return nil, fmt.Errorf("unknown status %q, expected one of todo, in_progress, blocked, done, cancelled, open or closed", raw)
+	case FieldPriority:
+		priority, err := models.ParsePriority(strings.ToLower(raw))
+		if err != nil {
+			return nil, err
+		}
+		return PriorityValue(priority), nil
+	case FieldTag:
+		tag, err := models.NormalizeTag(raw)
+		if err != nil {
+			return nil, err
+		}
+		return StringValue(tag), nil
+	case FieldDue, FieldCreated, FieldUpdated:
+		raw = strings.ToLower(raw)
+		if raw == "none" {
+			return NoneValue{}, nil
+		}
+		if days, ok := relativeDays[raw]; ok {
+			return DateValue{DaysFromToday: days}, nil
+		}
+		day, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected a day such as 2026-11-01, today, yesterday, tomorrow or none", raw)
+		}
+		return DateValue{Year: day.Year(), Month: day.Month(), Day: day.Day()}, nil
+	case FieldProject:
+		if _, err := uuid.Parse(raw); err != nil {
+			return nil, fmt.Errorf("invalid project id %q", raw)
+		}
+		return StringValue(raw), nil
+	default:
+		if strings.TrimSpace(raw) == "" {
+			return nil, fmt.Errorf("%s must not be empty", field)
+		}
+		return StringValue(raw), nil
+	}
+}
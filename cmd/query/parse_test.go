@@ -0,0 +1,107 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Writes the tree back out fully parenthesized, so tests can compare trees
+func format(node Node) string {
+	switch n := node.(type) {
+	case *And:
+		return formatTerms("AND", n.Terms)
+	case *Or:
+		return formatTerms("OR", n.Terms)
+	case *Not:
+		return "NOT " + format(n.Term)
+	case *Text:
+		return fmt.Sprintf("%q", n.Text)
+	case *Comparison:
+		if day, ok := n.Value.(DateValue); ok && day.IsRelative() {
+			return fmt.Sprintf("%s%stoday%+d", n.Field, n.Op, day.DaysFromToday)
+		}
+		if day, ok := n.Value.(DateValue); ok {
+			return fmt.Sprintf("%s%s%04d-%02d-%02d", n.Field, n.Op, day.Year, day.Month, day.Day)
+		}
+		return fmt.Sprintf("%s%s%v", n.Field, n.Op, n.Value)
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func formatTerms(op string, terms []Node) string {
+	formatted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		formatted = append(formatted, format(term))
+	}
+	return "(" + strings.Join(formatted, " "+op+" ") + ")"
+}
+
+func TestParse(t *testing.T) {
+	t.Run("Parse Queries", func(t *testing.T) {
+		queries := map[string]string{
+			`status:open tag:backend due<2026-11-01 priority>=high "login bug"`: `(status:[todo in_progress blocked] AND tag:backend AND due<2026-11-01 AND priority>=3 AND "login bug")`,
+			`Status=DONE AND tag:API`:                       `(status:[done] AND tag:api)`,
+			`(tag:a OR tag:b) -tag:c`:                       `((tag:a OR tag:b) AND NOT tag:c)`,
+			`tag:a OR tag:b tag:c`:                          `(tag:a OR (tag:b AND tag:c))`,
+			`NOT due:none updated>=yesterday`:               `(NOT due:{} AND updated>=today-1)`,
+			`title:"sprint review" or`:                      `(title:sprint review AND "or")`,
+			`project!=5f0c2a9e-1b7d-4e36-8a51-0d9c3e7b4f12`: `project!=5f0c2a9e-1b7d-4e36-8a51-0d9c3e7b4f12`,
+			`follow-up`: `"follow-up"`,
+		}
+		for input, expected := range queries {
+			node, err := Parse(input)
+			if err != nil {
+				t.Errorf("Failed to parse %s: %v", input, err)
+				continue
+			}
+			if got := format(node); got != expected {
+				t.Errorf("Expected %s to parse into %s, got %s", input, expected, got)
+			}
+		}
+	})
+
+	t.Run("Point At Errors", func(t *testing.T) {
+		queries := map[string]int{
+			``:                       1,
+			`status:opne`:            8,
+			`tag:a (tag:b`:           13,
+			`due<soon`:               5,
+			`estimate>3`:             1,
+			`tag<b`:                  4,
+			`"login bug`:             1,
+			`tag:a OR`:               9,
+			`AND tag:a`:              1,
+			`priority: high`:         11,
+			`tag:a)`:                 6,
+			`due>none`:               4,
+			`title!=draft`:           6,
+			`café project:not-a-uid`: 14,
+		}
+		for input, position := range queries {
+			_, err := Parse(input)
+			var parseErr *Error
+			if !errors.As(err, &parseErr) {
+				t.Errorf("Expected a parse error for %s, got %v", input, err)
+				continue
+			}
+			if parseErr.Position != position {
+				t.Errorf("Expected the error for %s at position %d, got %v", input, position, err)
+			}
+		}
+	})
+
+	t.Run("Resolve Days", func(t *testing.T) {
+		today := time.Date(2026, 10, 16, 0, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+
+		if day := (DateValue{DaysFromToday: -1}).Resolve(today); !day.Equal(today.AddDate(0, 0, -1)) {
+			t.Errorf("Expected yesterday to be the day before %v, got %v", today, day)
+		}
+		fixed := DateValue{Year: 2026, Month: time.November, Day: 1}
+		if day := fixed.Resolve(today); day.Location() != today.Location() || day.Day() != 1 || day.Hour() != 0 {
+			t.Errorf("Expected the start of November 1st in the caller's time zone, got %v", day)
+		}
+	})
+}
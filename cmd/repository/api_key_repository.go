@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/auth"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// How stale last_used_at may get before we bother writing it again, so a
+// busy bot does not turn every request into an UPDATE
+const LastUsedResolution = time.Minute
+
+type APIKeyRepository struct {
+	db *bun.DB
+}
+
+func NewAPIKeyRepository(db *bun.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Stores a new key for the user making the request
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	key.ID = uuid.New().String()
+	if userID, ok := auth.UserID(ctx); ok {
+		key.UserID = userID
+	}
+
+	_, err := r.db.NewInsert().Model(key).Exec(ctx)
+	return translateError(err)
+}
+
+// Lists the keys of the user making the request, newest first, revoked ones included
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+
+	err := r.db.NewSelect().
+		Model(&keys).
+		ApplyQueryBuilder(ownedBy(ctx, "k.user_id")).
+		Order("k.created_at DESC", "k.id ASC").
+		Scan(ctx)
+	return keys, translateError(err)
+}
+
+// Revokes the key. Revoking it again keeps the original revocation time.
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id string, now time.Time) (*models.APIKey, error) {
+	if err := checkUUID(id); err != nil {
+		return nil, err
+	}
+
+	key := new(models.APIKey)
+
+	_, err := r.db.NewUpdate().
+		Model(key).
+		Set("revoked_at = COALESCE(revoked_at, ?)", now).
+		Where("id = ?", id).
+		ApplyQueryBuilder(ownedBy(ctx, "user_id")).
+		Returning("*").
+		Exec(ctx)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && key.ID == "") {
+		return nil, fmt.Errorf("%w: api key %s does not exist", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return key, nil
+}
+
+// Looks up a live key by its hash, recording that it was just used.
+// Unknown and revoked keys are both reported as ErrNotFound.
+func (r *APIKeyRepository) UseAPIKey(ctx context.Context, hash string, now time.Time) (*models.APIKey, error) {
+	key := new(models.APIKey)
+
+	err := r.db.NewSelect().Model(key).Where("k.hash = ?", hash).Where("k.revoked_at IS NULL").Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: unknown or revoked api key", ErrNotFound)
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if key.LastUsedAt.IsZero() || now.Sub(key.LastUsedAt.Time) >= LastUsedResolution {
+		_, err := r.db.NewUpdate().
+			Model((*models.APIKey)(nil)).
+			Set("last_used_at = ?", now).
+			Where("id = ?", key.ID).
+			Exec(ctx)
+		if err != nil {
+			return nil, translateError(err)
+		}
+		key.LastUsedAt = bun.NullTime{Time: now}
+	}
+	return key, nil
+}
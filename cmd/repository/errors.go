@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// Domain errors returned by our repositories. Callers should match them
+// with errors.Is, as they are usually wrapped with more context.
+var (
+	// the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// the write clashes with existing data, i.e. a unique constraint
+	ErrConflict = errors.New("conflict")
+	// the database rejected the input, i.e. a malformed uuid
+	ErrInvalidArgument = errors.New("invalid argument")
+	// the database could not be reached
+	ErrUnavailable = errors.New("database unavailable")
+	// the row changed since the caller read it, so their write would undo someone else's
+	ErrStale = errors.New("stale version")
+	// the task events after a watcher's cursor have been pruned, so they
+	// cannot resume from it and have to start over
+	ErrCursorExpired = errors.New("cursor expired")
+)
+
+// Postgres SQLSTATE codes we translate into domain errors.
+// see https://www.postgresql.org/docs/current/errcodes-appendix.html
+var sqlStateErrors = map[string]error{
+	"23505": ErrConflict,        // unique_violation
+	"23503": ErrInvalidArgument, // foreign_key_violation
+	"23502": ErrInvalidArgument, // not_null_violation
+	"23514": ErrInvalidArgument, // check_violation
+	"22001": ErrInvalidArgument, // string_data_right_truncation
+	"22007": ErrInvalidArgument, // invalid_datetime_format
+	"22008": ErrInvalidArgument, // datetime_field_overflow
+	"22P02": ErrInvalidArgument, // invalid_text_representation, i.e. a malformed uuid
+	"57P01": ErrUnavailable,     // admin_shutdown
+	"57P02": ErrUnavailable,     // crash_shutdown
+	"57P03": ErrUnavailable,     // cannot_connect_now
+}
+
+// SQLite extended result codes we translate into domain errors.
+// see https://www.sqlite.org/rescode.html
+var sqliteCodeErrors = map[int]error{
+	2067: ErrConflict,        // SQLITE_CONSTRAINT_UNIQUE
+	1555: ErrConflict,        // SQLITE_CONSTRAINT_PRIMARYKEY
+	787:  ErrInvalidArgument, // SQLITE_CONSTRAINT_FOREIGNKEY
+	1299: ErrInvalidArgument, // SQLITE_CONSTRAINT_NOTNULL
+	275:  ErrInvalidArgument, // SQLITE_CONSTRAINT_CHECK
+	5:    ErrUnavailable,     // SQLITE_BUSY
+	6:    ErrUnavailable,     // SQLITE_LOCKED
+	14:   ErrUnavailable,     // SQLITE_CANTOPEN
+}
+
+// Translates an error coming back from the database into one of our
+// domain errors, keeping the original error's message for context.
+// Errors we cannot classify are returned untouched.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %v", ErrNotFound, err)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone):
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	if code := sqlState(err); code != "" {
+		if domainErr, ok := sqlStateErrors[code]; ok {
+			return fmt.Errorf("%w: %v", domainErr, err)
+		}
+		// class 08 covers every connection exception
+		if strings.HasPrefix(code, "08") {
+			return fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		if domainErr, ok := sqliteCodeErrors[sqliteErr.Code()]; ok {
+			return fmt.Errorf("%w: %v", domainErr, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	return err
+}
+
+// extracts the SQLSTATE code from either of the Postgres drivers we use;
+// lib/pq in our services and pgdriver in our tests
+func sqlState(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		return pgErr.Field('C')
+	}
+
+	return ""
+}
+
+// Reports ErrNotFound when a write matched no rows
+func checkRowsAffected(result sql.Result, entity, id string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return translateError(err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %s %s does not exist", ErrNotFound, entity, id)
+	}
+	return nil
+}
+
+// Rejects ids that are not uuids up front. Postgres refuses them in our id
+// columns by itself, SQLite would look them up and come back empty handed.
+func checkUUID(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("%w: %q is not a valid id", ErrInvalidArgument, id)
+	}
+	return nil
+}
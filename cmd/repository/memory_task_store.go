@@ -0,0 +1,626 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/auth"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Keeps tasks and tags in memory, for running our services and their tests
+// without a database. It mirrors TaskRepository down to the errors it
+// returns and the order it lists things in; the one thing it cannot check
+// is whether the project a task is filed under exists.
+//
+// Tasks are copied on the way in and out, so callers never share them.
+type MemoryTaskStore struct {
+	mu    sync.RWMutex
+	tasks map[string]*models.Task
+	// tag ids carried by each task, our task_tags
+	taskTags map[string][]string
+	tags     map[string]*models.Tag
+
+	// every task event in the order they happened, our task_events
+	events      []*models.TaskEvent
+	lastEventID int64
+	hub         eventHub
+}
+
+func NewMemoryTaskStore() *MemoryTaskStore {
+	return &MemoryTaskStore{
+		tasks:    map[string]*models.Task{},
+		taskTags: map[string][]string{},
+		tags:     map[string]*models.Tag{},
+	}
+}
+
+func (s *MemoryTaskStore) CreateTask(ctx context.Context, task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task.ID = uuid.New().String()
+	assignOwner(ctx, task)
+	if task.Status == "" {
+		task.Status = models.StatusTodo
+	}
+	if task.CreatedAt.IsZero() {
+		task.CreatedAt = time.Now()
+	}
+	task.Version = 1
+
+	if err := s.checkParent(ctx, task); err != nil {
+		return err
+	}
+
+	s.tasks[task.ID] = copyTask(task)
+	s.setTaskTags(task)
+	s.recordEvent(nil, s.tasks[task.ID], models.TaskCreated)
+	return nil
+}
+
+func (s *MemoryTaskStore) GetTask(ctx context.Context, id string) (*models.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, err := s.find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.load(stored), nil
+}
+
+func (s *MemoryTaskStore) ListTasks(ctx context.Context, filter TaskFilter, page TaskPage) ([]*models.Task, string, error) {
+	w, err := page.resolve()
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tasks []*models.Task
+	for _, stored := range s.tasks {
+		if !visibleTo(ctx, stored.OwnerID) || !stored.DeletedAt.IsZero() {
+			continue
+		}
+		task := s.load(stored)
+		if filter.matches(task, models.TagNames(task.Tags)) {
+			tasks = append(tasks, task)
+		}
+	}
+
+	tasks, nextPageToken := w.paginate(w.slice(tasks))
+	return tasks, nextPageToken, nil
+}
+
+func (s *MemoryTaskStore) UpdateTask(ctx context.Context, task *models.Task, columns ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateTask(ctx, task, columns)
+}
+
+// Callers hold s.mu
+func (s *MemoryTaskStore) updateTask(ctx context.Context, task *models.Task, columns []string) error {
+	if err := s.checkParent(ctx, task); err != nil {
+		return err
+	}
+	stored, err := s.find(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+
+	if stored.Version != task.Version {
+		return fmt.Errorf("%w: task %s is at version %d, not %d", ErrStale, task.ID, stored.Version, task.Version)
+	}
+
+	written, replaceTags := splitTagsColumn(columns)
+	updated := copyTask(task)
+	if len(columns) > 0 {
+		updated = copyTask(stored)
+		if err := copyColumns(updated, task, written); err != nil {
+			return err
+		}
+	}
+	task.Version++
+	updated.Version = task.Version
+
+	previous := s.load(stored)
+	s.tasks[task.ID] = updated
+	if replaceTags {
+		s.setTaskTags(task)
+	}
+	s.recordEvent(previous, updated, models.TaskUpdated)
+	return nil
+}
+
+func (s *MemoryTaskStore) DeleteTask(ctx context.Context, id string, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if version != AnyVersion && stored.Version != version {
+		return fmt.Errorf("%w: task %s is at version %d, not %d", ErrStale, id, stored.Version, version)
+	}
+
+	now := time.Now()
+	for _, trashed := range append(s.descendants(id), id) {
+		if stored := s.tasks[trashed]; visibleTo(ctx, stored.OwnerID) && stored.DeletedAt.IsZero() {
+			previous := s.load(stored)
+			stored.DeletedAt.Time = now
+			s.recordEvent(previous, stored, models.TaskDeleted)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTaskStore) ListTrash(ctx context.Context, page TaskPage) ([]*models.Task, string, error) {
+	page.OrderBy = trashOrder
+	w, err := page.resolve()
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tasks []*models.Task
+	for _, stored := range s.tasks {
+		if !visibleTo(ctx, stored.OwnerID) || stored.DeletedAt.IsZero() {
+			continue
+		}
+		if parent, ok := s.tasks[stored.ParentID]; ok && !parent.DeletedAt.IsZero() {
+			continue
+		}
+		tasks = append(tasks, s.load(stored))
+	}
+
+	tasks, nextPageToken := w.paginate(w.slice(tasks))
+	return tasks, nextPageToken, nil
+}
+
+func (s *MemoryTaskStore) RestoreTask(ctx context.Context, id string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.findTrashed(ctx, id)
+	if err != nil {
+		return err
+	}
+	if task.ParentID != "" {
+		if _, err := s.find(ctx, task.ParentID); err != nil {
+			return fmt.Errorf("%w: parent task %s is in the trash, restore it first", ErrInvalidArgument, task.ParentID)
+		}
+	}
+
+	for _, restored := range append(s.descendants(id), id) {
+		if stored := s.tasks[restored]; visibleTo(ctx, stored.OwnerID) && !stored.DeletedAt.IsZero() {
+			previous := s.load(stored)
+			stored.DeletedAt = bun.NullTime{}
+			stored.UpdatedAt.Time = now
+			stored.Version++
+			s.recordEvent(previous, stored, models.TaskCreated)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTaskStore) PermanentlyDeleteTask(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.findTrashed(ctx, id); err != nil {
+		return err
+	}
+
+	s.purge(ctx, append(s.descendants(id), id))
+	return nil
+}
+
+func (s *MemoryTaskStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []string
+	for id, stored := range s.tasks {
+		if visibleTo(ctx, stored.OwnerID) && !stored.DeletedAt.IsZero() && stored.DeletedAt.Before(before) {
+			expired = append(expired, id)
+		}
+	}
+
+	s.purge(ctx, expired)
+	return len(expired), nil
+}
+
+func (s *MemoryTaskStore) ListDescendants(ctx context.Context, id string) ([]*models.Task, error) {
+	if err := checkUUID(id); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tasks []*models.Task
+	for _, descendant := range s.descendants(id) {
+		if stored := s.tasks[descendant]; visibleTo(ctx, stored.OwnerID) && stored.DeletedAt.IsZero() {
+			tasks = append(tasks, s.load(stored))
+		}
+	}
+
+	slices.SortFunc(tasks, func(a, b *models.Task) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return tasks, nil
+}
+
+func (s *MemoryTaskStore) CountOpenDescendants(ctx context.Context, id string) (int, error) {
+	if err := checkUUID(id); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	open := 0
+	for _, descendant := range s.descendants(id) {
+		if stored := s.tasks[descendant]; visibleTo(ctx, stored.OwnerID) && stored.DeletedAt.IsZero() && !stored.Status.IsClosed() {
+			open++
+		}
+	}
+	return open, nil
+}
+
+func (s *MemoryTaskStore) CompleteTaskTree(ctx context.Context, task *models.Task, now time.Time, columns ...string) error {
+	if err := checkUUID(task.ID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// nothing can fail once the task is written
+	if err := s.updateTask(ctx, task, columns); err != nil {
+		return err
+	}
+	for _, descendant := range s.descendants(task.ID) {
+		if stored := s.tasks[descendant]; visibleTo(ctx, stored.OwnerID) && stored.DeletedAt.IsZero() && !stored.Status.IsClosed() {
+			previous := s.load(stored)
+			stored.Status = models.StatusDone
+			stored.CompletedAt.Time = now
+			stored.UpdatedAt.Time = now
+			stored.Version++
+			s.recordEvent(previous, stored, models.TaskUpdated)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryTaskStore) ListTags(ctx context.Context) ([]*models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags := make([]*models.Tag, 0, len(s.tags))
+	for _, tag := range s.tags {
+		if visibleTo(ctx, tag.OwnerID) {
+			tags = append(tags, s.countTaggedTasks(tag))
+		}
+	}
+	sortTagsByName(tags)
+	return tags, nil
+}
+
+func (s *MemoryTaskStore) RenameTag(ctx context.Context, name, newName string) (*models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag := s.visibleTag(ctx, name)
+	if tag == nil {
+		return nil, fmt.Errorf("%w: tag %q does not exist", ErrNotFound, name)
+	}
+	if existing := s.tagNamed(tag.OwnerID, newName); existing != nil && existing != tag {
+		return nil, fmt.Errorf("%w: tag %q already exists", ErrConflict, newName)
+	}
+
+	s.changeTags([]string{tag.ID}, func() {
+		tag.Name = newName
+	})
+	return s.countTaggedTasks(tag), nil
+}
+
+func (s *MemoryTaskStore) MergeTags(ctx context.Context, sources []string, target string) (*models.Tag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sourceIDs := map[string]bool{}
+	for _, name := range sources {
+		tag := s.visibleTag(ctx, name)
+		if tag == nil {
+			return nil, fmt.Errorf("%w: not every tag in %q exists", ErrNotFound, sources)
+		}
+		sourceIDs[tag.ID] = true
+	}
+
+	ownerID, _ := auth.UserID(ctx)
+	merged := s.upsertTags(ownerID, []string{target})[0]
+	delete(sourceIDs, merged.ID)
+
+	s.changeTags(slices.Collect(maps.Keys(sourceIDs)), func() {
+		for taskID, tagIDs := range s.taskTags {
+			carried := slices.ContainsFunc(tagIDs, func(id string) bool { return sourceIDs[id] })
+			tagIDs = slices.DeleteFunc(tagIDs, func(id string) bool { return sourceIDs[id] })
+			// tasks already carrying the target keep a single link to it
+			if carried && !slices.Contains(tagIDs, merged.ID) {
+				tagIDs = append(tagIDs, merged.ID)
+			}
+			s.taskTags[taskID] = tagIDs
+		}
+		for id := range sourceIDs {
+			delete(s.tags, id)
+		}
+	})
+
+	return s.countTaggedTasks(merged), nil
+}
+
+func (s *MemoryTaskStore) DeleteTag(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tag := s.visibleTag(ctx, name)
+	if tag == nil {
+		return fmt.Errorf("%w: tag %q does not exist", ErrNotFound, name)
+	}
+
+	s.changeTags([]string{tag.ID}, func() {
+		for taskID, tagIDs := range s.taskTags {
+			s.taskTags[taskID] = slices.DeleteFunc(tagIDs, func(id string) bool { return id == tag.ID })
+		}
+		delete(s.tags, tag.ID)
+	})
+	return nil
+}
+
+// Applies change to the tags, bumping the version of every task carrying
+// one of tagIDs and recording its update the way touchTaggedTasks and our
+// triggers do for TaskRepository. Callers hold s.mu.
+func (s *MemoryTaskStore) changeTags(tagIDs []string, change func()) {
+	previous := map[*models.Task]*models.Task{}
+	now := time.Now()
+	for taskID, ids := range s.taskTags {
+		if slices.ContainsFunc(ids, func(id string) bool { return slices.Contains(tagIDs, id) }) {
+			stored := s.tasks[taskID]
+			previous[stored] = s.load(stored)
+			stored.Version++
+			stored.UpdatedAt.Time = now
+		}
+	}
+
+	change()
+	for stored, before := range previous {
+		// tasks in the trash are already deleted as far as watchers know
+		if stored.DeletedAt.IsZero() {
+			s.recordEvent(before, stored, models.TaskUpdated)
+		}
+	}
+}
+
+// Looks up a stored task the user on ctx gets to see
+func (s *MemoryTaskStore) find(ctx context.Context, id string) (*models.Task, error) {
+	if err := checkUUID(id); err != nil {
+		return nil, err
+	}
+
+	stored, ok := s.tasks[id]
+	if !ok || !visibleTo(ctx, stored.OwnerID) || !stored.DeletedAt.IsZero() {
+		return nil, fmt.Errorf("%w: task %s does not exist", ErrNotFound, id)
+	}
+	return stored, nil
+}
+
+// Looks up a task in the trash the user on ctx gets to see
+func (s *MemoryTaskStore) findTrashed(ctx context.Context, id string) (*models.Task, error) {
+	if err := checkUUID(id); err != nil {
+		return nil, err
+	}
+
+	stored, ok := s.tasks[id]
+	if !ok || !visibleTo(ctx, stored.OwnerID) || stored.DeletedAt.IsZero() {
+		return nil, fmt.Errorf("%w: task %s is not in the trash", ErrNotFound, id)
+	}
+	return stored, nil
+}
+
+// Removes the tasks for good, along with their tags
+func (s *MemoryTaskStore) purge(ctx context.Context, ids []string) {
+	for _, id := range ids {
+		if visibleTo(ctx, s.tasks[id].OwnerID) {
+			delete(s.tasks, id)
+			delete(s.taskTags, id)
+		}
+	}
+}
+
+// Copies a stored task out along with its tags
+func (s *MemoryTaskStore) load(stored *models.Task) *models.Task {
+	task := copyTask(stored)
+	task.Tags = make([]*models.Tag, 0, len(s.taskTags[stored.ID]))
+	for _, id := range s.taskTags[stored.ID] {
+		tag := *s.tags[id]
+		task.Tags = append(task.Tags, &tag)
+	}
+	sortTagsByName(task.Tags)
+	return task
+}
+
+// The ids of every task below the given one, however deep
+func (s *MemoryTaskStore) descendants(id string) []string {
+	children := map[string][]string{}
+	for _, task := range s.tasks {
+		if task.ParentID != "" {
+			children[task.ParentID] = append(children[task.ParentID], task.ID)
+		}
+	}
+
+	var subtree []string
+	seen := map[string]bool{id: true}
+	queue := children[id]
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if seen[next] {
+			continue
+		}
+		seen[next] = true
+		subtree = append(subtree, next)
+		queue = append(queue, children[next]...)
+	}
+	return subtree
+}
+
+// Same rules as checkParent: the parent has to be one of the caller's own
+// tasks and must not sit below the task itself
+func (s *MemoryTaskStore) checkParent(ctx context.Context, task *models.Task) error {
+	if task.ParentID == "" {
+		return nil
+	}
+	if task.ParentID == task.ID {
+		return fmt.Errorf("%w: a task cannot be its own parent", ErrInvalidArgument)
+	}
+
+	if _, err := s.find(ctx, task.ParentID); err != nil {
+		return fmt.Errorf("%w: parent task %s does not exist", ErrInvalidArgument, task.ParentID)
+	}
+
+	if slices.Contains(s.descendants(task.ID), task.ParentID) {
+		return fmt.Errorf("%w: task %s cannot be moved under its own subtask %s", ErrInvalidArgument, task.ID, task.ParentID)
+	}
+	return nil
+}
+
+// Replaces the tags linked to a task with task.Tags, creating tags of its
+// owner that do not exist yet. task.Tags is refreshed with the stored tags.
+func (s *MemoryTaskStore) setTaskTags(task *models.Task) {
+	tags := s.upsertTags(s.tasks[task.ID].OwnerID, models.TagNames(task.Tags))
+
+	ids := make([]string, 0, len(tags))
+	task.Tags = make([]*models.Tag, 0, len(tags))
+	for _, tag := range tags {
+		ids = append(ids, tag.ID)
+		stored := *tag
+		task.Tags = append(task.Tags, &stored)
+	}
+	s.taskTags[task.ID] = ids
+}
+
+// Makes sure the owner has a tag for every name and returns them ordered
+// by name
+func (s *MemoryTaskStore) upsertTags(ownerID string, names []string) []*models.Tag {
+	tags := make([]*models.Tag, 0, len(names))
+	for _, name := range names {
+		tag := s.tagNamed(ownerID, name)
+		if tag == nil {
+			tag = &models.Tag{ID: uuid.New().String(), OwnerID: ownerID, Name: name, CreatedAt: time.Now()}
+			s.tags[tag.ID] = tag
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	sortTagsByName(tags)
+	return tags
+}
+
+func (s *MemoryTaskStore) tagNamed(ownerID, name string) *models.Tag {
+	for _, tag := range s.tags {
+		if tag.OwnerID == ownerID && tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+// Looks up a tag of the given name the user on ctx gets to see
+func (s *MemoryTaskStore) visibleTag(ctx context.Context, name string) *models.Tag {
+	for _, tag := range s.tags {
+		if visibleTo(ctx, tag.OwnerID) && tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
+
+// Copies the tag out with the number of tasks carrying it filled in
+func (s *MemoryTaskStore) countTaggedTasks(tag *models.Tag) *models.Tag {
+	counted := *tag
+	counted.TaskCount = 0
+	for taskID, tagIDs := range s.taskTags {
+		if s.tasks[taskID].DeletedAt.IsZero() && slices.Contains(tagIDs, tag.ID) {
+			counted.TaskCount++
+		}
+	}
+	return &counted
+}
+
+// Copies the given columns of src over to dst, as an UPDATE writing only
+// those columns would
+func copyColumns(dst, src *models.Task, columns []string) error {
+	for _, column := range columns {
+		switch column {
+		case "title":
+			dst.Title = src.Title
+		case "description":
+			dst.Description = src.Description
+		case "status":
+			dst.Status = src.Status
+		case "priority":
+			dst.Priority = src.Priority
+		case "created_at":
+			dst.CreatedAt = src.CreatedAt
+		case "updated_at":
+			dst.UpdatedAt = src.UpdatedAt
+		case "completed_at":
+			dst.CompletedAt = src.CompletedAt
+		case "start_at":
+			dst.StartAt = src.StartAt
+		case "due_at":
+			dst.DueAt = src.DueAt
+		case "parent_id":
+			dst.ParentID = src.ParentID
+		case "owner_id":
+			dst.OwnerID = src.OwnerID
+		case "project_id":
+			dst.ProjectID = src.ProjectID
+		default:
+			return fmt.Errorf("%w: tasks have no column %q", ErrInvalidArgument, column)
+		}
+	}
+	return nil
+}
+
+// copies the task's own columns, leaving out its relations
+func copyTask(task *models.Task) *models.Task {
+	copied := *task
+	copied.Parent, copied.Owner, copied.Project = nil, nil, nil
+	copied.Tags, copied.Subtasks = nil, nil
+	return &copied
+}
+
+func sortTagsByName(tags []*models.Tag) {
+	slices.SortFunc(tags, func(a, b *models.Tag) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/50-Course/notes-tracker/shared/auth"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+)
+
+// Scopes a tasks query down to the tasks owned by the user making the
+// request, for use with ApplyQueryBuilder. Requests without a user, i.e.
+// our own maintenance jobs, are left unscoped; our gRPC server refuses
+// client calls without one.
+func ownedBy(ctx context.Context, column string) func(bun.QueryBuilder) bun.QueryBuilder {
+	return func(q bun.QueryBuilder) bun.QueryBuilder {
+		if userID, ok := auth.UserID(ctx); ok {
+			return q.Where(column+" = ?", userID)
+		}
+		return q
+	}
+}
+
+// hands a new task over to the user creating it
+func assignOwner(ctx context.Context, task *models.Task) {
+	if userID, ok := auth.UserID(ctx); ok && task.OwnerID == "" {
+		task.OwnerID = userID
+	}
+}
+
+// The in-memory counterpart of ownedBy, reporting whether the user making
+// the request gets to see a task owned by ownerID
+func visibleTo(ctx context.Context, ownerID string) bool {
+	userID, ok := auth.UserID(ctx)
+	return !ok || userID == ownerID
+}
@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// columns our clients are allowed to order tasks by
+var sortableColumns = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"title":      true,
+	"start_at":   true,
+	"due_at":     true,
+	"priority":   true,
+}
+
+// A single ORDER BY term, i.e. "due_at desc"
+type TaskSort struct {
+	Column string
+	Desc   bool
+}
+
+// Describes which slice of the tasks a ListTasks call should return
+type TaskPage struct {
+	// number of tasks per page, defaults to DefaultPageSize
+	Size int
+	// opaque token handed out as the previous page's next page token
+	Token string
+	// sort order, defaults to oldest tasks first
+	OrderBy []TaskSort
+}
+
+// Parses an order_by expression such as "due_at desc, title" into sort
+// terms. An empty expression parses to no terms, leaving the default order.
+func ParseOrderBy(orderBy string) ([]TaskSort, error) {
+	var sorts []TaskSort
+	if strings.TrimSpace(orderBy) == "" {
+		return sorts, nil
+	}
+
+	for _, term := range strings.Split(orderBy, ",") {
+		fields := strings.Fields(term)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid order_by term %q", strings.TrimSpace(term))
+		}
+
+		sort := TaskSort{Column: strings.ToLower(fields[0])}
+		if !sortableColumns[sort.Column] {
+			return nil, fmt.Errorf("cannot order tasks by %q", fields[0])
+		}
+
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				sort.Desc = true
+			default:
+				return nil, fmt.Errorf("invalid sort direction %q, expected asc or desc", fields[1])
+			}
+		}
+
+		sorts = append(sorts, sort)
+	}
+
+	return sorts, nil
+}
+
+// formats the sort terms back into an order_by expression
+func formatOrderBy(sorts []TaskSort) string {
+	terms := make([]string, 0, len(sorts))
+	for _, sort := range sorts {
+		term := sort.Column
+		if sort.Desc {
+			term += " desc"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, ", ")
+}
+
+// The decoded contents of a page token.
+//
+// Task pages use keyset pagination: the token holds the last task's value
+// of every sort column along with its id, and the next page starts right
+// after that task in the page's order. Unlike an offset this stays put
+// while tasks are written in between pages. Search results are ranked per
+// query rather than ordered by a column, so those are paged by offset.
+type pageCursor struct {
+	OrderBy string `json:"s"`
+	// the last task's value of each sort term, nil where it had none
+	Keys   []*string `json:"k,omitempty"`
+	ID     string    `json:"i,omitempty"`
+	Offset int       `json:"o,omitempty"`
+}
+
+func (c pageCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodePageCursor(token string) (pageCursor, error) {
+	var cursor pageCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, fmt.Errorf("malformed page token")
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return cursor, fmt.Errorf("malformed page token")
+	}
+	return cursor, nil
+}
+
+// A page resolved against our defaults, along with its decoded token
+type pageWindow struct {
+	size     int
+	orderBy  []TaskSort
+	orderKey string
+	// the direction ties are broken by id in, the one of the last term
+	desc   bool
+	cursor pageCursor
+	// the last task of the previous page, rebuilt from the cursor
+	anchor *models.Task
+}
+
+// Fills in the defaults of the page and validates its token
+func (p TaskPage) resolve() (pageWindow, error) {
+	w := pageWindow{size: p.Size, orderBy: p.OrderBy}
+	switch {
+	case w.size < 0:
+		return w, fmt.Errorf("page size must not be negative")
+	case w.size == 0:
+		w.size = DefaultPageSize
+	case w.size > MaxPageSize:
+		w.size = MaxPageSize
+	}
+
+	if len(w.orderBy) == 0 {
+		w.orderBy = []TaskSort{{Column: "created_at"}}
+	}
+	w.orderKey = formatOrderBy(w.orderBy)
+	w.desc = w.orderBy[len(w.orderBy)-1].Desc
+
+	if p.Token != "" {
+		var err error
+		if w.cursor, err = decodePageCursor(p.Token); err != nil {
+			return w, err
+		}
+		if w.cursor.OrderBy != w.orderKey {
+			return w, fmt.Errorf("page token was issued for a different order_by")
+		}
+		if w.cursor.ID != "" {
+			if w.anchor, err = w.cursor.task(w.orderBy); err != nil {
+				return w, err
+			}
+		}
+	}
+	return w, nil
+}
+
+// Trims the lookahead row off the tasks that came back for the window
+// and works out the next page token
+func (w pageWindow) paginate(tasks []*models.Task) ([]*models.Task, string) {
+	if len(tasks) <= w.size {
+		return tasks, ""
+	}
+
+	tasks = tasks[:w.size]
+	last := tasks[len(tasks)-1]
+	cursor := pageCursor{OrderBy: w.orderKey, ID: last.ID}
+	for _, term := range w.orderBy {
+		cursor.Keys = append(cursor.Keys, sortKey(term.Column, last))
+	}
+	return tasks, cursor.encode()
+}
+
+// The token of the page after the window, for pages paged by offset
+func (w pageWindow) nextOffsetToken() string {
+	return pageCursor{OrderBy: w.orderKey, Offset: w.cursor.Offset + w.size}.encode()
+}
+
+// Rebuilds the last task of the previous page from its sort keys, as far
+// as ordering goes
+func (c pageCursor) task(orderBy []TaskSort) (*models.Task, error) {
+	if len(c.Keys) != len(orderBy) {
+		return nil, fmt.Errorf("malformed page token")
+	}
+
+	task := &models.Task{ID: c.ID}
+	for i, term := range orderBy {
+		if err := setSortKey(term.Column, task, c.Keys[i]); err != nil {
+			return nil, fmt.Errorf("malformed page token")
+		}
+	}
+	return task, nil
+}
+
+// Applies the page's ordering, cursor and limit onto a tasks query. It
+// returns a function that, given the rows that came back, trims the
+// lookahead row and works out the next page token.
+func (p TaskPage) apply(q *bun.SelectQuery) (*bun.SelectQuery, func([]*models.Task) ([]*models.Task, string), error) {
+	w, err := p.resolve()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, sort := range w.orderBy {
+		direction := "ASC"
+		if sort.Desc {
+			direction = "DESC"
+		}
+		q = q.OrderExpr("? "+direction+" NULLS LAST", bun.Ident("t."+sort.Column))
+	}
+	// ties are always broken by id, so every order is total
+	if w.desc {
+		q = q.OrderExpr("t.id DESC")
+	} else {
+		q = q.OrderExpr("t.id ASC")
+	}
+
+	if w.anchor != nil {
+		q = w.after(q)
+	}
+
+	// we fetch one row more than asked for to know whether a next page exists
+	q = q.Limit(w.size + 1)
+
+	return q, w.paginate, nil
+}
+
+// Keeps the rows that come after the anchor in the window's order. Since
+// the terms may run in different directions and put NULLs last either
+// way, this spells out the row comparison one term at a time: a row comes
+// after when it ties with the anchor on the terms before one and comes
+// after it on that one, id being the last term.
+func (w pageWindow) after(q *bun.SelectQuery) *bun.SelectQuery {
+	var (
+		alternatives []string
+		ties         []string
+		args         []any
+		tieArgs      []any
+	)
+	for _, term := range w.orderBy {
+		column := "t." + term.Column
+		value, null := sortValue(term.Column, w.anchor)
+
+		// nothing sorts after a NULL but other NULLs, which tie with it
+		if null {
+			ties = append(ties, column+" IS NULL")
+			continue
+		}
+
+		comparison := " > ?"
+		if term.Desc {
+			comparison = " < ?"
+		}
+		alternative := append(slices.Clone(ties), "("+column+comparison+" OR "+column+" IS NULL)")
+		alternatives = append(alternatives, strings.Join(alternative, " AND "))
+		args = append(append(args, tieArgs...), value)
+
+		ties = append(ties, column+" = ?")
+		tieArgs = append(tieArgs, value)
+	}
+
+	comparison := "t.id > ?"
+	if w.desc {
+		comparison = "t.id < ?"
+	}
+	alternatives = append(alternatives, strings.Join(append(ties, comparison), " AND "))
+	args = append(append(args, tieArgs...), w.anchor.ID)
+
+	return q.Where("("+strings.Join(alternatives, ") OR (")+")", args...)
+}
+
+// The in-memory counterpart of apply: sorts the tasks the way our queries
+// order them and cuts out the window, lookahead row included
+func (w pageWindow) slice(tasks []*models.Task) []*models.Task {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return w.compare(tasks[i], tasks[j]) < 0
+	})
+
+	if w.anchor != nil {
+		after := tasks[:0]
+		for _, task := range tasks {
+			if w.compare(task, w.anchor) > 0 {
+				after = append(after, task)
+			}
+		}
+		tasks = after
+	}
+
+	return tasks[:min(w.size+1, len(tasks))]
+}
+
+// Orders a before b just like ORDER BY <terms> NULLS LAST, id would
+func (w pageWindow) compare(a, b *models.Task) int {
+	for _, term := range w.orderBy {
+		c, nulls := compareColumn(term.Column, a, b)
+		if c == 0 {
+			continue
+		}
+		// missing values stay last whichever way we sort
+		if term.Desc && !nulls {
+			c = -c
+		}
+		return c
+	}
+
+	if w.desc {
+		return strings.Compare(b.ID, a.ID)
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// Compares a single sortable column of two tasks. nulls reports that the
+// two differ in only one of them being NULL, which sorts after any value.
+func compareColumn(column string, a, b *models.Task) (c int, nulls bool) {
+	switch column {
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt), false
+	case "updated_at":
+		return compareNullTimes(a.UpdatedAt, b.UpdatedAt)
+	case "start_at":
+		return compareNullTimes(a.StartAt, b.StartAt)
+	case "due_at":
+		return compareNullTimes(a.DueAt, b.DueAt)
+	case "title":
+		return strings.Compare(a.Title, b.Title), false
+	case "priority":
+		return cmp.Compare(a.Priority, b.Priority), false
+	case "deleted_at":
+		return compareNullTimes(a.DeletedAt, b.DeletedAt)
+	}
+	return 0, false
+}
+
+func compareNullTimes(a, b bun.NullTime) (int, bool) {
+	switch {
+	case a.IsZero() && b.IsZero():
+		return 0, false
+	case a.IsZero():
+		return 1, true
+	case b.IsZero():
+		return -1, true
+	}
+	return a.Time.Compare(b.Time), false
+}
+
+// Reads a sort column off a task for a page token, nil when it is NULL
+func sortKey(column string, task *models.Task) *string {
+	value, null := sortValue(column, task)
+	if null {
+		return nil
+	}
+
+	var key string
+	switch value := value.(type) {
+	case time.Time:
+		key = value.UTC().Format(time.RFC3339Nano)
+	case string:
+		key = value
+	case int:
+		key = strconv.Itoa(value)
+	}
+	return &key
+}
+
+// Sets a sort column read by sortKey back onto a task
+func setSortKey(column string, task *models.Task, key *string) error {
+	if key == nil {
+		// only the nullable columns can have been left out
+		switch column {
+		case "updated_at", "start_at", "due_at", "deleted_at":
+			return nil
+		}
+		return fmt.Errorf("%s cannot be NULL", column)
+	}
+
+	switch column {
+	case "title":
+		task.Title = *key
+		return nil
+	case "priority":
+		priority, err := strconv.Atoi(*key)
+		task.Priority = models.TaskPriority(priority)
+		return err
+	}
+
+	at, err := time.Parse(time.RFC3339Nano, *key)
+	if err != nil {
+		return err
+	}
+	switch column {
+	case "created_at":
+		task.CreatedAt = at
+	case "updated_at":
+		task.UpdatedAt = bun.NullTime{Time: at}
+	case "start_at":
+		task.StartAt = bun.NullTime{Time: at}
+	case "due_at":
+		task.DueAt = bun.NullTime{Time: at}
+	case "deleted_at":
+		task.DeletedAt = bun.NullTime{Time: at}
+	default:
+		return fmt.Errorf("cannot page by %q", column)
+	}
+	return nil
+}
+
+// The value of a sort column of a task as a query argument, and whether
+// it is NULL
+func sortValue(column string, task *models.Task) (value any, null bool) {
+	switch column {
+	case "created_at":
+		return task.CreatedAt, false
+	case "updated_at":
+		return task.UpdatedAt.Time, task.UpdatedAt.IsZero()
+	case "start_at":
+		return task.StartAt.Time, task.StartAt.IsZero()
+	case "due_at":
+		return task.DueAt.Time, task.DueAt.IsZero()
+	case "title":
+		return task.Title, false
+	case "priority":
+		return int(task.Priority), false
+	case "deleted_at":
+		return task.DeletedAt.Time, task.DeletedAt.IsZero()
+	}
+	return nil, true
+}
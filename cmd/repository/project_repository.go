@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/50-Course/notes-tracker/shared/auth"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type ProjectRepository struct {
+	db *bun.DB
+}
+
+func NewProjectRepository(db *bun.DB) *ProjectRepository {
+	return &ProjectRepository{db: db}
+}
+
+// Inserts the project, handing it over to the user creating it
+func (r *ProjectRepository) CreateProject(ctx context.Context, project *models.Project) error {
+	project.ID = uuid.New().String()
+	if userID, ok := auth.UserID(ctx); ok {
+		project.OwnerID = userID
+	}
+
+	_, err := r.db.NewInsert().Model(project).Exec(ctx)
+	return translateError(err)
+}
+
+func (r *ProjectRepository) GetProject(ctx context.Context, id string) (*models.Project, error) {
+	if err := checkUUID(id); err != nil {
+		return nil, err
+	}
+
+	project := new(models.Project)
+	err := r.db.NewSelect().
+		Model(project).
+		Where("p.id = ?", id).
+		ApplyQueryBuilder(ownedBy(ctx, "p.owner_id")).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: project %s does not exist", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return project, nil
+}
+
+// Lists the caller's projects by name, leaving archived ones out unless
+// asked for
+func (r *ProjectRepository) ListProjects(ctx context.Context, includeArchived bool) ([]*models.Project, error) {
+	var projects []*models.Project
+
+	q := r.db.NewSelect().
+		Model(&projects).
+		ApplyQueryBuilder(ownedBy(ctx, "p.owner_id")).
+		Order("p.name ASC", "p.id ASC")
+	if !includeArchived {
+		q = q.Where("p.archived = ?", false)
+	}
+
+	err := q.Scan(ctx)
+	return projects, translateError(err)
+}
+
+func (r *ProjectRepository) UpdateProject(ctx context.Context, project *models.Project) error {
+	if err := checkUUID(project.ID); err != nil {
+		return err
+	}
+
+	result, err := r.db.NewUpdate().
+		Model(project).
+		Where("id = ?", project.ID).
+		ApplyQueryBuilder(ownedBy(ctx, "owner_id")).
+		Exec(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	return checkRowsAffected(result, "project", project.ID)
+}
+
+// Deletes the project. Its tasks are kept around, they just no longer
+// belong to any project.
+func (r *ProjectRepository) DeleteProject(ctx context.Context, id string) error {
+	if err := checkUUID(id); err != nil {
+		return err
+	}
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// another user's project is left alone along with its tasks
+		err := tx.NewSelect().
+			Model((*models.Project)(nil)).
+			Column("p.id").
+			Where("p.id = ?", id).
+			ApplyQueryBuilder(ownedBy(ctx, "p.owner_id")).
+			Scan(ctx, new(string))
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: project %s does not exist", ErrNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*models.Task)(nil)).
+			Set("project_id = NULL").
+			Where("project_id = ?", id).
+			// tasks in the trash too, they would otherwise hold on to the project
+			WhereAllWithDeleted().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewDelete().Model((*models.Project)(nil)).Where("id = ?", id).Exec(ctx)
+		return err
+	})
+	return translateError(err)
+}
+
+// Makes sure the project a task is filed under exists and is one of the
+// caller's own
+func checkProject(ctx context.Context, db bun.IDB, task *models.Task) error {
+	if task.ProjectID == "" {
+		return nil
+	}
+
+	err := db.NewSelect().
+		Model((*models.Project)(nil)).
+		Column("p.id").
+		Where("p.id = ?", task.ProjectID).
+		ApplyQueryBuilder(ownedBy(ctx, "p.owner_id")).
+		Scan(ctx, new(string))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: project %s does not exist", ErrInvalidArgument, task.ProjectID)
+	}
+	return err
+}
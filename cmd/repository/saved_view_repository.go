@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/50-Course/notes-tracker/shared/auth"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Keeps the views our users saved, each visible to its owner alone
+type SavedViewRepository struct {
+	db *bun.DB
+}
+
+func NewSavedViewRepository(db *bun.DB) *SavedViewRepository {
+	return &SavedViewRepository{db: db}
+}
+
+// Stores a new view for the user making the request. Names are unique per
+// user, so reusing one fails with ErrConflict.
+func (r *SavedViewRepository) CreateSavedView(ctx context.Context, view *models.SavedView) error {
+	view.ID = uuid.New().String()
+	if userID, ok := auth.UserID(ctx); ok {
+		view.OwnerID = userID
+	}
+
+	_, err := r.db.NewInsert().Model(view).Exec(ctx)
+	return translateViewError(err, view.Name)
+}
+
+func (r *SavedViewRepository) GetSavedView(ctx context.Context, id string) (*models.SavedView, error) {
+	if err := checkUUID(id); err != nil {
+		return nil, err
+	}
+
+	view := new(models.SavedView)
+	err := r.db.NewSelect().
+		Model(view).
+		Where("v.id = ?", id).
+		ApplyQueryBuilder(ownedBy(ctx, "v.owner_id")).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: view %s does not exist", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return view, nil
+}
+
+// Lists the views of the user making the request by name
+func (r *SavedViewRepository) ListSavedViews(ctx context.Context) ([]*models.SavedView, error) {
+	var views []*models.SavedView
+
+	err := r.db.NewSelect().
+		Model(&views).
+		ApplyQueryBuilder(ownedBy(ctx, "v.owner_id")).
+		Order("v.name ASC", "v.id ASC").
+		Scan(ctx)
+	return views, translateError(err)
+}
+
+// Saves every field of the view but its owner
+func (r *SavedViewRepository) UpdateSavedView(ctx context.Context, view *models.SavedView) error {
+	if err := checkUUID(view.ID); err != nil {
+		return err
+	}
+
+	result, err := r.db.NewUpdate().
+		Model(view).
+		ExcludeColumn("owner_id", "created_at").
+		Where("id = ?", view.ID).
+		ApplyQueryBuilder(ownedBy(ctx, "owner_id")).
+		Exec(ctx)
+	if err != nil {
+		return translateViewError(err, view.Name)
+	}
+	return checkRowsAffected(result, "view", view.ID)
+}
+
+func (r *SavedViewRepository) DeleteSavedView(ctx context.Context, id string) error {
+	if err := checkUUID(id); err != nil {
+		return err
+	}
+
+	result, err := r.db.NewDelete().
+		Model((*models.SavedView)(nil)).
+		Where("id = ?", id).
+		ApplyQueryBuilder(ownedBy(ctx, "owner_id")).
+		Exec(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	return checkRowsAffected(result, "view", id)
+}
+
+// Like translateError, naming the view a clash on its name is about
+func translateViewError(err error, name string) error {
+	err = translateError(err)
+	if errors.Is(err, ErrConflict) {
+		return fmt.Errorf("%w: a view named %q already exists", ErrConflict, name)
+	}
+	return err
+}
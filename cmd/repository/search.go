@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Matches are marked up in titles and snippets with these
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// Searches are ordered by how well tasks matched, and paged by offset
+// rather than by the keyset cursors ListTasks hands out. A keyset cursor
+// seeks past the last row's sort keys, but a task's rank is no column: it
+// is worked out afresh by every search, as a float whose exact value can
+// change with the tasks around it, so there is nothing stable to seek
+// past. The price is that tasks changing in between pages can shift them,
+// showing a task twice or not at all.
+var searchOrder = []TaskSort{{Column: "rank", Desc: true}}
+
+// A task found by SearchTasks, along with how well it matched
+type TaskMatch struct {
+	Task *models.Task
+	// higher is better, though only comparable within a single search
+	Rank float64
+	// the title with every match wrapped in HighlightStart and HighlightStop
+	Title string
+	// an excerpt of the description around its matches, marked up the same way
+	Snippet string
+}
+
+// A single term of a search query: a word, or a "quoted phrase" of words
+// that have to follow one another. A trailing * matches any word starting
+// with the last word, i.e. plan* matches planning.
+type searchTerm struct {
+	words  []string
+	prefix bool
+}
+
+// Parses a search query into the terms a task has to match every one of.
+// Anything but letters and digits separates words, so e-mail is searched
+// for as the phrase "e mail".
+func parseSearchQuery(query string) ([]searchTerm, error) {
+	var terms []searchTerm
+
+	for rest := strings.TrimSpace(query); rest != ""; rest = strings.TrimSpace(rest) {
+		var raw string
+		if rest[0] == '"' {
+			phrase, remainder, closed := strings.Cut(rest[1:], `"`)
+			if !closed {
+				return nil, fmt.Errorf("%w: the search query has an unterminated quote", ErrInvalidArgument)
+			}
+			raw, rest = phrase, remainder
+			// a * right after the closing quote makes the phrase's last word a prefix
+			if strings.HasPrefix(rest, "*") {
+				raw, rest = raw+"*", rest[1:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(rest)
+			}
+			raw, rest = rest[:end], rest[end:]
+		}
+
+		words := splitWords(raw)
+		if len(words) == 0 {
+			continue
+		}
+		term := searchTerm{prefix: strings.HasSuffix(raw, "*")}
+		for _, word := range words {
+			term.words = append(term.words, word.word)
+		}
+		terms = append(terms, term)
+	}
+
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("%w: the search query has no words in it", ErrInvalidArgument)
+	}
+	return terms, nil
+}
+
+// Formats the terms as a Postgres tsquery. Words are letters and digits
+// alone, so quoting them is all the escaping they need.
+func toTSQuery(terms []searchTerm) string {
+	formatted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		words := make([]string, 0, len(term.words))
+		for _, word := range term.words {
+			words = append(words, "'"+word+"'")
+		}
+		if term.prefix {
+			words[len(words)-1] += ":*"
+		}
+		formatted = append(formatted, strings.Join(words, " <-> "))
+	}
+	return strings.Join(formatted, " & ")
+}
+
+// Formats the terms as an SQLite FTS5 query
+func toFTSQuery(terms []searchTerm) string {
+	formatted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		phrase := `"` + strings.Join(term.words, " ") + `"`
+		if term.prefix {
+			phrase += "*"
+		}
+		formatted = append(formatted, phrase)
+	}
+	return strings.Join(formatted, " AND ")
+}
+
+// the ids of the tasks a search found, along with how they matched
+type searchHit struct {
+	ID             string
+	Rank           float64
+	TitleHighlight string
+	Snippet        string
+}
+
+// Searches the titles and descriptions of the caller's tasks, best matches
+// first. Postgres stems words and ranks by its search vector; SQLite does
+// the same through its FTS5 index.
+func (r *TaskRepository) SearchTasks(ctx context.Context, query string, page TaskPage) ([]*TaskMatch, string, error) {
+	terms, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page.OrderBy = searchOrder
+	w, err := page.resolve()
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	var q *bun.SelectQuery
+	if r.db.Dialect().Name() == dialect.SQLite {
+		q = r.db.NewSelect().
+			TableExpr("tasks_fts").
+			Join("JOIN tasks AS t ON t.rowid = tasks_fts.rowid").
+			ColumnExpr("t.id").
+			// bm25 ranks better matches lower, weighing titles twice as much
+			ColumnExpr("-bm25(tasks_fts, 2.0, 1.0) AS rank").
+			ColumnExpr("highlight(tasks_fts, 0, ?, ?) AS title_highlight", HighlightStart, HighlightStop).
+			ColumnExpr("coalesce(snippet(tasks_fts, 1, ?, ?, '…', 16), '') AS snippet", HighlightStart, HighlightStop).
+			Where("tasks_fts MATCH ?", toFTSQuery(terms))
+	} else {
+		q = r.db.NewSelect().
+			TableExpr("tasks AS t").
+			TableExpr("to_tsquery('english', ?) AS query", toTSQuery(terms)).
+			ColumnExpr("t.id, t.title, t.description, query").
+			ColumnExpr("ts_rank_cd(t.search_vector, query) AS rank").
+			Where("t.search_vector @@ query")
+	}
+
+	q = q.Where("t.deleted_at IS NULL").
+		ApplyQueryBuilder(ownedBy(ctx, "t.owner_id")).
+		// ties are broken by id in the direction of the last term, as with ListTasks
+		OrderExpr("rank DESC, t.id DESC").
+		Offset(w.cursor.Offset).
+		// one row more than asked for tells us whether a next page exists
+		Limit(w.size + 1)
+	if r.db.Dialect().Name() != dialect.SQLite {
+		// ts_headline parses the whole of every text it marks up, so it is
+		// left for the page rather than every task that matched. FTS5 has
+		// its matches at hand already, and only highlights them within the
+		// query that found them.
+		q = r.db.NewSelect().
+			TableExpr("(?) AS hits", q).
+			ColumnExpr("hits.id, hits.rank").
+			ColumnExpr("ts_headline('english', hits.title, hits.query, ?) AS title_highlight",
+				fmt.Sprintf("HighlightAll=true, StartSel=%s, StopSel=%s", HighlightStart, HighlightStop)).
+			ColumnExpr("ts_headline('english', coalesce(hits.description, ''), hits.query, ?) AS snippet",
+				fmt.Sprintf("MaxFragments=2, MaxWords=16, MinWords=6, StartSel=%s, StopSel=%s", HighlightStart, HighlightStop)).
+			OrderExpr("hits.rank DESC, hits.id DESC")
+	}
+
+	var hits []searchHit
+	if err := q.Scan(ctx, &hits); err != nil {
+		return nil, "", translateError(err)
+	}
+
+	nextPageToken := ""
+	if len(hits) > w.size {
+		hits = hits[:w.size]
+		nextPageToken = w.nextOffsetToken()
+	}
+	if len(hits) == 0 {
+		return nil, nextPageToken, nil
+	}
+
+	ids := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	var tasks []*models.Task
+	err = r.db.NewSelect().
+		Model(&tasks).
+		Relation("Tags", orderTagsByName).
+		Where("t.id IN (?)", bun.In(ids)).
+		Scan(ctx)
+	if err != nil {
+		return nil, "", translateError(err)
+	}
+
+	byID := make(map[string]*models.Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+	matches := make([]*TaskMatch, 0, len(hits))
+	for _, hit := range hits {
+		// a task deleted in between the two queries is simply left out
+		if task, ok := byID[hit.ID]; ok {
+			matches = append(matches, &TaskMatch{Task: task, Rank: hit.Rank, Title: hit.TitleHighlight, Snippet: hit.Snippet})
+		}
+	}
+	return matches, nextPageToken, nil
+}
+
+func (s *MemoryTaskStore) SearchTasks(ctx context.Context, query string, page TaskPage) ([]*TaskMatch, string, error) {
+	terms, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page.OrderBy = searchOrder
+	w, err := page.resolve()
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matches []*TaskMatch
+	for _, stored := range s.tasks {
+		if !visibleTo(ctx, stored.OwnerID) || !stored.DeletedAt.IsZero() {
+			continue
+		}
+		if match, ok := matchTask(stored, terms); ok {
+			match.Task = s.load(stored)
+			matches = append(matches, match)
+		}
+	}
+	slices.SortFunc(matches, func(a, b *TaskMatch) int {
+		if c := cmp.Compare(b.Rank, a.Rank); c != 0 {
+			return c
+		}
+		return strings.Compare(b.Task.ID, a.Task.ID)
+	})
+
+	matches = matches[min(w.cursor.Offset, len(matches)):]
+	if len(matches) <= w.size {
+		return matches, "", nil
+	}
+	return matches[:w.size], w.nextOffsetToken(), nil
+}
+
+// A word of some text, lowercased, along with where it sits in the text
+type textWord struct {
+	word       string
+	start, end int
+}
+
+// Splits text into its words, runs of letters and digits
+func splitWords(text string) []textWord {
+	var words []textWord
+	start := -1
+	for i, r := range text + " " {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			words = append(words, textWord{word: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	return words
+}
+
+// Marks the words at which the term occurs in words, along with the
+// words its phrase carries on over, and returns how often it occurs
+func (t searchTerm) mark(words []textWord, marked map[int]bool) int {
+	occurrences := 0
+	for i := 0; i+len(t.words) <= len(words); i++ {
+		matches := true
+		for j, word := range t.words {
+			last := j == len(t.words)-1
+			if words[i+j].word != word && !(last && t.prefix && strings.HasPrefix(words[i+j].word, word)) {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		occurrences++
+		for j := range t.words {
+			marked[i+j] = true
+		}
+	}
+	return occurrences
+}
+
+// Matches the terms against a task the way our databases would, short of
+// stemming words: every term has to occur in the title or the description.
+// Title matches rank twice as high as description ones. The match is
+// left for the caller to fill in the task of.
+func matchTask(task *models.Task, terms []searchTerm) (*TaskMatch, bool) {
+	titleWords, descriptionWords := splitWords(task.Title), splitWords(task.Description)
+	titleMarks, descriptionMarks := map[int]bool{}, map[int]bool{}
+
+	match := &TaskMatch{}
+	for _, term := range terms {
+		inTitle, inDescription := term.mark(titleWords, titleMarks), term.mark(descriptionWords, descriptionMarks)
+		if inTitle+inDescription == 0 {
+			return nil, false
+		}
+		match.Rank += float64(2*inTitle + inDescription)
+	}
+
+	match.Title = highlight(task.Title, titleWords, titleMarks, 0, len(titleWords))
+
+	// an excerpt of snippetWords words, starting a little ahead of the first match
+	const snippetWords = 16
+	from := 0
+	for i := range descriptionWords {
+		if descriptionMarks[i] {
+			from = max(0, min(i-3, len(descriptionWords)-snippetWords))
+			break
+		}
+	}
+	match.Snippet = highlight(task.Description, descriptionWords, descriptionMarks, from, min(from+snippetWords, len(descriptionWords)))
+	return match, true
+}
+
+// Cuts words[from:to] out of text, wrapping the marked words in our
+// highlights and eliding whatever was cut off with an ellipsis
+func highlight(text string, words []textWord, marked map[int]bool, from, to int) string {
+	if from >= to {
+		return ""
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("… ")
+	}
+	position := words[from].start
+	for i := from; i < to; i++ {
+		b.WriteString(text[position:words[i].start])
+		if marked[i] {
+			b.WriteString(HighlightStart + text[words[i].start:words[i].end] + HighlightStop)
+		} else {
+			b.WriteString(text[words[i].start:words[i].end])
+		}
+		position = words[i].end
+	}
+	if to < len(words) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+)
+
+// Selects the ids of every task below the given one, however deep.
+// UNION rather than UNION ALL keeps the walk finite even on bad data.
+const descendantIDsQuery = `WITH RECURSIVE subtree AS (
+	SELECT id FROM tasks WHERE parent_id = ?
+	UNION
+	SELECT tasks.id FROM tasks JOIN subtree ON tasks.parent_id = subtree.id
+) SELECT id FROM subtree`
+
+// Selects the ids of the given task and every task above it
+const ancestorIDsQuery = `WITH RECURSIVE lineage AS (
+	SELECT id, parent_id FROM tasks WHERE id = ?
+	UNION
+	SELECT tasks.id, tasks.parent_id FROM tasks JOIN lineage ON tasks.id = lineage.parent_id
+) SELECT id FROM lineage`
+
+// Lists every task below the given one, however deep, in creation order.
+// Use models.BuildTaskTree to assemble them into a tree.
+func (r *TaskRepository) ListDescendants(ctx context.Context, id string) ([]*models.Task, error) {
+	if err := checkUUID(id); err != nil {
+		return nil, err
+	}
+
+	var tasks []*models.Task
+
+	err := r.db.NewSelect().
+		Model(&tasks).
+		Relation("Tags", orderTagsByName).
+		Where("t.id IN ("+descendantIDsQuery+")", id).
+		ApplyQueryBuilder(ownedBy(ctx, "t.owner_id")).
+		Order("t.created_at ASC", "t.id ASC").
+		Scan(ctx)
+	return tasks, translateError(err)
+}
+
+// Counts the tasks below the given one that are neither done nor cancelled
+func (r *TaskRepository) CountOpenDescendants(ctx context.Context, id string) (int, error) {
+	if err := checkUUID(id); err != nil {
+		return 0, err
+	}
+
+	count, err := r.db.NewSelect().
+		Model((*models.Task)(nil)).
+		Where("t.id IN ("+descendantIDsQuery+")", id).
+		Where("t.status NOT IN (?)", bun.In([]models.TaskStatus{models.StatusDone, models.StatusCancelled})).
+		ApplyQueryBuilder(ownedBy(ctx, "t.owner_id")).
+		Count(ctx)
+	return count, translateError(err)
+}
+
+// Saves the task like UpdateTask and marks every open task below it as
+// done, all or nothing. See TaskStore.
+func (r *TaskRepository) CompleteTaskTree(ctx context.Context, task *models.Task, now time.Time, columns ...string) error {
+	if err := checkUUID(task.ID); err != nil {
+		return err
+	}
+
+	read := task.Version
+	task.Version++
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := updateTask(ctx, tx, task, read, columns); err != nil {
+			return err
+		}
+
+		_, err := tx.NewUpdate().
+			Model((*models.Task)(nil)).
+			Set("status = ?", models.StatusDone).
+			Set("completed_at = ?", now).
+			Set("updated_at = ?", now).
+			Set("version = version + 1").
+			Where("id IN ("+descendantIDsQuery+")", task.ID).
+			Where("status NOT IN (?)", bun.In([]models.TaskStatus{models.StatusDone, models.StatusCancelled})).
+			ApplyQueryBuilder(ownedBy(ctx, "owner_id")).
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		task.Version = read
+	}
+	return translateError(err)
+}
+
+// Makes sure task may be placed under its ParentID: the parent has to
+// exist and must not be the task itself or one of its subtasks, which
+// would turn our tree into a cycle.
+func checkParent(ctx context.Context, db bun.IDB, task *models.Task) error {
+	if task.ParentID == "" {
+		return nil
+	}
+	if task.ParentID == task.ID {
+		return fmt.Errorf("%w: a task cannot be its own parent", ErrInvalidArgument)
+	}
+
+	// the parent has to be one of the caller's own tasks
+	err := db.NewSelect().
+		Model((*models.Task)(nil)).
+		Column("t.id").
+		Where("t.id = ?", task.ParentID).
+		ApplyQueryBuilder(ownedBy(ctx, "t.owner_id")).
+		Scan(ctx, new(string))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: parent task %s does not exist", ErrInvalidArgument, task.ParentID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// walking up from the new parent must never lead us back to the task
+	cycles, err := db.NewSelect().
+		TableExpr("("+ancestorIDsQuery+") AS lineage", task.ParentID).
+		Where("lineage.id = ?", task.ID).
+		Count(ctx)
+	if err != nil {
+		return err
+	}
+	if cycles > 0 {
+		return fmt.Errorf("%w: task %s cannot be moved under its own subtask %s", ErrInvalidArgument, task.ID, task.ParentID)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/auth"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// keeps the tags of a task in a stable order whenever we load them
+func orderTagsByName(q *bun.SelectQuery) *bun.SelectQuery {
+	return q.Order("tg.name ASC")
+}
+
+// Tags are unique by name per owner, with the tags of unowned tasks
+// counting as one more owner. Our unique index on tags spells it the same
+// way, so inserts can tell a tag already exists.
+const tagConflict = "CONFLICT (coalesce(owner_id, '00000000-0000-0000-0000-000000000000'), name) DO NOTHING"
+
+// Scopes a tags query down to the tags of the given owner, the unowned
+// ones when ownerID is empty
+func tagsOf(ownerID string) func(bun.QueryBuilder) bun.QueryBuilder {
+	return func(q bun.QueryBuilder) bun.QueryBuilder {
+		if ownerID == "" {
+			return q.Where("tg.owner_id IS NULL")
+		}
+		return q.Where("tg.owner_id = ?", ownerID)
+	}
+}
+
+// Replaces the tags linked to a task with task.Tags, creating tags that
+// do not exist yet. Tasks carry the tags of their own owner, whoever
+// saves them. task.Tags is refreshed with the stored rows.
+func setTaskTags(ctx context.Context, tx bun.Tx, task *models.Task) error {
+	var ownerID sql.NullString
+	err := tx.NewSelect().
+		Model((*models.Task)(nil)).
+		Column("owner_id").
+		Where("t.id = ?", task.ID).
+		WhereAllWithDeleted().
+		Scan(ctx, &ownerID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.NewDelete().Model((*models.TaskTag)(nil)).Where("task_id = ?", task.ID).Exec(ctx); err != nil {
+		return err
+	}
+
+	tags, err := upsertTags(ctx, tx, ownerID.String, models.TagNames(task.Tags))
+	if err != nil {
+		return err
+	}
+	task.Tags = tags
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	links := make([]*models.TaskTag, 0, len(tags))
+	for _, tag := range tags {
+		links = append(links, &models.TaskTag{TaskID: task.ID, TagID: tag.ID})
+	}
+	_, err = tx.NewInsert().Model(&links).Exec(ctx)
+	return err
+}
+
+// Makes sure the owner has a tag for every name and returns them ordered
+// by name
+func upsertTags(ctx context.Context, tx bun.IDB, ownerID string, names []string) ([]*models.Tag, error) {
+	tags := make([]*models.Tag, 0, len(names))
+	if len(names) == 0 {
+		return tags, nil
+	}
+
+	for _, name := range names {
+		tags = append(tags, &models.Tag{ID: uuid.New().String(), OwnerID: ownerID, Name: name})
+	}
+
+	if _, err := tx.NewInsert().Model(&tags).On(tagConflict).Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	// tags that already existed keep their original id, so we read them back
+	tags = tags[:0]
+	err := tx.NewSelect().
+		Model(&tags).
+		Where("tg.name IN (?)", bun.In(names)).
+		ApplyQueryBuilder(tagsOf(ownerID)).
+		Order("tg.name ASC").
+		Scan(ctx)
+	return tags, err
+}
+
+// tasks in the trash keep their tags, but are not counted as carrying them
+const untrashedTaskIDsQuery = "SELECT id FROM tasks WHERE deleted_at IS NULL"
+
+// Lists the caller's tags along with the number of tasks carrying each
+func (r *TaskRepository) ListTags(ctx context.Context) ([]*models.Tag, error) {
+	var tags []*models.Tag
+
+	err := r.db.NewSelect().
+		Model(&tags).
+		ColumnExpr("tg.*").
+		ColumnExpr("count(tt.task_id) AS task_count").
+		Join("LEFT JOIN task_tags AS tt ON tt.tag_id = tg.id AND tt.task_id IN (" + untrashedTaskIDsQuery + ")").
+		ApplyQueryBuilder(ownedBy(ctx, "tg.owner_id")).
+		Group("tg.id").
+		Order("tg.name ASC").
+		Scan(ctx)
+	return tags, translateError(err)
+}
+
+// Renames a tag. Every task carrying it follows along.
+func (r *TaskRepository) RenameTag(ctx context.Context, name, newName string) (*models.Tag, error) {
+	tag := new(models.Tag)
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().
+			Model(tag).
+			Where("tg.name = ?", name).
+			ApplyQueryBuilder(ownedBy(ctx, "tg.owner_id")).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: tag %q does not exist", ErrNotFound, name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := touchTaggedTasks(ctx, tx, []string{tag.ID}); err != nil {
+			return err
+		}
+		tag.Name = newName
+		if _, err := tx.NewUpdate().Model(tag).Column("name").WherePK().Exec(ctx); err != nil {
+			return err
+		}
+		return countTaggedTasks(ctx, tx, tag)
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return tag, nil
+}
+
+// Moves every task tagged with one of the sources over to target and
+// removes the sources. The target tag is created for the caller when
+// missing.
+func (r *TaskRepository) MergeTags(ctx context.Context, sources []string, target string) (*models.Tag, error) {
+	var merged *models.Tag
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var sourceTags []*models.Tag
+		err := tx.NewSelect().
+			Model(&sourceTags).
+			Where("tg.name IN (?)", bun.In(sources)).
+			ApplyQueryBuilder(ownedBy(ctx, "tg.owner_id")).
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		if len(sourceTags) != len(sources) {
+			return fmt.Errorf("%w: not every tag in %q exists", ErrNotFound, sources)
+		}
+
+		ownerID, _ := auth.UserID(ctx)
+		tags, err := upsertTags(ctx, tx, ownerID, []string{target})
+		if err != nil {
+			return err
+		}
+		merged = tags[0]
+
+		var sourceIDs []string
+		for _, tag := range sourceTags {
+			if tag.ID != merged.ID {
+				sourceIDs = append(sourceIDs, tag.ID)
+			}
+		}
+		if len(sourceIDs) == 0 {
+			return nil
+		}
+		if err := touchTaggedTasks(ctx, tx, sourceIDs); err != nil {
+			return err
+		}
+
+		// tasks already carrying the target keep a single link to it
+		_, err = tx.NewRaw(
+			"INSERT INTO task_tags (task_id, tag_id) SELECT DISTINCT task_id, ? FROM task_tags WHERE tag_id IN (?) ON CONFLICT DO NOTHING",
+			merged.ID, bun.In(sourceIDs),
+		).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.NewDelete().Model((*models.TaskTag)(nil)).Where("tag_id IN (?)", bun.In(sourceIDs)).Exec(ctx); err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*models.Tag)(nil)).Where("id IN (?)", bun.In(sourceIDs)).Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		return countTaggedTasks(ctx, tx, merged)
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return merged, nil
+}
+
+// Deletes a tag, untagging every task that carried it
+func (r *TaskRepository) DeleteTag(ctx context.Context, name string) error {
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		tag := new(models.Tag)
+		err := tx.NewSelect().
+			Model(tag).
+			Where("tg.name = ?", name).
+			ApplyQueryBuilder(ownedBy(ctx, "tg.owner_id")).
+			Scan(ctx)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: tag %q does not exist", ErrNotFound, name)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := touchTaggedTasks(ctx, tx, []string{tag.ID}); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*models.TaskTag)(nil)).Where("tag_id = ?", tag.ID).Exec(ctx); err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().Model((*models.Tag)(nil)).Where("id = ?", tag.ID).Exec(ctx)
+		return err
+	})
+	return translateError(err)
+}
+
+// A task's tags are part of it, so changing a tag changes every task
+// carrying it: each gets a new version, turning away writers still holding
+// the one before, and is stamped as updated. Call it before changing the
+// tags, see the task_events migrations.
+func touchTaggedTasks(ctx context.Context, tx bun.Tx, tagIDs []string) error {
+	_, err := tx.NewUpdate().
+		Model((*models.Task)(nil)).
+		Set("version = version + 1").
+		Set("updated_at = ?", time.Now()).
+		Where("id IN (SELECT task_id FROM task_tags WHERE tag_id IN (?))", bun.In(tagIDs)).
+		WhereAllWithDeleted().
+		Exec(ctx)
+	return err
+}
+
+// fills in the number of tasks carrying the tag
+func countTaggedTasks(ctx context.Context, db bun.IDB, tag *models.Tag) error {
+	count, err := db.NewSelect().
+		Model((*models.TaskTag)(nil)).
+		Where("tag_id = ?", tag.ID).
+		Where("task_id IN (" + untrashedTaskIDsQuery + ")").
+		Count(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	tag.TaskCount = count
+	return nil
+}
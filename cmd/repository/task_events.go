@@ -0,0 +1,521 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/lib/pq"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// the channel our Postgres trigger notifies of every task event
+const taskEventsChannel = "task_events"
+
+// how many events a watcher reads at a time
+const eventBatchSize = 100
+
+// How often watchers look for new events without being told of any. While
+// a listener tells them of every event that is only a safety net, without
+// one, as on SQLite, it is how they find events at all.
+const (
+	eventPollInterval    = time.Second
+	listenedPollInterval = 30 * time.Second
+)
+
+// Wakes the watchers of a store up whenever there may be new events for
+// them. The zero value is ready to use.
+type eventHub struct {
+	mu       sync.Mutex
+	watchers map[chan struct{}]struct{}
+	// set while a listener is connected and telling us of every event
+	listening atomic.Bool
+}
+
+func (h *eventHub) subscribe() chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.watchers == nil {
+		h.watchers = map[chan struct{}]struct{}{}
+	}
+	// a single pending wake up is enough, the watcher reads every event there is
+	wake := make(chan struct{}, 1)
+	h.watchers[wake] = struct{}{}
+	return wake
+}
+
+func (h *eventHub) unsubscribe(wake chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.watchers, wake)
+}
+
+func (h *eventHub) notify() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for wake := range h.watchers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (h *eventHub) pollInterval() time.Duration {
+	if h.listening.Load() {
+		return listenedPollInterval
+	}
+	return eventPollInterval
+}
+
+// Where a watcher is at: right past the event with the given id, written
+// by transaction txID. Events are read in (txID, id) order.
+type eventPosition struct {
+	txID, id int64
+}
+
+// Reads up to limit of the caller's events after the given position,
+// oldest first, with their snapshots read back
+type eventReader func(ctx context.Context, after eventPosition, limit int) ([]*models.TaskEvent, error)
+
+// Hands every event after the given position that passes filter to emit,
+// then waits for more, until ctx is done or emit fails
+func watchEvents(ctx context.Context, hub *eventHub, after eventPosition, filter TaskFilter, read eventReader, emit func(*models.TaskEvent) error) error {
+	wake := hub.subscribe()
+	defer hub.unsubscribe(wake)
+
+	for {
+		events, err := read(ctx, after, eventBatchSize)
+		if err != nil {
+			return err
+		}
+		// a watch can outlast the day its query's relative days were worked out on
+		filter = filter.rolledOver(time.Now())
+		for _, event := range events {
+			after = eventPosition{event.TxID, event.ID}
+			event, ok := filter.admit(event)
+			if !ok {
+				continue
+			}
+			if err := emit(event); err != nil {
+				return err
+			}
+		}
+		if len(events) == eventBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		case <-time.After(hub.pollInterval()):
+		}
+	}
+}
+
+// Works out what a watcher with this filter gets to see of the event, if
+// anything. Going by the task as it was on either side of the change, the
+// event goes out when it matched on one of them; an update that takes the
+// task out of the filter goes out as TaskLeft, so the watcher can let go
+// of it. Deleted tasks are only ever looked at as they were before.
+func (f TaskFilter) admit(event *models.TaskEvent) (*models.TaskEvent, bool) {
+	matched := func(task *models.Task) bool {
+		return task != nil && f.matches(task, models.TagNames(task.Tags))
+	}
+
+	switch {
+	case event.Type == models.TaskDeleted:
+		return event, matched(event.Previous)
+	case matched(event.Task):
+		return event, true
+	case event.Type == models.TaskUpdated && matched(event.Previous):
+		left := *event
+		left.Type = models.TaskLeft
+		return &left, true
+	}
+	return nil, false
+}
+
+// Returns the filter with Today moved along to the day it is at now, in
+// the time zone Today was taken in
+func (f TaskFilter) rolledOver(now time.Time) TaskFilter {
+	if f.Today.IsZero() {
+		return f
+	}
+	now = now.In(f.Today.Location())
+	f.Today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return f
+}
+
+// Works out the id of the event a watcher resumes after, given the oldest
+// and latest event we still keep; zero for both when there are none. An
+// empty cursor starts after the latest event.
+func resumeAfter(cursor string, oldest, latest int64) (int64, error) {
+	if cursor == "" {
+		return latest, nil
+	}
+
+	after, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || after < 0 {
+		return 0, fmt.Errorf("%w: %q is not a valid cursor", ErrInvalidArgument, cursor)
+	}
+	// the events between the cursor and the oldest we keep have been pruned,
+	// and a cursor past the latest event comes from some other database
+	if after > latest || (oldest > 0 && after < oldest-1) {
+		return 0, fmt.Errorf("%w: the events after %s are no longer kept", ErrCursorExpired, cursor)
+	}
+	return after, nil
+}
+
+// Calls emit with every event on the caller's tasks after the one cursor
+// names, then with every new one as it is written, until ctx is done or
+// emit fails. See TaskStore.WatchTasks.
+func (r *TaskRepository) WatchTasks(ctx context.Context, filter TaskFilter, cursor string, emit func(*models.TaskEvent) error) error {
+	after, err := r.eventCursor(ctx, cursor)
+	if err != nil {
+		return err
+	}
+	return watchEvents(ctx, &r.events, after, filter, r.readEvents, emit)
+}
+
+// Fails the way WatchTasks would with this cursor, without watching
+func (r *TaskRepository) CheckEventCursor(ctx context.Context, cursor string) error {
+	_, err := r.eventCursor(ctx, cursor)
+	return err
+}
+
+// Works out the position a watcher starting from cursor is at. An empty
+// cursor is past the last event readEvents would hand out right now.
+func (r *TaskRepository) eventCursor(ctx context.Context, cursor string) (eventPosition, error) {
+	var after eventPosition
+	if cursor == "" {
+		err := r.db.NewSelect().
+			Model((*models.TaskEvent)(nil)).
+			Column("e.txid", "e.id").
+			ApplyQueryBuilder(r.settledEvents).
+			OrderExpr("e.txid DESC, e.id DESC").
+			Limit(1).
+			Scan(ctx, &after.txID, &after.id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return after, nil
+		}
+		return after, translateError(err)
+	}
+
+	var oldest, latest int64
+	err := r.db.NewSelect().
+		Model((*models.TaskEvent)(nil)).
+		ColumnExpr("coalesce(min(e.id), 0), coalesce(max(e.id), 0)").
+		Scan(ctx, &oldest, &latest)
+	if err != nil {
+		return after, translateError(err)
+	}
+
+	if after.id, err = resumeAfter(cursor, oldest, latest); err != nil || after.id == 0 {
+		return after, err
+	}
+	err = r.db.NewSelect().
+		Model((*models.TaskEvent)(nil)).
+		Column("e.txid").
+		Where("e.id = ?", after.id).
+		Scan(ctx, &after.txID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// without the event we cannot tell which of the ones we keep came after it
+		return after, fmt.Errorf("%w: the events after %s are no longer kept", ErrCursorExpired, cursor)
+	}
+	return after, translateError(err)
+}
+
+// Leaves out the events of transactions that may still be running, along
+// with those of every later transaction. See readEvents.
+func (r *TaskRepository) settledEvents(q bun.QueryBuilder) bun.QueryBuilder {
+	if r.db.Dialect().Name() != dialect.PG {
+		return q
+	}
+	return q.Where("e.txid < pg_snapshot_xmin(pg_current_snapshot())::text::bigint")
+}
+
+// Reads events by the transaction that wrote them, then by id. Ids alone
+// won't do on Postgres, as they are handed out when events are written
+// rather than when they commit: a watcher past one id could still see a
+// lower one commit afterwards and miss it. So we only read the events of
+// transactions older than any still running, whose events are all there
+// is to see of them, and every transaction that commits later has an id
+// of at least the oldest running one, sorting its events after these.
+//
+// The price is that events wait for every older transaction to end before
+// they go out, however unrelated.
+func (r *TaskRepository) readEvents(ctx context.Context, after eventPosition, limit int) ([]*models.TaskEvent, error) {
+	var events []*models.TaskEvent
+
+	err := r.db.NewSelect().
+		Model(&events).
+		Where("(e.txid, e.id) > (?, ?)", after.txID, after.id).
+		ApplyQueryBuilder(r.settledEvents).
+		ApplyQueryBuilder(ownedBy(ctx, "e.owner_id")).
+		OrderExpr("e.txid ASC, e.id ASC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil || len(events) == 0 {
+		return nil, translateError(err)
+	}
+
+	for _, event := range events {
+		if err := readSnapshots(event); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// A task the way events note it down, see the task_snapshot function of
+// our Postgres migrations and the task_snapshots view of our SQLite ones
+type taskSnapshot struct {
+	ID          string              `json:"id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Status      models.TaskStatus   `json:"status"`
+	Priority    models.TaskPriority `json:"priority"`
+	Version     int64               `json:"version"`
+	CreatedAt   snapshotTime        `json:"created_at"`
+	UpdatedAt   snapshotTime        `json:"updated_at"`
+	CompletedAt snapshotTime        `json:"completed_at"`
+	StartAt     snapshotTime        `json:"start_at"`
+	DueAt       snapshotTime        `json:"due_at"`
+	DeletedAt   snapshotTime        `json:"deleted_at"`
+	ParentID    string              `json:"parent_id"`
+	OwnerID     string              `json:"owner_id"`
+	ProjectID   string              `json:"project_id"`
+	Tags        []string            `json:"tags"`
+}
+
+// Timestamps come out of Postgres in RFC 3339 and out of SQLite the way
+// they were written, which bun reads either way
+type snapshotTime struct {
+	bun.NullTime
+}
+
+func (t *snapshotTime) UnmarshalJSON(b []byte) error {
+	var s *string
+	if err := json.Unmarshal(b, &s); err != nil || s == nil {
+		return err
+	}
+	return t.Scan(*s)
+}
+
+func newTaskSnapshot(task *models.Task) taskSnapshot {
+	return taskSnapshot{
+		ID:          task.ID,
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      task.Status,
+		Priority:    task.Priority,
+		Version:     task.Version,
+		CreatedAt:   snapshotTime{bun.NullTime{Time: task.CreatedAt}},
+		UpdatedAt:   snapshotTime{task.UpdatedAt},
+		CompletedAt: snapshotTime{task.CompletedAt},
+		StartAt:     snapshotTime{task.StartAt},
+		DueAt:       snapshotTime{task.DueAt},
+		DeletedAt:   snapshotTime{task.DeletedAt},
+		ParentID:    task.ParentID,
+		OwnerID:     task.OwnerID,
+		ProjectID:   task.ProjectID,
+		Tags:        models.TagNames(task.Tags),
+	}
+}
+
+func (snapshot taskSnapshot) task() *models.Task {
+	task := &models.Task{
+		ID:          snapshot.ID,
+		Title:       snapshot.Title,
+		Description: snapshot.Description,
+		Status:      snapshot.Status,
+		Priority:    snapshot.Priority,
+		Version:     snapshot.Version,
+		CreatedAt:   snapshot.CreatedAt.Time,
+		UpdatedAt:   snapshot.UpdatedAt.NullTime,
+		CompletedAt: snapshot.CompletedAt.NullTime,
+		StartAt:     snapshot.StartAt.NullTime,
+		DueAt:       snapshot.DueAt.NullTime,
+		DeletedAt:   snapshot.DeletedAt.NullTime,
+		ParentID:    snapshot.ParentID,
+		OwnerID:     snapshot.OwnerID,
+		ProjectID:   snapshot.ProjectID,
+		Tags:        make([]*models.Tag, 0, len(snapshot.Tags)),
+	}
+	for _, name := range snapshot.Tags {
+		task.Tags = append(task.Tags, &models.Tag{Name: name, OwnerID: snapshot.OwnerID})
+	}
+	sortTagsByName(task.Tags)
+	return task
+}
+
+// Fills in the event's Task and Previous from its snapshots
+func readSnapshots(event *models.TaskEvent) error {
+	read := func(raw json.RawMessage) (*models.Task, error) {
+		if len(raw) == 0 {
+			return nil, nil
+		}
+		var snapshot taskSnapshot
+		if err := json.Unmarshal(raw, &snapshot); err != nil {
+			return nil, fmt.Errorf("reading event %d: %w", event.ID, err)
+		}
+		return snapshot.task(), nil
+	}
+
+	var err error
+	if event.Task, err = read(event.TaskSnapshot); err != nil {
+		return err
+	}
+	event.Previous, err = read(event.PreviousSnapshot)
+	return err
+}
+
+// Deletes the events written before the given time and returns how many
+// there were. The latest event is always kept, so cursors past the pruned
+// ones can still be told apart from ones we never handed out.
+func (r *TaskRepository) PruneTaskEvents(ctx context.Context, before time.Time) (int, error) {
+	result, err := r.db.NewDelete().
+		Model((*models.TaskEvent)(nil)).
+		Where("created_at < ?", before).
+		Where("id < (SELECT max(id) FROM task_events)").
+		Exec(ctx)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	pruned, err := result.RowsAffected()
+	return int(pruned), translateError(err)
+}
+
+// Listens for the notifications our trigger sends on every task event,
+// waking watchers up as soon as events are written by any instance of our
+// service, until ctx is done. Postgres only; dsn is the database's
+// connection string, as notifications need a connection of their own.
+//
+// Watchers find new events without a listener too, only slower.
+func (r *TaskRepository) ListenForEvents(ctx context.Context, dsn string) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventConnected, pq.ListenerEventReconnected:
+			r.events.listening.Store(true)
+			// we may have missed notifications while reconnecting
+			r.events.notify()
+		case pq.ListenerEventDisconnected, pq.ListenerEventConnectionAttemptFailed:
+			r.events.listening.Store(false)
+		}
+	})
+	defer listener.Close()
+	defer r.events.listening.Store(false)
+
+	if err := listener.Listen(taskEventsChannel); err != nil {
+		return translateError(err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-listener.Notify:
+			r.events.notify()
+		case <-time.After(90 * time.Second):
+			// a quiet connection may have died without us noticing
+			go listener.Ping()
+		}
+	}
+}
+
+func (s *MemoryTaskStore) WatchTasks(ctx context.Context, filter TaskFilter, cursor string, emit func(*models.TaskEvent) error) error {
+	after, err := s.eventCursor(cursor)
+	if err != nil {
+		return err
+	}
+	return watchEvents(ctx, &s.hub, after, filter, s.readEvents, emit)
+}
+
+func (s *MemoryTaskStore) CheckEventCursor(ctx context.Context, cursor string) error {
+	_, err := s.eventCursor(cursor)
+	return err
+}
+
+func (s *MemoryTaskStore) eventCursor(cursor string) (eventPosition, error) {
+	s.mu.RLock()
+	var oldest, latest int64
+	if len(s.events) > 0 {
+		oldest, latest = s.events[0].ID, s.events[len(s.events)-1].ID
+	}
+	s.mu.RUnlock()
+
+	after, err := resumeAfter(cursor, oldest, latest)
+	return eventPosition{id: after}, err
+}
+
+// Our events are written under s.mu, so their ids alone are in commit order
+func (s *MemoryTaskStore) readEvents(ctx context.Context, after eventPosition, limit int) ([]*models.TaskEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []*models.TaskEvent
+	start := sort.Search(len(s.events), func(i int) bool { return s.events[i].ID > after.id })
+	for _, recorded := range s.events[start:] {
+		if len(events) == limit {
+			break
+		}
+		if !visibleTo(ctx, recorded.OwnerID) {
+			continue
+		}
+		event := *recorded
+		if err := readSnapshots(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+func (s *MemoryTaskStore) PruneTaskEvents(ctx context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// like TaskRepository, we always keep the latest event
+	pruned := 0
+	for pruned < len(s.events)-1 && s.events[pruned].CreatedAt.Before(before) {
+		pruned++
+	}
+	s.events = s.events[pruned:]
+	return pruned, nil
+}
+
+// Records an event about a stored task and wakes the watchers up, what the
+// triggers on our tasks table do for TaskRepository. previous is the task
+// as loaded right before the change, nil for new ones. Callers hold s.mu.
+func (s *MemoryTaskStore) recordEvent(previous, stored *models.Task, eventType models.TaskEventType) {
+	event := &models.TaskEvent{
+		ID:        s.lastEventID + 1,
+		TaskID:    stored.ID,
+		OwnerID:   stored.OwnerID,
+		Type:      eventType,
+		CreatedAt: time.Now(),
+	}
+	// the snapshots are ours alone, so marshalling them cannot fail
+	event.TaskSnapshot, _ = json.Marshal(newTaskSnapshot(s.load(stored)))
+	if previous != nil {
+		event.PreviousSnapshot, _ = json.Marshal(newTaskSnapshot(previous))
+	}
+
+	s.lastEventID++
+	s.events = append(s.events, event)
+	s.hub.notify()
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/query"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+)
+
+// Narrows down the tasks returned by ListTasks. The zero value
+// matches every task.
+type TaskFilter struct {
+	// only the direct subtasks of this task
+	ParentID string
+	// only the tasks filed under this project
+	ProjectID string
+
+	// inclusive lower bound on due_at
+	DueAfter time.Time
+	// exclusive upper bound on due_at
+	DueBefore time.Time
+	// only tasks without a deadline
+	NoDueDate bool
+	// leaves out done and cancelled tasks
+	OpenOnly bool
+
+	// case-insensitive substring match on the title
+	TitleContains string
+	// inclusive lower and exclusive upper bounds on created_at
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// inclusive lower and exclusive upper bounds on updated_at
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	// tasks carrying at least one of these tags
+	TagsAny []string
+	// tasks carrying every one of these tags
+	TagsAll []string
+
+	// tasks matching a parsed query, on top of every other field
+	Query query.Node
+	// the start of the caller's day in their time zone, which the days in
+	// Query are worked out from
+	Today time.Time
+}
+
+// escapes the LIKE wildcards so titles are matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// appends the filter's WHERE clauses onto a tasks query
+func (f TaskFilter) apply(q *bun.SelectQuery) *bun.SelectQuery {
+	if f.ParentID != "" {
+		q = q.Where("t.parent_id = ?", f.ParentID)
+	}
+	if f.ProjectID != "" {
+		q = q.Where("t.project_id = ?", f.ProjectID)
+	}
+	if f.NoDueDate {
+		q = q.Where("t.due_at IS NULL")
+	}
+	if !f.DueAfter.IsZero() {
+		q = q.Where("t.due_at >= ?", f.DueAfter)
+	}
+	if !f.DueBefore.IsZero() {
+		q = q.Where("t.due_at < ?", f.DueBefore)
+	}
+	if f.OpenOnly {
+		q = q.Where("t.status NOT IN (?)", bun.In([]models.TaskStatus{models.StatusDone, models.StatusCancelled}))
+	}
+	if f.TitleContains != "" {
+		// rather than ILIKE, which SQLite lacks; LIKE there has no escape character by default either
+		q = q.Where(`lower(t.title) LIKE ? ESCAPE '\'`, containsPattern(f.TitleContains))
+	}
+	if !f.CreatedAfter.IsZero() {
+		q = q.Where("t.created_at >= ?", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		q = q.Where("t.created_at < ?", f.CreatedBefore)
+	}
+	if !f.UpdatedAfter.IsZero() {
+		q = q.Where("t.updated_at >= ?", f.UpdatedAfter)
+	}
+	if !f.UpdatedBefore.IsZero() {
+		q = q.Where("t.updated_at < ?", f.UpdatedBefore)
+	}
+	if len(f.TagsAny) > 0 {
+		q = q.Where(
+			"t.id IN (SELECT tt.task_id FROM task_tags AS tt JOIN tags AS tg ON tg.id = tt.tag_id WHERE tg.name IN (?))",
+			bun.In(f.TagsAny),
+		)
+	}
+	if len(f.TagsAll) > 0 {
+		q = q.Where(
+			"t.id IN (SELECT tt.task_id FROM task_tags AS tt JOIN tags AS tg ON tg.id = tt.tag_id WHERE tg.name IN (?) GROUP BY tt.task_id HAVING count(DISTINCT tg.name) = ?)",
+			bun.In(f.TagsAll), len(f.TagsAll),
+		)
+	}
+	if f.Query != nil {
+		q = q.Where("?", compileQuery(f.Query, f.Today))
+	}
+	return q
+}
+
+// The in-memory counterpart of apply, reporting whether the task, carrying
+// the given tag names, passes every clause of the filter
+func (f TaskFilter) matches(task *models.Task, tags []string) bool {
+	switch {
+	case f.ParentID != "" && task.ParentID != f.ParentID:
+		return false
+	case f.ProjectID != "" && task.ProjectID != f.ProjectID:
+		return false
+	case f.NoDueDate && !task.DueAt.IsZero():
+		return false
+	case !f.DueAfter.IsZero() && (task.DueAt.IsZero() || task.DueAt.Time.Before(f.DueAfter)):
+		return false
+	case !f.DueBefore.IsZero() && (task.DueAt.IsZero() || !task.DueAt.Time.Before(f.DueBefore)):
+		return false
+	case f.OpenOnly && task.Status.IsClosed():
+		return false
+	case f.TitleContains != "" && !strings.Contains(strings.ToLower(task.Title), strings.ToLower(f.TitleContains)):
+		return false
+	case !f.CreatedAfter.IsZero() && task.CreatedAt.Before(f.CreatedAfter):
+		return false
+	case !f.CreatedBefore.IsZero() && !task.CreatedAt.Before(f.CreatedBefore):
+		return false
+	case !f.UpdatedAfter.IsZero() && (task.UpdatedAt.IsZero() || task.UpdatedAt.Time.Before(f.UpdatedAfter)):
+		return false
+	case !f.UpdatedBefore.IsZero() && (task.UpdatedAt.IsZero() || !task.UpdatedAt.Time.Before(f.UpdatedBefore)):
+		return false
+	}
+
+	carried := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		carried[tag] = true
+	}
+	if len(f.TagsAny) > 0 && !slices.ContainsFunc(f.TagsAny, func(tag string) bool { return carried[tag] }) {
+		return false
+	}
+	for _, tag := range f.TagsAll {
+		if !carried[tag] {
+			return false
+		}
+	}
+	return f.Query == nil || matchesQuery(f.Query, task, tags, f.Today)
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+)
+
+// The number of tasks counted by CountTasks that fall into one group
+type TaskGroup struct {
+	// the status, the priority's name, the project id or the tag name the
+	// tasks share, empty for tasks in no project or without tags
+	Key   string `bun:"group_key"`
+	Count int    `bun:"task_count"`
+}
+
+// statuses are grouped in the order a task goes through them
+var statusOrder = []models.TaskStatus{
+	models.StatusTodo,
+	models.StatusInProgress,
+	models.StatusBlocked,
+	models.StatusDone,
+	models.StatusCancelled,
+}
+
+// Counts the tasks matching filter and, given a grouping, how many fall
+// into each group. Tasks carrying several tags count towards each of them.
+func (r *TaskRepository) CountTasks(ctx context.Context, filter TaskFilter, groupBy string) (int, []*TaskGroup, error) {
+	tasks := func() *bun.SelectQuery {
+		q := r.db.NewSelect().Model((*models.Task)(nil)).ApplyQueryBuilder(ownedBy(ctx, "t.owner_id"))
+		return filter.apply(q)
+	}
+
+	total, err := tasks().Count(ctx)
+	if err != nil {
+		return 0, nil, translateError(err)
+	}
+	if groupBy == "" {
+		return total, nil, nil
+	}
+
+	q := tasks().ColumnExpr("count(*) AS task_count")
+	switch groupBy {
+	case models.GroupByStatus:
+		q = q.ColumnExpr("t.status AS group_key").Group("t.status")
+	case models.GroupByPriority:
+		q = q.ColumnExpr("CAST(t.priority AS TEXT) AS group_key").Group("t.priority")
+	case models.GroupByProject:
+		q = q.ColumnExpr("coalesce(CAST(t.project_id AS TEXT), '') AS group_key").Group("t.project_id")
+	case models.GroupByTag:
+		q = q.ColumnExpr("coalesce(tg.name, '') AS group_key").
+			Join("LEFT JOIN task_tags AS tt ON tt.task_id = t.id").
+			Join("LEFT JOIN tags AS tg ON tg.id = tt.tag_id").
+			Group("tg.name")
+	default:
+		return 0, nil, fmt.Errorf("%w: cannot group tasks by %q", ErrInvalidArgument, groupBy)
+	}
+
+	var groups []*TaskGroup
+	if err := q.Scan(ctx, &groups); err != nil {
+		return 0, nil, translateError(err)
+	}
+	if groupBy == models.GroupByPriority {
+		for _, group := range groups {
+			priority, _ := strconv.Atoi(group.Key)
+			group.Key = models.TaskPriority(priority).String()
+		}
+	}
+
+	sortTaskGroups(groups, groupBy)
+	return total, groups, nil
+}
+
+func (s *MemoryTaskStore) CountTasks(ctx context.Context, filter TaskFilter, groupBy string) (int, []*TaskGroup, error) {
+	var key func(task *models.Task) []string
+	switch groupBy {
+	case "":
+	case models.GroupByStatus:
+		key = func(task *models.Task) []string { return []string{string(task.Status)} }
+	case models.GroupByPriority:
+		key = func(task *models.Task) []string { return []string{task.Priority.String()} }
+	case models.GroupByProject:
+		key = func(task *models.Task) []string { return []string{task.ProjectID} }
+	case models.GroupByTag:
+		key = func(task *models.Task) []string {
+			if len(task.Tags) == 0 {
+				return []string{""}
+			}
+			return models.TagNames(task.Tags)
+		}
+	default:
+		return 0, nil, fmt.Errorf("%w: cannot group tasks by %q", ErrInvalidArgument, groupBy)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	total := 0
+	counts := map[string]int{}
+	for _, stored := range s.tasks {
+		if !visibleTo(ctx, stored.OwnerID) || !stored.DeletedAt.IsZero() {
+			continue
+		}
+		task := s.load(stored)
+		if !filter.matches(task, models.TagNames(task.Tags)) {
+			continue
+		}
+		total++
+		if key != nil {
+			for _, k := range key(task) {
+				counts[k]++
+			}
+		}
+	}
+	if key == nil {
+		return total, nil, nil
+	}
+
+	groups := make([]*TaskGroup, 0, len(counts))
+	for k, count := range counts {
+		groups = append(groups, &TaskGroup{Key: k, Count: count})
+	}
+	sortTaskGroups(groups, groupBy)
+	return total, groups, nil
+}
+
+// Puts groups in the order we list them in: statuses along the lifecycle,
+// priorities from urgent down and anything else by key, with the group of
+// tasks in no project or without tags last
+func sortTaskGroups(groups []*TaskGroup, groupBy string) {
+	rank := func(group *TaskGroup) int { return 0 }
+	switch groupBy {
+	case models.GroupByStatus:
+		rank = func(group *TaskGroup) int { return slices.Index(statusOrder, models.TaskStatus(group.Key)) }
+	case models.GroupByPriority:
+		rank = func(group *TaskGroup) int {
+			priority, _ := models.ParsePriority(group.Key)
+			return -int(priority)
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b *TaskGroup) int {
+		if c := cmp.Compare(rank(a), rank(b)); c != 0 {
+			return c
+		}
+		if (a.Key == "") != (b.Key == "") {
+			if a.Key == "" {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+}
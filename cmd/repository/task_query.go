@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/query"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// the columns the date fields of a query compare against
+var queryDateColumns = map[query.Field]string{
+	query.FieldDue:     "t.due_at",
+	query.FieldCreated: "t.created_at",
+	query.FieldUpdated: "t.updated_at",
+}
+
+// Compiles a parsed query into a condition on tasks queries, for use with
+// Where("?", ...). Days in the query are worked out from today.
+//
+// Every condition comes out as either true or false, never NULL, so NOT
+// turns a comparison against a task without a deadline into a match just
+// like matchesQuery does.
+func compileQuery(node query.Node, today time.Time) schema.QueryWithArgs {
+	switch n := node.(type) {
+	case *query.And:
+		return joinConditions(n.Terms, " AND ", today)
+	case *query.Or:
+		return joinConditions(n.Terms, " OR ", today)
+	case *query.Not:
+		return bun.SafeQuery("NOT (?)", compileQuery(n.Term, today))
+	case *query.Text:
+		pattern := containsPattern(n.Text)
+		return bun.SafeQuery(
+			`lower(t.title) LIKE ? ESCAPE '\' OR lower(coalesce(t.description, '')) LIKE ? ESCAPE '\'`,
+			pattern, pattern,
+		)
+	case *query.Comparison:
+		return compileComparison(n, today)
+	}
+	panic(fmt.Sprintf("unknown query node %T", node))
+}
+
+// Compiles the terms and joins them with sep, each in parentheses of its own
+func joinConditions(terms []query.Node, sep string, today time.Time) schema.QueryWithArgs {
+	placeholders := make([]string, 0, len(terms))
+	args := make([]interface{}, 0, len(terms))
+	for _, term := range terms {
+		placeholders = append(placeholders, "(?)")
+		args = append(args, compileQuery(term, today))
+	}
+	return bun.SafeQuery(strings.Join(placeholders, sep), args...)
+}
+
+func compileComparison(c *query.Comparison, today time.Time) schema.QueryWithArgs {
+	if c.Op == query.OpNotEqual {
+		equal := *c
+		equal.Op = query.OpEqual
+		return bun.SafeQuery("NOT (?)", compileComparison(&equal, today))
+	}
+
+	switch value := c.Value.(type) {
+	case query.StatusValue:
+		return bun.SafeQuery("t.status IN (?)", bun.In([]models.TaskStatus(value)))
+	case query.PriorityValue:
+		return bun.SafeQuery("t.priority "+sqlOperator(c.Op)+" ?", models.TaskPriority(value))
+	case query.NoneValue:
+		return bun.SafeQuery("? IS NULL", bun.Ident(queryDateColumns[c.Field]))
+	case query.DateValue:
+		column := bun.Ident(queryDateColumns[c.Field])
+		start, end := dayBounds(value, c.Op, today)
+		switch {
+		case start.IsZero():
+			return bun.SafeQuery("? IS NOT NULL AND ? < ?", column, column, end)
+		case end.IsZero():
+			return bun.SafeQuery("? IS NOT NULL AND ? >= ?", column, column, start)
+		default:
+			return bun.SafeQuery("? IS NOT NULL AND ? >= ? AND ? < ?", column, column, start, column, end)
+		}
+	case query.StringValue:
+		switch c.Field {
+		case query.FieldTag:
+			return bun.SafeQuery(
+				"t.id IN (SELECT tt.task_id FROM task_tags AS tt JOIN tags AS tg ON tg.id = tt.tag_id WHERE tg.name = ?)",
+				string(value),
+			)
+		case query.FieldTitle:
+			return bun.SafeQuery(`lower(t.title) LIKE ? ESCAPE '\'`, containsPattern(string(value)))
+		case query.FieldProject:
+			return bun.SafeQuery("t.project_id IS NOT NULL AND t.project_id = ?", string(value))
+		}
+	}
+	panic(fmt.Sprintf("cannot compare %s against %T", c.Field, c.Value))
+}
+
+func sqlOperator(op query.Operator) string {
+	if op == query.OpEqual {
+		return "="
+	}
+	return string(op)
+}
+
+// Works out the range of times a comparison against a day covers, as an
+// inclusive start and an exclusive end. A zero bound is left open.
+func dayBounds(day query.DateValue, op query.Operator, today time.Time) (time.Time, time.Time) {
+	start := day.Resolve(today)
+	next := start.AddDate(0, 0, 1)
+
+	switch op {
+	case query.OpLess:
+		return time.Time{}, start
+	case query.OpLessEqual:
+		return time.Time{}, next
+	case query.OpGreater:
+		return next, time.Time{}
+	case query.OpGreaterEqual:
+		return start, time.Time{}
+	default:
+		return start, next
+	}
+}
+
+// Builds a LIKE pattern matching anything containing text, regardless of case
+func containsPattern(text string) string {
+	return "%" + likeEscaper.Replace(strings.ToLower(text)) + "%"
+}
+
+// The in-memory counterpart of compileQuery, reporting whether the task,
+// carrying the given tag names, matches the query
+func matchesQuery(node query.Node, task *models.Task, tags []string, today time.Time) bool {
+	switch n := node.(type) {
+	case *query.And:
+		for _, term := range n.Terms {
+			if !matchesQuery(term, task, tags, today) {
+				return false
+			}
+		}
+		return true
+	case *query.Or:
+		for _, term := range n.Terms {
+			if matchesQuery(term, task, tags, today) {
+				return true
+			}
+		}
+		return false
+	case *query.Not:
+		return !matchesQuery(n.Term, task, tags, today)
+	case *query.Text:
+		text := strings.ToLower(n.Text)
+		return strings.Contains(strings.ToLower(task.Title), text) || strings.Contains(strings.ToLower(task.Description), text)
+	case *query.Comparison:
+		return matchesComparison(n, task, tags, today)
+	}
+	panic(fmt.Sprintf("unknown query node %T", node))
+}
+
+func matchesComparison(c *query.Comparison, task *models.Task, tags []string, today time.Time) bool {
+	if c.Op == query.OpNotEqual {
+		equal := *c
+		equal.Op = query.OpEqual
+		return !matchesComparison(&equal, task, tags, today)
+	}
+
+	switch value := c.Value.(type) {
+	case query.StatusValue:
+		return slices.Contains(value, task.Status)
+	case query.PriorityValue:
+		priority, than := task.Priority, models.TaskPriority(value)
+		switch c.Op {
+		case query.OpLess:
+			return priority < than
+		case query.OpLessEqual:
+			return priority <= than
+		case query.OpGreater:
+			return priority > than
+		case query.OpGreaterEqual:
+			return priority >= than
+		default:
+			return priority == than
+		}
+	case query.NoneValue:
+		_, set := taskDate(task, c.Field)
+		return !set
+	case query.DateValue:
+		date, set := taskDate(task, c.Field)
+		start, end := dayBounds(value, c.Op, today)
+		return set && (start.IsZero() || !date.Before(start)) && (end.IsZero() || date.Before(end))
+	case query.StringValue:
+		switch c.Field {
+		case query.FieldTag:
+			return slices.Contains(tags, string(value))
+		case query.FieldTitle:
+			return strings.Contains(strings.ToLower(task.Title), strings.ToLower(string(value)))
+		case query.FieldProject:
+			return task.ProjectID == string(value)
+		}
+	}
+	panic(fmt.Sprintf("cannot compare %s against %T", c.Field, c.Value))
+}
+
+// Looks up the date a query field stands for on the task, and whether it is set
+func taskDate(task *models.Task, field query.Field) (time.Time, bool) {
+	switch field {
+	case query.FieldDue:
+		return task.DueAt.Time, !task.DueAt.IsZero()
+	case query.FieldUpdated:
+		return task.UpdatedAt.Time, !task.UpdatedAt.IsZero()
+	default:
+		return task.CreatedAt, !task.CreatedAt.IsZero()
+	}
+}
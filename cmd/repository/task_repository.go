@@ -2,6 +2,10 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/50-Course/notes-tracker/shared/models"
 	"github.com/google/uuid"
@@ -10,39 +14,195 @@ import (
 
 type TaskRepository struct {
 	db *bun.DB
+	// wakes up the watchers of task events, see ListenForEvents
+	events eventHub
 }
 
 func NewTaskRepository(db *bun.DB) *TaskRepository {
+	// bun needs the join model registered before it can resolve Task.Tags
+	db.RegisterModel((*models.TaskTag)(nil))
 	return &TaskRepository{db: db}
 }
 
+// Inserts the task along with its tags, creating any tag we have not seen before
 func (r *TaskRepository) CreateTask(ctx context.Context, task *models.Task) error {
 	task.ID = uuid.New().String()
-	_, err := r.db.NewInsert().Model(task).Exec(ctx)
-	return err
+	assignOwner(ctx, task)
+	if task.Status == "" {
+		task.Status = models.StatusTodo
+	}
+	// rather than the column default, which SQLite writes in a format of its
+	// own that does not compare with the timestamps we write
+	if task.CreatedAt.IsZero() {
+		task.CreatedAt = time.Now()
+	}
+	task.Version = 1
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkParent(ctx, tx, task); err != nil {
+			return err
+		}
+		if err := checkProject(ctx, tx, task); err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(task).Exec(ctx); err != nil {
+			return err
+		}
+		return setTaskTags(ctx, tx, task)
+	})
+	return translateError(err)
 }
 
 func (r *TaskRepository) GetTask(ctx context.Context, id string) (*models.Task, error) {
+	if err := checkUUID(id); err != nil {
+		return nil, err
+	}
+
 	task := new(models.Task)
-	err := r.db.NewSelect().Model(task).Where("id = ?", id).Scan(ctx)
+	err := r.db.NewSelect().
+		Model(task).
+		Relation("Tags", orderTagsByName).
+		Where("t.id = ?", id).
+		ApplyQueryBuilder(ownedBy(ctx, "t.owner_id")).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: task %s does not exist", ErrNotFound, id)
+	}
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return task, nil
 }
 
-func (r *TaskRepository) ListTasks(ctx context.Context) ([]*models.Task, error) {
+// Lists a single page of the tasks matching filter, along with the token
+// to fetch the page after it. The token is empty on the last page.
+func (r *TaskRepository) ListTasks(ctx context.Context, filter TaskFilter, page TaskPage) ([]*models.Task, string, error) {
 	var tasks []*models.Task
-	err := r.db.NewSelect().Model(&tasks).Scan(ctx)
-	return tasks, err
+
+	q := r.db.NewSelect().Model(&tasks).Relation("Tags", orderTagsByName).ApplyQueryBuilder(ownedBy(ctx, "t.owner_id"))
+	q, paginate, err := page.apply(filter.apply(q))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return nil, "", translateError(err)
+	}
+
+	tasks, nextPageToken := paginate(tasks)
+	return tasks, nextPageToken, nil
 }
 
-func (r *TaskRepository) UpdateTask(ctx context.Context, task *models.Task) error {
-	_, err := r.db.NewUpdate().Model(task).Where("id = ?", task.ID).Exec(ctx)
-	return err
+// Saves the task, replacing its tags with the ones it currently carries.
+// Given columns, only those are written, see TaskStore.
+//
+// The task is only saved if it is still at the version it was read at,
+// which is then bumped; otherwise ErrStale is returned.
+func (r *TaskRepository) UpdateTask(ctx context.Context, task *models.Task, columns ...string) error {
+	if err := checkUUID(task.ID); err != nil {
+		return err
+	}
+	read := task.Version
+	task.Version++
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return updateTask(ctx, tx, task, read, columns)
+	})
+	if err != nil {
+		task.Version = read
+	}
+	return translateError(err)
 }
 
-func (r *TaskRepository) DeleteTask(ctx context.Context, id string) error {
-	_, err := r.db.NewDelete().Model((*models.Task)(nil)).Where("id = ?", id).Exec(ctx)
-	return err
+// Writes the task within tx as long as it is still at version read, see
+// UpdateTask
+func updateTask(ctx context.Context, tx bun.Tx, task *models.Task, read int64, columns []string) error {
+	written, replaceTags := splitTagsColumn(columns)
+	if err := checkParent(ctx, tx, task); err != nil {
+		return err
+	}
+	if err := checkProject(ctx, tx, task); err != nil {
+		return err
+	}
+
+	q := tx.NewUpdate().
+		Model(task).
+		Where("id = ?", task.ID).
+		Where("version = ?", read).
+		ApplyQueryBuilder(ownedBy(ctx, "owner_id"))
+	if len(columns) > 0 {
+		q = q.Column(append(written, "version")...)
+	}
+
+	result, err := q.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return checkTaskVersion(ctx, tx, task.ID, read)
+	}
+	if !replaceTags {
+		return nil
+	}
+	return setTaskTags(ctx, tx, task)
+}
+
+// Works out why an update guarded by the version a task was read at
+// touched no rows: either the task is gone or someone else got there first
+func checkTaskVersion(ctx context.Context, db bun.IDB, id string, read int64) error {
+	var current int64
+	err := db.NewSelect().
+		Model((*models.Task)(nil)).
+		Column("version").
+		Where("t.id = ?", id).
+		ApplyQueryBuilder(ownedBy(ctx, "t.owner_id")).
+		Scan(ctx, &current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: task %s does not exist", ErrNotFound, id)
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: task %s is at version %d, not %d", ErrStale, id, current, read)
+}
+
+// Moves the task to the trash along with every subtask below it. Subtasks
+// already in there are stamped again, so the whole subtree comes back, and
+// eventually goes, together.
+func (r *TaskRepository) DeleteTask(ctx context.Context, id string, version int64) error {
+	if err := checkUUID(id); err != nil {
+		return err
+	}
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// bun turns these into UPDATEs of deleted_at, as Task is soft
+		// deleted, leaving out the tasks in the trash unless told otherwise
+		q := tx.NewDelete().
+			Model((*models.Task)(nil)).
+			Where("id = ?", id).
+			ApplyQueryBuilder(ownedBy(ctx, "owner_id"))
+		if version != AnyVersion {
+			q = q.Where("version = ?", version)
+		}
+		result, err := q.Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return checkTaskVersion(ctx, tx, id, version)
+		}
+
+		var subtree []string
+		if err := tx.NewRaw(descendantIDsQuery, id).Scan(ctx, &subtree); err != nil || len(subtree) == 0 {
+			return err
+		}
+		_, err = tx.NewDelete().
+			Model((*models.Task)(nil)).
+			Where("id IN (?)", bun.In(subtree)).
+			WhereAllWithDeleted().
+			ApplyQueryBuilder(ownedBy(ctx, "owner_id")).
+			Exec(ctx)
+		return err
+	})
+	return translateError(err)
 }
@@ -3,14 +3,25 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
-	_ "time"
+	"time"
 
+	"github.com/50-Course/notes-tracker/cmd/query"
+	"github.com/50-Course/notes-tracker/scripts/migrations"
+	"github.com/50-Course/notes-tracker/shared/auth"
 	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/50-Course/notes-tracker/shared/utils"
+	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/driver/pgdriver"
 	"github.com/uptrace/bun/extra/bundebug"
@@ -36,8 +47,10 @@ func setupTestDB() *bun.DB {
 	testDB.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
 
 	// apply migrations
-	_, _ = testDB.NewDropTable().Model((*models.Task)(nil)).IfExists().IfExists().Cascade().Exec(context.Background())
-	_, err := testDB.NewCreateTable().Model((*models.Task)(nil)).IfNotExists().Exec(context.Background())
+	for _, model := range []interface{}{(*models.TaskEvent)(nil), (*models.SavedView)(nil), (*models.TaskTag)(nil), (*models.Tag)(nil), (*models.Task)(nil), (*models.Project)(nil), (*models.APIKey)(nil), (*models.User)(nil), (*migrations.AppliedMigration)(nil)} {
+		_, _ = testDB.NewDropTable().Model(model).IfExists().Cascade().Exec(context.Background())
+	}
+	err := migrations.RunMigrations(testDB)
 	if err != nil {
 		log.Fatalf("Database Integrity Error: Unable to apply migrations: %v", err)
 	}
@@ -52,8 +65,718 @@ func setupTestDB() *bun.DB {
 	return testDB
 }
 
+// Runs the same checks against any TaskStore, so our stores are held to
+// identical semantics. Every task is tagged with a tag of its own, keeping
+// the checks clear of whatever else the store holds.
+func testTaskStore(t *testing.T, store TaskStore) {
+	ctx := context.Background()
+	label := "store-" + uuid.New().String()[:8]
+	base := time.Now().Add(-time.Hour).Truncate(time.Microsecond)
+
+	create := func(title string, offset time.Duration, priority models.TaskPriority) *models.Task {
+		task := &models.Task{
+			Title:     title,
+			CreatedAt: base.Add(offset),
+			Priority:  priority,
+			Tags:      []*models.Tag{{Name: label}},
+		}
+		if err := store.CreateTask(ctx, task); err != nil {
+			t.Fatalf("Error creating task: %v", err)
+		}
+		return task
+	}
+
+	first := create("Store task one", 0, models.PriorityLow)
+	second := create("Store task two", time.Second, models.PriorityUrgent)
+	third := create("Store task three", 2*time.Second, models.PriorityMedium)
+
+	t.Run("Report Missing Tasks", func(t *testing.T) {
+		if _, err := store.GetTask(ctx, uuid.New().String()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound fetching a missing task, got %v", err)
+		}
+		if err := store.UpdateTask(ctx, &models.Task{ID: uuid.New().String(), Title: "Ghost"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound updating a missing task, got %v", err)
+		}
+		if _, err := store.GetTask(ctx, "not-a-uuid"); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected ErrInvalidArgument fetching a malformed id, got %v", err)
+		}
+	})
+
+	t.Run("List In Order", func(t *testing.T) {
+		filter := TaskFilter{TagsAll: []string{label}}
+
+		tasks, nextPageToken, err := store.ListTasks(ctx, filter, TaskPage{Size: 2})
+		if err != nil {
+			t.Fatalf("Fetch operation failed: %v", err)
+		}
+		if len(tasks) != 2 || tasks[0].ID != first.ID || tasks[1].ID != second.ID || nextPageToken == "" {
+			t.Fatalf("Expected the two oldest tasks and a next page, got %d tasks", len(tasks))
+		}
+
+		tasks, nextPageToken, err = store.ListTasks(ctx, filter, TaskPage{Size: 2, Token: nextPageToken})
+		if err != nil {
+			t.Fatalf("Paged fetch operation failed: %v", err)
+		}
+		if len(tasks) != 1 || tasks[0].ID != third.ID || nextPageToken != "" {
+			t.Errorf("Expected only the newest task on the last page, got %d tasks", len(tasks))
+		}
+
+		byPriority := TaskPage{OrderBy: []TaskSort{{Column: "priority", Desc: true}}}
+		tasks, _, err = store.ListTasks(ctx, filter, byPriority)
+		if err != nil {
+			t.Fatalf("Sorted fetch operation failed: %v", err)
+		}
+		if len(tasks) != 3 || tasks[0].ID != second.ID || tasks[1].ID != third.ID || tasks[2].ID != first.ID {
+			t.Error("Expected tasks ordered urgent, medium, low")
+		}
+
+		// a task written in between pages must not shift the next page
+		byPriority.Size = 2
+		tasks, nextPageToken, err = store.ListTasks(ctx, filter, byPriority)
+		if err != nil || len(tasks) != 2 || nextPageToken == "" {
+			t.Fatalf("Expected a first page of 2 sorted tasks, got %d tasks, %v", len(tasks), err)
+		}
+		inserted := create("Store task four", 3*time.Second, models.PriorityHigh)
+		defer func() {
+			_ = store.DeleteTask(ctx, inserted.ID, AnyVersion)
+			_ = store.PermanentlyDeleteTask(ctx, inserted.ID)
+		}()
+
+		byPriority.Token = nextPageToken
+		tasks, _, err = store.ListTasks(ctx, filter, byPriority)
+		if err != nil {
+			t.Fatalf("Sorted paged fetch operation failed: %v", err)
+		}
+		if len(tasks) != 1 || tasks[0].ID != first.ID {
+			t.Errorf("Expected only the low priority task on the next page, got %d tasks", len(tasks))
+		}
+
+		// every task is missing a due date, so all of them tie on it
+		byDue := TaskPage{Size: 1, OrderBy: []TaskSort{{Column: "due_at"}, {Column: "priority", Desc: true}}}
+		var ids []string
+		for {
+			tasks, nextPageToken, err = store.ListTasks(ctx, filter, byDue)
+			if err != nil {
+				t.Fatalf("Paged fetch by due date failed: %v", err)
+			}
+			for _, task := range tasks {
+				ids = append(ids, task.ID)
+			}
+			if nextPageToken == "" || len(ids) > 4 {
+				break
+			}
+			byDue.Token = nextPageToken
+		}
+		if want := []string{second.ID, inserted.ID, third.ID, first.ID}; !slices.Equal(ids, want) {
+			t.Error("Expected paging by due date to walk every task once, by priority")
+		}
+	})
+
+	t.Run("Filter By Query", func(t *testing.T) {
+		queried := "query-" + uuid.New().String()[:8]
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+
+		tasks := map[string]*models.Task{
+			"overdue": {Title: "Chase the login bug", Description: "Reported by support", Priority: models.PriorityHigh,
+				DueAt: bun.NullTime{Time: today.Add(-23 * time.Hour)}},
+			"later": {Title: "Write release notes", Description: "Mention the login bug fix", Priority: models.PriorityLow,
+				DueAt: bun.NullTime{Time: today.AddDate(0, 0, 3)}},
+			"done": {Title: "Ship login page", Status: models.StatusDone, Tags: []*models.Tag{{Name: "backend"}}},
+		}
+		for _, task := range tasks {
+			task.Tags = append(task.Tags, &models.Tag{Name: queried})
+			if err := store.CreateTask(ctx, task); err != nil {
+				t.Fatalf("Failed to create task: %v", err)
+			}
+		}
+
+		queries := map[string][]string{
+			"due<today":                            {"overdue"},
+			"due>=today":                           {"later"},
+			"due:none":                             {"done"},
+			"-due<today":                           {"later", "done"},
+			`status:open "login bug"`:              {"overdue", "later"},
+			"(status:closed OR priority>=high)":    {"overdue", "done"},
+			"tag:backend":                          {"done"},
+			"title:login -title:ship":              {"overdue"},
+			"NOT tag:backend AND priority!=medium": {"overdue", "later"},
+		}
+		for input, expected := range queries {
+			node, err := query.Parse("tag:" + queried + " " + input)
+			if err != nil {
+				t.Fatalf("Failed to parse %s: %v", input, err)
+			}
+
+			found, _, err := store.ListTasks(ctx, TaskFilter{Query: node, Today: today}, TaskPage{})
+			if err != nil {
+				t.Errorf("Failed to list tasks matching %s: %v", input, err)
+				continue
+			}
+			if len(found) != len(expected) {
+				t.Errorf("Expected %d tasks matching %s, got %d", len(expected), input, len(found))
+				continue
+			}
+			for _, name := range expected {
+				if !containsTask(found, tasks[name].ID) {
+					t.Errorf("Expected the %s task to match %s", name, input)
+				}
+			}
+		}
+	})
+
+	t.Run("Count Tasks", func(t *testing.T) {
+		counted := "count-" + uuid.New().String()[:8]
+		tasks := []*models.Task{
+			{Title: "Counted task one", Priority: models.PriorityHigh, Tags: []*models.Tag{{Name: counted + "-a"}}},
+			{Title: "Counted task two", Status: models.StatusInProgress, Priority: models.PriorityHigh},
+			{Title: "Counted task three", Status: models.StatusDone, Priority: models.PriorityLow,
+				Tags: []*models.Tag{{Name: counted + "-a"}, {Name: counted + "-b"}}},
+		}
+		for _, task := range tasks {
+			task.Tags = append(task.Tags, &models.Tag{Name: counted})
+			if err := store.CreateTask(ctx, task); err != nil {
+				t.Fatalf("Failed to create task: %v", err)
+			}
+		}
+		filter := TaskFilter{TagsAll: []string{counted}}
+
+		groupings := map[string]string{
+			"":                     "",
+			models.GroupByStatus:   "todo=1 in_progress=1 done=1",
+			models.GroupByPriority: "high=2 low=1",
+			models.GroupByProject:  "=3",
+			models.GroupByTag:      fmt.Sprintf("%s=3 %s-a=2 %s-b=1", counted, counted, counted),
+		}
+		for groupBy, expected := range groupings {
+			total, groups, err := store.CountTasks(ctx, filter, groupBy)
+			if err != nil {
+				t.Errorf("Failed to count tasks by %q: %v", groupBy, err)
+				continue
+			}
+			if total != len(tasks) {
+				t.Errorf("Expected %d tasks in total, got %d", len(tasks), total)
+			}
+			counts := make([]string, 0, len(groups))
+			for _, group := range groups {
+				counts = append(counts, fmt.Sprintf("%s=%d", group.Key, group.Count))
+			}
+			if got := strings.Join(counts, " "); got != expected {
+				t.Errorf("Expected the tasks grouped by %q to count %q, got %q", groupBy, expected, got)
+			}
+		}
+
+		if _, _, err := store.CountTasks(ctx, filter, "owner"); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected ErrInvalidArgument grouping by owner, got %v", err)
+		}
+	})
+
+	t.Run("Update Tasks", func(t *testing.T) {
+		task, err := store.GetTask(ctx, first.ID)
+		if err != nil {
+			t.Fatalf("Failed to get task: %v", err)
+		}
+		task.Title = "Store task one, renamed"
+		task.Tags = append(task.Tags, &models.Tag{Name: label + "-extra"})
+		if err := store.UpdateTask(ctx, task); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+
+		// what we got back must not be tied to what the store holds
+		task.Title = "Changed after saving"
+
+		saved, _ := store.GetTask(ctx, first.ID)
+		if saved.Title != "Store task one, renamed" {
+			t.Errorf("Expected the saved title, got %q", saved.Title)
+		}
+		if names := models.TagNames(saved.Tags); len(names) != 2 || names[0] != label || names[1] != label+"-extra" {
+			t.Errorf("Expected tags [%s %s-extra], got %v", label, label, names)
+		}
+	})
+
+	t.Run("Update Only Some Columns", func(t *testing.T) {
+		task, err := store.GetTask(ctx, third.ID)
+		if err != nil {
+			t.Fatalf("Failed to get task: %v", err)
+		}
+
+		task.Title = "Store task three, renamed"
+		task.Description = "Never saved"
+		task.Tags = nil
+		if err := store.UpdateTask(ctx, task, "title"); err != nil {
+			t.Fatalf("Failed to update the title: %v", err)
+		}
+
+		saved, _ := store.GetTask(ctx, third.ID)
+		if saved.Title != "Store task three, renamed" || saved.Description != "" {
+			t.Errorf("Expected only the title to be written, got %q and %q", saved.Title, saved.Description)
+		}
+		if names := models.TagNames(saved.Tags); len(names) != 1 || names[0] != label {
+			t.Errorf("Expected the tags left alone, got %v", names)
+		}
+
+		if err := store.UpdateTask(ctx, task, TagsColumn); err != nil {
+			t.Fatalf("Failed to update the tags: %v", err)
+		}
+		if saved, _ := store.GetTask(ctx, third.ID); len(saved.Tags) != 0 {
+			t.Errorf("Expected the tags cleared, got %v", models.TagNames(saved.Tags))
+		}
+
+		// the checks below still count on the task carrying our tag
+		task.Tags = []*models.Tag{{Name: label}}
+		if err := store.UpdateTask(ctx, task, TagsColumn); err != nil {
+			t.Fatalf("Failed to restore the tags: %v", err)
+		}
+	})
+
+	t.Run("Reject Stale Updates", func(t *testing.T) {
+		stale, err := store.GetTask(ctx, third.ID)
+		if err != nil {
+			t.Fatalf("Failed to get task: %v", err)
+		}
+
+		// someone else changes the task in the meantime
+		fresh, _ := store.GetTask(ctx, third.ID)
+		fresh.Description = "Written by someone else"
+		if err := store.UpdateTask(ctx, fresh, "description"); err != nil {
+			t.Fatalf("Failed to update the description: %v", err)
+		}
+		if fresh.Version != stale.Version+1 {
+			t.Errorf("Expected the version bumped to %d, got %d", stale.Version+1, fresh.Version)
+		}
+
+		stale.Title = "Overwrites someone else"
+		if err := store.UpdateTask(ctx, stale, "title"); !errors.Is(err, ErrStale) {
+			t.Fatalf("Expected ErrStale writing a stale task, got %v", err)
+		}
+		if stale.Version != fresh.Version-1 {
+			t.Errorf("Expected a rejected write to leave the version at %d, got %d", fresh.Version-1, stale.Version)
+		}
+
+		saved, _ := store.GetTask(ctx, third.ID)
+		if saved.Title == stale.Title || saved.Description != fresh.Description || saved.Version != fresh.Version {
+			t.Errorf("Expected only the first write to land, got %q and %q at version %d", saved.Title, saved.Description, saved.Version)
+		}
+	})
+
+	t.Run("Nest Subtasks", func(t *testing.T) {
+		child := &models.Task{Title: "Store subtask", ParentID: second.ID, Tags: []*models.Tag{{Name: label}}}
+		if err := store.CreateTask(ctx, child); err != nil {
+			t.Fatalf("Failed to create subtask: %v", err)
+		}
+
+		parent, _ := store.GetTask(ctx, second.ID)
+		parent.ParentID = child.ID
+		if err := store.UpdateTask(ctx, parent); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected moving a task under its own subtask to fail, got %v", err)
+		}
+
+		if open, _ := store.CountOpenDescendants(ctx, second.ID); open != 1 {
+			t.Errorf("Expected 1 open subtask, got %d", open)
+		}
+		// a forced completion of a task someone changed since is turned away whole
+		parent, _ = store.GetTask(ctx, second.ID)
+		stale := *parent
+		stale.Version--
+		if err := stale.TransitionTo(models.StatusDone, time.Now()); err != nil {
+			t.Fatalf("Failed to complete task: %v", err)
+		}
+		if err := store.CompleteTaskTree(ctx, &stale, time.Now(), "status", "completed_at"); !errors.Is(err, ErrStale) {
+			t.Errorf("Expected completing a stale task to fail with ErrStale, got %v", err)
+		}
+		if open, _ := store.CountOpenDescendants(ctx, second.ID); open != 1 {
+			t.Errorf("Expected the subtask left open along with its stale parent, got %d open", open)
+		}
+
+		if err := parent.TransitionTo(models.StatusDone, time.Now()); err != nil {
+			t.Fatalf("Failed to complete task: %v", err)
+		}
+		if err := store.CompleteTaskTree(ctx, parent, time.Now(), "status", "completed_at"); err != nil {
+			t.Fatalf("Failed to complete task and subtasks: %v", err)
+		}
+		if open, _ := store.CountOpenDescendants(ctx, second.ID); open != 0 {
+			t.Errorf("Expected no open subtasks, got %d", open)
+		}
+		if saved, _ := store.GetTask(ctx, second.ID); saved.Status != models.StatusDone {
+			t.Errorf("Expected the task completed along with its subtasks, got %q", saved.Status)
+		}
+
+		if err := store.DeleteTask(ctx, second.ID, AnyVersion); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		if _, err := store.GetTask(ctx, child.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the subtask to be deleted along with its parent, got %v", err)
+		}
+	})
+
+	t.Run("Trash And Restore", func(t *testing.T) {
+		parent := &models.Task{Title: "Store trashed parent"}
+		if err := store.CreateTask(ctx, parent); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		child := &models.Task{Title: "Store trashed subtask", ParentID: parent.ID}
+		if err := store.CreateTask(ctx, child); err != nil {
+			t.Fatalf("Failed to create subtask: %v", err)
+		}
+
+		// only the version last read gets to go
+		if err := store.DeleteTask(ctx, parent.ID, parent.Version+1); !errors.Is(err, ErrStale) {
+			t.Errorf("Expected ErrStale deleting a version the task is not at, got %v", err)
+		}
+		if _, err := store.GetTask(ctx, parent.ID); err != nil {
+			t.Errorf("Expected the task to stay out of the trash, got %v", err)
+		}
+		if err := store.DeleteTask(ctx, parent.ID, parent.Version); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		if _, err := store.GetTask(ctx, child.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the subtask to go into the trash with its parent, got %v", err)
+		}
+		if err := store.DeleteTask(ctx, parent.ID, AnyVersion); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound deleting a task already in the trash, got %v", err)
+		}
+
+		trash, _, err := store.ListTrash(ctx, TaskPage{Size: MaxPageSize})
+		if err != nil {
+			t.Fatalf("Failed to list the trash: %v", err)
+		}
+		if !containsTask(trash, parent.ID) || containsTask(trash, child.ID) {
+			t.Errorf("Expected the parent in the trash without its subtask")
+		}
+
+		if err := store.RestoreTask(ctx, child.ID, time.Now()); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected restoring a subtask ahead of its parent to fail, got %v", err)
+		}
+		if err := store.RestoreTask(ctx, parent.ID, time.Now()); err != nil {
+			t.Fatalf("Failed to restore task: %v", err)
+		}
+		restored, err := store.GetTask(ctx, child.ID)
+		if err != nil {
+			t.Fatalf("Expected the subtask restored along with its parent, got %v", err)
+		}
+		if restored.Version != child.Version+1 || !restored.DeletedAt.IsZero() {
+			t.Errorf("Expected the restored subtask at version %d, got %d", child.Version+1, restored.Version)
+		}
+		if err := store.RestoreTask(ctx, parent.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound restoring a task outside the trash, got %v", err)
+		}
+		if err := store.PermanentlyDeleteTask(ctx, parent.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound permanently deleting a task outside the trash, got %v", err)
+		}
+
+		if err := store.DeleteTask(ctx, parent.ID, AnyVersion); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		if _, err := store.PurgeTrash(ctx, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("Failed to purge the trash: %v", err)
+		}
+		if err := store.RestoreTask(ctx, parent.ID, time.Now()); err != nil {
+			t.Fatalf("Expected a freshly trashed task to outlive the purge, got %v", err)
+		}
+
+		if err := store.DeleteTask(ctx, parent.ID, AnyVersion); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		purged, err := store.PurgeTrash(ctx, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to purge the trash: %v", err)
+		}
+		if purged < 2 {
+			t.Errorf("Expected the parent and its subtask purged, got %d tasks", purged)
+		}
+		if err := store.RestoreTask(ctx, child.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the subtask gone for good, got %v", err)
+		}
+
+		doomed := &models.Task{Title: "Store doomed task"}
+		if err := store.CreateTask(ctx, doomed); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		_ = store.DeleteTask(ctx, doomed.ID, AnyVersion)
+		if err := store.PermanentlyDeleteTask(ctx, doomed.ID); err != nil {
+			t.Fatalf("Failed to permanently delete task: %v", err)
+		}
+		if err := store.RestoreTask(ctx, doomed.ID, time.Now()); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected the task gone for good, got %v", err)
+		}
+	})
+
+	t.Run("Search Tasks", func(t *testing.T) {
+		titled := &models.Task{Title: "Zanzibar expedition planning", Description: "Book the ferry"}
+		described := &models.Task{Title: "Pack bags", Description: "Everything for the zanzibar expedition"}
+		trashed := &models.Task{Title: "Zanzibar souvenirs"}
+		for _, task := range []*models.Task{titled, described, trashed} {
+			if err := store.CreateTask(ctx, task); err != nil {
+				t.Fatalf("Failed to create task: %v", err)
+			}
+		}
+		if err := store.DeleteTask(ctx, trashed.ID, AnyVersion); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+
+		matches, _, err := store.SearchTasks(ctx, "zanzibar", TaskPage{})
+		if err != nil {
+			t.Fatalf("Failed to search tasks: %v", err)
+		}
+		if len(matches) != 2 || matches[0].Task.ID != titled.ID || matches[1].Task.ID != described.ID {
+			t.Fatalf("Expected the title match ranked above the description one and the trash left out, got %d matches", len(matches))
+		}
+		if !strings.Contains(matches[0].Title, HighlightStart+"Zanzibar"+HighlightStop) {
+			t.Errorf("Expected the match highlighted in the title, got %q", matches[0].Title)
+		}
+		if !strings.Contains(matches[1].Snippet, HighlightStart+"zanzibar"+HighlightStop) {
+			t.Errorf("Expected the match highlighted in the snippet, got %q", matches[1].Snippet)
+		}
+
+		queries := map[string]int{
+			`"zanzibar expedition"`: 2,
+			`"expedition zanzibar"`: 0,
+			"zanzi*":                2,
+			"zanzibar plan*":        1,
+		}
+		for query, expected := range queries {
+			matches, _, err := store.SearchTasks(ctx, query, TaskPage{})
+			if err != nil || len(matches) != expected {
+				t.Errorf("Expected %d matches for %s, got %d and %v", expected, query, len(matches), err)
+			}
+		}
+
+		first, token, err := store.SearchTasks(ctx, "zanzibar", TaskPage{Size: 1})
+		if err != nil || len(first) != 1 || token == "" {
+			t.Fatalf("Expected a page of one match with a token, got %d and %v", len(first), err)
+		}
+		second, token, err := store.SearchTasks(ctx, "zanzibar", TaskPage{Size: 1, Token: token})
+		if err != nil || len(second) != 1 || second[0].Task.ID != described.ID || token != "" {
+			t.Errorf("Expected the last match on the second page, got %d and %v", len(second), err)
+		}
+
+		for _, query := range []string{"", " - ", `"unterminated`} {
+			if _, _, err := store.SearchTasks(ctx, query, TaskPage{}); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("Expected ErrInvalidArgument for %q, got %v", query, err)
+			}
+		}
+	})
+
+	t.Run("Watch Tasks", func(t *testing.T) {
+		watched := "watch-" + uuid.New().String()[:8]
+		filter := TaskFilter{TagsAll: []string{watched}}
+
+		task := &models.Task{Title: "Watched task", Tags: []*models.Tag{{Name: watched}}}
+		if err := store.CreateTask(ctx, task); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		task.Title = "Watched task, renamed"
+		if err := store.UpdateTask(ctx, task); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+		if err := store.DeleteTask(ctx, task.ID, AnyVersion); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		// its events outlive it
+		if err := store.PermanentlyDeleteTask(ctx, task.ID); err != nil {
+			t.Fatalf("Failed to purge task: %v", err)
+		}
+		if err := store.CreateTask(ctx, &models.Task{Title: "Unwatched task"}); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+
+		// watches from cursor in the background, stop cancels the watch
+		watch := func(cursor string) (events chan *models.TaskEvent, stop func() error) {
+			watchCtx, cancel := context.WithCancel(ctx)
+			events = make(chan *models.TaskEvent, 10)
+			done := make(chan error, 1)
+			go func() {
+				done <- store.WatchTasks(watchCtx, filter, cursor, func(event *models.TaskEvent) error {
+					events <- event
+					return nil
+				})
+			}()
+			return events, func() error {
+				cancel()
+				return <-done
+			}
+		}
+		next := func(events chan *models.TaskEvent) *models.TaskEvent {
+			select {
+			case event := <-events:
+				return event
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for a task event")
+				return nil
+			}
+		}
+
+		// every event so far, of which only those of the watched task pass
+		// the filter
+		events, stop := watch("0")
+		var first *models.TaskEvent
+		var types []string
+		for len(types) < 3 {
+			event := next(events)
+			if event.TaskID != task.ID || event.Task == nil {
+				t.Fatalf("Expected events about the watched task along with it, got %+v", event)
+			}
+			if first == nil {
+				first = event
+			}
+			types = append(types, string(event.Type))
+		}
+		if got := strings.Join(types, " "); got != "created updated deleted" {
+			t.Errorf("Expected the task to be created, updated and deleted, got %s", got)
+		}
+
+		live := &models.Task{Title: "Watched as it happens", Tags: []*models.Tag{{Name: watched}}}
+		if err := store.CreateTask(ctx, live); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		latest := next(events)
+		if latest.TaskID != live.ID || latest.Type != models.TaskCreated {
+			t.Errorf("Expected the new task to be watched as it is created, got %+v", latest)
+		}
+		// dropping the tag takes the task out of the watch
+		live.Tags = nil
+		if err := store.UpdateTask(ctx, live); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+		if event := next(events); event.TaskID != live.ID || event.Type != models.TaskLeft || len(event.Task.Tags) != 0 {
+			t.Errorf("Expected the task to leave the watch without its tag, got %+v", event)
+		}
+		if err := stop(); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the watch to end with its context, got %v", err)
+		}
+
+		events, stop = watch(fmt.Sprint(first.ID))
+		// along with the task as it was back then
+		if event := next(events); event.Type != models.TaskUpdated || event.Task.Title != task.Title || event.Previous.Title != "Watched task" {
+			t.Errorf("Expected to resume with the update after the cursor, got %+v", event)
+		}
+		_ = stop()
+
+		// relative days follow the calendar rather than the day the watch started
+		dueToday, err := query.Parse("tag:" + watched + " due:today")
+		if err != nil {
+			t.Fatalf("Failed to parse query: %v", err)
+		}
+		now := time.Now().UTC()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		filter = TaskFilter{Query: dueToday, Today: today.AddDate(0, 0, -1)}
+		events, stop = watch(fmt.Sprint(latest.ID))
+		due := &models.Task{Title: "Watched due today", DueAt: bun.NullTime{Time: today.Add(12 * time.Hour)}, Tags: []*models.Tag{{Name: watched}}}
+		if err := store.CreateTask(ctx, due); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		if event := next(events); event.TaskID != due.ID {
+			t.Errorf("Expected a task due today to pass a watch started yesterday, got %+v", event)
+		}
+		_ = stop()
+		filter = TaskFilter{TagsAll: []string{watched}}
+
+		if err := store.WatchTasks(ctx, filter, "soon", nil); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected ErrInvalidArgument for a malformed cursor, got %v", err)
+		}
+		if err := store.WatchTasks(ctx, filter, "999999999", nil); !errors.Is(err, ErrCursorExpired) {
+			t.Errorf("Expected ErrCursorExpired for a cursor we never handed out, got %v", err)
+		}
+		if err := store.CheckEventCursor(ctx, "999999999"); !errors.Is(err, ErrCursorExpired) {
+			t.Errorf("Expected CheckEventCursor to fail like WatchTasks, got %v", err)
+		}
+		if err := store.CheckEventCursor(ctx, fmt.Sprint(first.ID)); err != nil {
+			t.Errorf("Expected a cursor we handed out to check out, got %v", err)
+		}
+		if _, err := store.PruneTaskEvents(ctx, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("Failed to prune task events: %v", err)
+		}
+		if err := store.WatchTasks(ctx, filter, "0", nil); !errors.Is(err, ErrCursorExpired) {
+			t.Errorf("Expected ErrCursorExpired resuming before the pruned events, got %v", err)
+		}
+	})
+
+	t.Run("Manage Tags", func(t *testing.T) {
+		tagged, _ := store.GetTask(ctx, first.ID)
+		renamed, err := store.RenameTag(ctx, label+"-extra", label+"-renamed")
+		if err != nil {
+			t.Fatalf("Failed to rename tag: %v", err)
+		}
+		if renamed.TaskCount != 1 {
+			t.Errorf("Expected renamed tag to carry 1 task, got %d", renamed.TaskCount)
+		}
+		// the task carrying the tag changed along with it
+		if saved, _ := store.GetTask(ctx, first.ID); saved.Version != tagged.Version+1 || saved.UpdatedAt.IsZero() {
+			t.Errorf("Expected the tagged task at a new version, got version %d updated at %v", saved.Version, saved.UpdatedAt)
+		}
+		tagged.Title = "Written over a renamed tag"
+		if err := store.UpdateTask(ctx, tagged); !errors.Is(err, ErrStale) {
+			t.Errorf("Expected writing a task read before its tag was renamed to fail with ErrStale, got %v", err)
+		}
+		if _, err := store.RenameTag(ctx, label+"-renamed", label); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected renaming onto an existing tag to conflict, got %v", err)
+		}
+
+		merged, err := store.MergeTags(ctx, []string{label, label + "-renamed"}, label+"-merged")
+		if err != nil {
+			t.Fatalf("Failed to merge tags: %v", err)
+		}
+		if merged.TaskCount != 2 {
+			t.Errorf("Expected merged tag to carry 2 tasks, got %d", merged.TaskCount)
+		}
+
+		tagged, _ = store.GetTask(ctx, first.ID)
+		if err := store.DeleteTag(ctx, label+"-merged"); err != nil {
+			t.Fatalf("Failed to delete tag: %v", err)
+		}
+		if saved, _ := store.GetTask(ctx, first.ID); saved.Version != tagged.Version+1 {
+			t.Errorf("Expected deleting its tag to move the task to version %d, got %d", tagged.Version+1, saved.Version)
+		}
+		if err := store.DeleteTag(ctx, label); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected merged source tag to be gone, got %v", err)
+		}
+
+		saved, _ := store.GetTask(ctx, first.ID)
+		if len(saved.Tags) != 0 {
+			t.Errorf("Expected no tags left, got %v", models.TagNames(saved.Tags))
+		}
+	})
+}
+
+func TestMemoryTaskStore(t *testing.T) {
+	testTaskStore(t, NewMemoryTaskStore())
+
+	t.Run("Scope Tags To Their Owner", func(t *testing.T) {
+		testTagOwnership(t, NewMemoryTaskStore(), uuid.New().String(), uuid.New().String())
+	})
+}
+
+// Opens a fresh SQLite database, migrated just like our Postgres one
+func setupSQLiteTestDB(t *testing.T) *bun.DB {
+	testDB, err := utils.ConnectToSQLite(filepath.Join(t.TempDir(), "notes_tracker_test.db"))
+	if err != nil {
+		t.Fatalf("Database Connection Error: Cannot open database: %v", err)
+	}
+	t.Cleanup(func() { _ = testDB.Close() })
+
+	testDB.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
+
+	if err := migrations.RunMigrations(testDB); err != nil {
+		t.Fatalf("Database Integrity Error: Unable to apply migrations: %v", err)
+	}
+	return testDB
+}
+
+// Runs our repository tests against every database we support. SQLite
+// always runs, Postgres whenever DATABASE_URL points at one.
 func TestTaskRepository(t *testing.T) {
-	testDB := setupTestDB()
+	t.Run("Postgres", func(t *testing.T) {
+		if os.Getenv("DATABASE_URL") == "" {
+			t.Skip("DATABASE_URL not set, skipping the Postgres run")
+		}
+		testTaskRepository(t, setupTestDB())
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		testTaskRepository(t, setupSQLiteTestDB(t))
+	})
+}
+
+func testTaskRepository(t *testing.T, testDB *bun.DB) {
 	repo := NewTaskRepository(testDB)
 
 	t.Run("Create a new Task Item", func(t *testing.T) {
@@ -73,8 +796,8 @@ func TestTaskRepository(t *testing.T) {
 			t.Errorf("Expected task ID to be set, got 0")
 		}
 
-		// task must exist in our database
-		savedTask, err := repo.GetTask(context.Background(), "test-uuid-4")
+		// task must exist in our database, under the id it was given on creation
+		savedTask, err := repo.GetTask(context.Background(), task.ID)
 		if err != nil {
 			t.Fatalf("Task does not exist. Error: %v", err)
 		}
@@ -104,7 +827,7 @@ func TestTaskRepository(t *testing.T) {
 	})
 
 	t.Run("List Tasks", func(t *testing.T) {
-		tasks, err := repo.ListTasks(context.Background())
+		tasks, _, err := repo.ListTasks(context.Background(), TaskFilter{}, TaskPage{})
 		if err != nil {
 			t.Errorf("Batch fetch operation failed: %v", err)
 		}
@@ -120,7 +843,7 @@ func TestTaskRepository(t *testing.T) {
 		}
 
 		_ = repo.CreateTask(context.Background(), task)
-		err := repo.DeleteTask(context.Background(), task.ID)
+		err := repo.DeleteTask(context.Background(), task.ID, AnyVersion)
 		if err != nil {
 			t.Fatalf("Failed to delete task: %v", err)
 		}
@@ -161,4 +884,772 @@ func TestTaskRepository(t *testing.T) {
 			t.Error("Operation Failed: Description was not updated. Expected")
 		}
 	})
+	t.Run("Complete a Task", func(t *testing.T) {
+		task := &models.Task{
+			Title:       "Finish me",
+			Description: "I am a task that will be marked as done",
+		}
+
+		_ = repo.CreateTask(context.Background(), task)
+		if task.Status != models.StatusTodo {
+			t.Errorf("Expected new task to default to %q, got %q", models.StatusTodo, task.Status)
+		}
+
+		if err := task.TransitionTo(models.StatusDone, time.Now()); err != nil {
+			t.Fatalf("Failed to transition task: %v", err)
+		}
+
+		if err := repo.UpdateTask(context.Background(), task); err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+
+		completedTask, err := repo.GetTask(context.Background(), task.ID)
+		if err != nil {
+			t.Fatalf("Failed to get completed task: %v", err)
+		}
+
+		if completedTask.Status != models.StatusDone {
+			t.Errorf("Expected task status to be %q, got %q", models.StatusDone, completedTask.Status)
+		}
+
+		if completedTask.CompletedAt.IsZero() {
+			t.Error("Expected completed_at to be recorded")
+		}
+
+		// a done task has to be reopened before it can be cancelled
+		if err := completedTask.TransitionTo(models.StatusCancelled, time.Now()); err == nil {
+			t.Error("Expected moving a done task to cancelled to be rejected")
+		}
+	})
+	t.Run("Filter Tasks By Due Date", func(t *testing.T) {
+		now := time.Now()
+		overdue := &models.Task{
+			Title: "Already late",
+			DueAt: bun.NullTime{Time: now.Add(-48 * time.Hour)},
+		}
+		upcoming := &models.Task{
+			Title: "Due tomorrow",
+			DueAt: bun.NullTime{Time: now.Add(24 * time.Hour)},
+		}
+
+		_ = repo.CreateTask(context.Background(), overdue)
+		_ = repo.CreateTask(context.Background(), upcoming)
+
+		tasks, _, err := repo.ListTasks(context.Background(), TaskFilter{DueBefore: now, OpenOnly: true}, TaskPage{})
+		if err != nil {
+			t.Fatalf("Filtered fetch operation failed: %v", err)
+		}
+
+		for _, task := range tasks {
+			if task.DueAt.IsZero() || !task.DueAt.Before(now) {
+				t.Errorf("Expected only overdue tasks, got %q due at %v", task.Title, task.DueAt)
+			}
+		}
+		if !containsTask(tasks, overdue.ID) {
+			t.Errorf("Expected overdue task %q to be listed", overdue.Title)
+		}
+
+		tasks, _, err = repo.ListTasks(context.Background(), TaskFilter{NoDueDate: true}, TaskPage{})
+		if err != nil {
+			t.Fatalf("Filtered fetch operation failed: %v", err)
+		}
+
+		if containsTask(tasks, overdue.ID) || containsTask(tasks, upcoming.ID) {
+			t.Error("Expected tasks with a due date to be filtered out")
+		}
+	})
+	t.Run("Paginate Tasks", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			_ = repo.CreateTask(context.Background(), &models.Task{Title: "Paged task"})
+		}
+
+		filter := TaskFilter{TitleContains: "paged"}
+		seen := map[string]bool{}
+		page := TaskPage{Size: 2}
+
+		for pages := 0; ; pages++ {
+			if pages > 3 {
+				t.Fatal("Expected pagination to end after 3 pages")
+			}
+
+			tasks, nextPageToken, err := repo.ListTasks(context.Background(), filter, page)
+			if err != nil {
+				t.Fatalf("Paged fetch operation failed: %v", err)
+			}
+
+			for _, task := range tasks {
+				if seen[task.ID] {
+					t.Errorf("Task %s was returned on more than one page", task.ID)
+				}
+				seen[task.ID] = true
+			}
+
+			if nextPageToken == "" {
+				break
+			}
+			page.Token = nextPageToken
+		}
+
+		if len(seen) != 5 {
+			t.Errorf("Expected 5 paged tasks, got %d", len(seen))
+		}
+	})
+
+	t.Run("Paginate Sorted Tasks", func(t *testing.T) {
+		for _, title := range []string{"Sorted page b", "Sorted page d", "Sorted page f"} {
+			_ = repo.CreateTask(context.Background(), &models.Task{Title: title})
+		}
+
+		filter := TaskFilter{TitleContains: "sorted page"}
+		page := TaskPage{Size: 2, OrderBy: []TaskSort{{Column: "title"}}}
+		tasks, nextPageToken, err := repo.ListTasks(context.Background(), filter, page)
+		if err != nil || len(tasks) != 2 || nextPageToken == "" {
+			t.Fatalf("Expected a first page of 2 sorted tasks, got %d tasks, %v", len(tasks), err)
+		}
+
+		// a task written in between pages must not shift the next page
+		_ = repo.CreateTask(context.Background(), &models.Task{Title: "Sorted page a"})
+
+		page.Token = nextPageToken
+		tasks, _, err = repo.ListTasks(context.Background(), filter, page)
+		if err != nil {
+			t.Fatalf("Sorted paged fetch operation failed: %v", err)
+		}
+		if len(tasks) != 1 || tasks[0].Title != "Sorted page f" {
+			t.Errorf("Expected only the last task on the next page, got %d tasks", len(tasks))
+		}
+	})
+
+	t.Run("Reject Malformed Order", func(t *testing.T) {
+		if _, err := ParseOrderBy("password desc"); err == nil {
+			t.Error("Expected ordering by an unknown column to be rejected")
+		}
+	})
+	t.Run("Report Missing Tasks", func(t *testing.T) {
+		missingID := "00000000-0000-0000-0000-000000000000"
+
+		if _, err := repo.GetTask(context.Background(), missingID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound fetching a missing task, got %v", err)
+		}
+
+		if err := repo.DeleteTask(context.Background(), missingID, AnyVersion); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound deleting a missing task, got %v", err)
+		}
+
+		if _, err := repo.GetTask(context.Background(), "not-a-uuid"); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected ErrInvalidArgument fetching a malformed id, got %v", err)
+		}
+	})
+	t.Run("Tag Tasks", func(t *testing.T) {
+		backend := &models.Task{
+			Title: "Fix the login endpoint",
+			Tags:  []*models.Tag{{Name: "backend"}, {Name: "urgent"}},
+		}
+		frontend := &models.Task{
+			Title: "Restyle the login form",
+			Tags:  []*models.Tag{{Name: "frontend"}, {Name: "urgent"}},
+		}
+
+		_ = repo.CreateTask(context.Background(), backend)
+		_ = repo.CreateTask(context.Background(), frontend)
+
+		savedTask, err := repo.GetTask(context.Background(), backend.ID)
+		if err != nil {
+			t.Fatalf("Failed to get tagged task: %v", err)
+		}
+		if names := models.TagNames(savedTask.Tags); len(names) != 2 || names[0] != "backend" || names[1] != "urgent" {
+			t.Errorf("Expected tags [backend urgent], got %v", names)
+		}
+
+		tasks, _, err := repo.ListTasks(context.Background(), TaskFilter{TagsAny: []string{"backend", "frontend"}}, TaskPage{})
+		if err != nil {
+			t.Fatalf("Tag filtered fetch operation failed: %v", err)
+		}
+		if !containsTask(tasks, backend.ID) || !containsTask(tasks, frontend.ID) {
+			t.Error("Expected both tasks to carry one of backend or frontend")
+		}
+
+		tasks, _, err = repo.ListTasks(context.Background(), TaskFilter{TagsAll: []string{"backend", "urgent"}}, TaskPage{})
+		if err != nil {
+			t.Fatalf("Tag filtered fetch operation failed: %v", err)
+		}
+		if !containsTask(tasks, backend.ID) || containsTask(tasks, frontend.ID) {
+			t.Error("Expected only the backend task to carry both backend and urgent")
+		}
+
+		merged, err := repo.MergeTags(context.Background(), []string{"backend", "frontend"}, "engineering")
+		if err != nil {
+			t.Fatalf("Failed to merge tags: %v", err)
+		}
+		if merged.TaskCount != 2 {
+			t.Errorf("Expected merged tag to carry 2 tasks, got %d", merged.TaskCount)
+		}
+
+		if _, err := repo.RenameTag(context.Background(), "backend", "api"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected merged source tag to be gone, got %v", err)
+		}
+
+		if err := repo.DeleteTag(context.Background(), "urgent"); err != nil {
+			t.Fatalf("Failed to delete tag: %v", err)
+		}
+
+		savedTask, _ = repo.GetTask(context.Background(), frontend.ID)
+		if names := models.TagNames(savedTask.Tags); len(names) != 1 || names[0] != "engineering" {
+			t.Errorf("Expected tags [engineering], got %v", names)
+		}
+	})
+	t.Run("Sort Tasks By Priority", func(t *testing.T) {
+		now := time.Now()
+		triage := []*models.Task{
+			{Title: "Triage: later", Priority: models.PriorityLow},
+			{Title: "Triage: urgent, due later", Priority: models.PriorityUrgent, DueAt: bun.NullTime{Time: now.Add(48 * time.Hour)}},
+			{Title: "Triage: urgent, due soon", Priority: models.PriorityUrgent, DueAt: bun.NullTime{Time: now.Add(time.Hour)}},
+		}
+		for _, task := range triage {
+			_ = repo.CreateTask(context.Background(), task)
+		}
+
+		orderBy, err := ParseOrderBy("priority desc, due_at")
+		if err != nil {
+			t.Fatalf("Failed to parse order: %v", err)
+		}
+
+		tasks, _, err := repo.ListTasks(context.Background(), TaskFilter{TitleContains: "triage:"}, TaskPage{OrderBy: orderBy})
+		if err != nil {
+			t.Fatalf("Sorted fetch operation failed: %v", err)
+		}
+
+		expected := []*models.Task{triage[2], triage[1], triage[0]}
+		if len(tasks) != len(expected) {
+			t.Fatalf("Expected %d tasks, got %d", len(expected), len(tasks))
+		}
+		for i, task := range tasks {
+			if task.ID != expected[i].ID {
+				t.Errorf("Expected %q at position %d, got %q", expected[i].Title, i, task.Title)
+			}
+		}
+	})
+	t.Run("Nest Subtasks", func(t *testing.T) {
+		parent := &models.Task{Title: "Ship the release"}
+		_ = repo.CreateTask(context.Background(), parent)
+
+		child := &models.Task{Title: "Write the changelog", ParentID: parent.ID}
+		_ = repo.CreateTask(context.Background(), child)
+
+		grandchild := &models.Task{Title: "Collect merged PRs", ParentID: child.ID}
+		_ = repo.CreateTask(context.Background(), grandchild)
+
+		descendants, err := repo.ListDescendants(context.Background(), parent.ID)
+		if err != nil {
+			t.Fatalf("Failed to list descendants: %v", err)
+		}
+
+		models.BuildTaskTree(parent, descendants)
+		if len(parent.Subtasks) != 1 || len(parent.Subtasks[0].Subtasks) != 1 {
+			t.Fatalf("Expected a three level tree, got %d descendants", len(descendants))
+		}
+
+		open, err := repo.CountOpenDescendants(context.Background(), parent.ID)
+		if err != nil {
+			t.Fatalf("Failed to count open subtasks: %v", err)
+		}
+		if open != 2 {
+			t.Errorf("Expected 2 open subtasks, got %d", open)
+		}
+
+		// moving the parent under its own grandchild would close a loop
+		parent.ParentID = grandchild.ID
+		if err := repo.UpdateTask(context.Background(), parent); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected cycle to be rejected with ErrInvalidArgument, got %v", err)
+		}
+		parent.ParentID = ""
+
+		if err := repo.DeleteTask(context.Background(), parent.ID, AnyVersion); err != nil {
+			t.Fatalf("Failed to delete task tree: %v", err)
+		}
+		if _, err := repo.GetTask(context.Background(), grandchild.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected subtasks to be deleted along with their parent, got %v", err)
+		}
+	})
+
+	t.Run("Group Tasks Into Projects", func(t *testing.T) {
+		projects := NewProjectRepository(testDB)
+
+		project := &models.Project{Name: "Platform team", Color: "#3b82f6"}
+		if err := projects.CreateProject(context.Background(), project); err != nil {
+			t.Fatalf("Failed to create project: %v", err)
+		}
+
+		filed := &models.Task{Title: "Rotate certificates", ProjectID: project.ID}
+		if err := repo.CreateTask(context.Background(), filed); err != nil {
+			t.Fatalf("Failed to create task in project: %v", err)
+		}
+		loose := &models.Task{Title: "Water the plants"}
+		_ = repo.CreateTask(context.Background(), loose)
+
+		tasks, _, err := repo.ListTasks(context.Background(), TaskFilter{ProjectID: project.ID}, TaskPage{})
+		if err != nil {
+			t.Fatalf("Failed to list project tasks: %v", err)
+		}
+		if len(tasks) != 1 || tasks[0].ID != filed.ID {
+			t.Errorf("Expected only %q in the project, got %d tasks", filed.Title, len(tasks))
+		}
+
+		project.Archived = true
+		if err := projects.UpdateProject(context.Background(), project); err != nil {
+			t.Fatalf("Failed to archive project: %v", err)
+		}
+		active, _ := projects.ListProjects(context.Background(), false)
+		for _, p := range active {
+			if p.ID == project.ID {
+				t.Errorf("Expected archived project to be left out of the listing")
+			}
+		}
+
+		missing := &models.Task{Title: "Orphan", ProjectID: "00000000-0000-0000-0000-000000000000"}
+		if err := repo.CreateTask(context.Background(), missing); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected unknown project to be rejected with ErrInvalidArgument, got %v", err)
+		}
+
+		// deleting a project keeps its tasks around
+		if err := projects.DeleteProject(context.Background(), project.ID); err != nil {
+			t.Fatalf("Failed to delete project: %v", err)
+		}
+		kept, err := repo.GetTask(context.Background(), filed.ID)
+		if err != nil {
+			t.Fatalf("Expected task to survive its project: %v", err)
+		}
+		if kept.ProjectID != "" {
+			t.Errorf("Expected task to no longer be in a project, got %s", kept.ProjectID)
+		}
+	})
+
+	t.Run("Scope Tasks To Their Owner", func(t *testing.T) {
+		users := NewUserRepository(testDB)
+
+		alice := &models.User{Email: "alice@example.com"}
+		_ = alice.SetPassword("alice-password")
+		if err := users.CreateUser(context.Background(), alice); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		bob := &models.User{Email: "bob@example.com"}
+		_ = bob.SetPassword("bob-password")
+		_ = users.CreateUser(context.Background(), bob)
+
+		if err := users.CreateUser(context.Background(), &models.User{Email: "alice@example.com", PasswordHash: "x"}); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected duplicate email to be rejected with ErrConflict, got %v", err)
+		}
+
+		asAlice := auth.WithUserID(context.Background(), alice.ID)
+		asBob := auth.WithUserID(context.Background(), bob.ID)
+
+		task := &models.Task{Title: "Alice's secret plan"}
+		if err := repo.CreateTask(asAlice, task); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		if task.OwnerID != alice.ID {
+			t.Errorf("Expected task to be owned by %s, got %s", alice.ID, task.OwnerID)
+		}
+
+		if _, err := repo.GetTask(asBob, task.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected another user's task to be reported missing, got %v", err)
+		}
+		bobsTasks, _, _ := repo.ListTasks(asBob, TaskFilter{}, TaskPage{})
+		if containsTask(bobsTasks, task.ID) {
+			t.Errorf("Expected another user's task to be left out of the listing")
+		}
+		if err := repo.UpdateTask(asBob, task); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected updating another user's task to fail with ErrNotFound, got %v", err)
+		}
+		if err := repo.DeleteTask(asBob, task.ID, AnyVersion); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected deleting another user's task to fail with ErrNotFound, got %v", err)
+		}
+		if err := repo.CreateTask(asBob, &models.Task{Title: "Hijack", ParentID: task.ID}); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected nesting under another user's task to fail with ErrInvalidArgument, got %v", err)
+		}
+
+		if _, err := repo.GetTask(asAlice, task.ID); err != nil {
+			t.Errorf("Expected owner to see their task: %v", err)
+		}
+	})
+
+	t.Run("Scope Tags To Their Owner", func(t *testing.T) {
+		users := NewUserRepository(testDB)
+		alice, _ := users.GetUserByEmail(context.Background(), "alice@example.com")
+		bob, _ := users.GetUserByEmail(context.Background(), "bob@example.com")
+
+		testTagOwnership(t, repo, alice.ID, bob.ID)
+	})
+
+	t.Run("Scope Projects To Their Owner", func(t *testing.T) {
+		users := NewUserRepository(testDB)
+		projects := NewProjectRepository(testDB)
+		alice, _ := users.GetUserByEmail(context.Background(), "alice@example.com")
+		bob, _ := users.GetUserByEmail(context.Background(), "bob@example.com")
+		asAlice := auth.WithUserID(context.Background(), alice.ID)
+		asBob := auth.WithUserID(context.Background(), bob.ID)
+
+		project := &models.Project{Name: "Alice's side project"}
+		if err := projects.CreateProject(asAlice, project); err != nil {
+			t.Fatalf("Failed to create project: %v", err)
+		}
+		if project.OwnerID != alice.ID {
+			t.Errorf("Expected project to be owned by %s, got %s", alice.ID, project.OwnerID)
+		}
+
+		if _, err := projects.GetProject(asBob, project.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected another user's project to be reported missing, got %v", err)
+		}
+		bobsProjects, _ := projects.ListProjects(asBob, true)
+		for _, p := range bobsProjects {
+			if p.ID == project.ID {
+				t.Errorf("Expected another user's project to be left out of the listing")
+			}
+		}
+		if err := projects.UpdateProject(asBob, &models.Project{ID: project.ID, Name: "Hijacked"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected updating another user's project to fail with ErrNotFound, got %v", err)
+		}
+		if err := projects.DeleteProject(asBob, project.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected deleting another user's project to fail with ErrNotFound, got %v", err)
+		}
+
+		if err := repo.CreateTask(asBob, &models.Task{Title: "Sneak in", ProjectID: project.ID}); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected filing under another user's project to fail with ErrInvalidArgument, got %v", err)
+		}
+		bobsTask := &models.Task{Title: "Move me over"}
+		_ = repo.CreateTask(asBob, bobsTask)
+		bobsTask.ProjectID = project.ID
+		if err := repo.UpdateTask(asBob, bobsTask); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("Expected moving a task into another user's project to fail with ErrInvalidArgument, got %v", err)
+		}
+
+		if err := repo.CreateTask(asAlice, &models.Task{Title: "Filed by the owner", ProjectID: project.ID}); err != nil {
+			t.Errorf("Expected the owner to file tasks under their project: %v", err)
+		}
+	})
+
+	t.Run("Manage API Keys", func(t *testing.T) {
+		users := NewUserRepository(testDB)
+		apiKeys := NewAPIKeyRepository(testDB)
+
+		owner := &models.User{Email: "ci-owner@example.com"}
+		_ = owner.SetPassword("ci-owner-password")
+		if err := users.CreateUser(context.Background(), owner); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		asOwner := auth.WithUserID(context.Background(), owner.ID)
+
+		key, secret, err := models.NewAPIKey("ci-bot", []string{models.ScopeTasksRead})
+		if err != nil {
+			t.Fatalf("Failed to generate api key: %v", err)
+		}
+		if err := apiKeys.CreateAPIKey(asOwner, key); err != nil {
+			t.Fatalf("Failed to store api key: %v", err)
+		}
+		if key.Hash == secret {
+			t.Fatalf("Expected only a hash of the key to be stored")
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		used, err := apiKeys.UseAPIKey(context.Background(), models.HashAPIKey(secret), now)
+		if err != nil {
+			t.Fatalf("Failed to use api key: %v", err)
+		}
+		if used.UserID != owner.ID || used.LastUsedAt.IsZero() {
+			t.Errorf("Expected key of %s with last use recorded, got user %s used at %v", owner.ID, used.UserID, used.LastUsedAt)
+		}
+
+		keys, _ := apiKeys.ListAPIKeys(asOwner)
+		if len(keys) != 1 || keys[0].ID != key.ID {
+			t.Errorf("Expected exactly the owner's key to be listed, got %d keys", len(keys))
+		}
+
+		if _, err := apiKeys.RevokeAPIKey(asOwner, key.ID, now); err != nil {
+			t.Fatalf("Failed to revoke api key: %v", err)
+		}
+		if _, err := apiKeys.UseAPIKey(context.Background(), models.HashAPIKey(secret), now); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected revoked key to be rejected with ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Save Views", func(t *testing.T) {
+		users := NewUserRepository(testDB)
+		views := NewSavedViewRepository(testDB)
+
+		owner := &models.User{Email: "viewer@example.com"}
+		_ = owner.SetPassword("viewer-password")
+		if err := users.CreateUser(context.Background(), owner); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		other := &models.User{Email: "other-viewer@example.com"}
+		_ = other.SetPassword("other-viewer-password")
+		_ = users.CreateUser(context.Background(), other)
+
+		asOwner := auth.WithUserID(context.Background(), owner.ID)
+		asOther := auth.WithUserID(context.Background(), other.ID)
+
+		view := &models.SavedView{Name: "Open bugs", Query: "status:open tag:bug", GroupBy: models.GroupByPriority}
+		if err := views.CreateSavedView(asOwner, view); err != nil {
+			t.Fatalf("Failed to save view: %v", err)
+		}
+		if view.OwnerID != owner.ID {
+			t.Errorf("Expected view to be owned by %s, got %s", owner.ID, view.OwnerID)
+		}
+		if err := views.CreateSavedView(asOwner, &models.SavedView{Name: "Open bugs"}); !errors.Is(err, ErrConflict) {
+			t.Errorf("Expected a second view of the same name to fail with ErrConflict, got %v", err)
+		}
+		if err := views.CreateSavedView(asOther, &models.SavedView{Name: "Open bugs"}); err != nil {
+			t.Errorf("Expected another user to reuse the name: %v", err)
+		}
+
+		view.Query = "status:open tag:bug priority>=high"
+		view.UpdatedAt = bun.NullTime{Time: time.Now()}
+		if err := views.UpdateSavedView(asOwner, view); err != nil {
+			t.Fatalf("Failed to update view: %v", err)
+		}
+		saved, err := views.GetSavedView(asOwner, view.ID)
+		if err != nil {
+			t.Fatalf("Failed to get view: %v", err)
+		}
+		if saved.Query != view.Query || saved.GroupBy != models.GroupByPriority || saved.UpdatedAt.IsZero() {
+			t.Errorf("Expected the updated view back, got %+v", saved)
+		}
+
+		if _, err := views.GetSavedView(asOther, view.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected another user's view to be reported missing, got %v", err)
+		}
+		if err := views.UpdateSavedView(asOther, view); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected updating another user's view to fail with ErrNotFound, got %v", err)
+		}
+		if listed, _ := views.ListSavedViews(asOwner); len(listed) != 1 || listed[0].ID != view.ID {
+			t.Errorf("Expected exactly the owner's view to be listed, got %d views", len(listed))
+		}
+
+		if err := views.DeleteSavedView(asOwner, view.ID); err != nil {
+			t.Fatalf("Failed to delete view: %v", err)
+		}
+		if err := views.DeleteSavedView(asOwner, view.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected deleting a deleted view to fail with ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Track Applied Migrations", func(t *testing.T) {
+		// everything was applied by setupTestDB, so this has nothing left to do
+		if err := migrations.RunMigrations(testDB); err != nil {
+			t.Fatalf("Expected reapplying migrations to be a no-op, got %v", err)
+		}
+
+		shipped, err := migrations.DefaultMigrations(testDB.Dialect().Name())
+		if err != nil {
+			t.Fatalf("Failed to load migrations: %v", err)
+		}
+
+		applied, err := migrations.NewMigrator(testDB, shipped).Applied(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to list applied migrations: %v", err)
+		}
+		for _, migration := range shipped {
+			if applied[migration.Version].Checksum != migration.Checksum() {
+				t.Errorf("Expected %s to be recorded with its checksum", migration)
+			}
+		}
+
+		// our models and migrations have to describe the same schema, which
+		// only Postgres can tell us
+		if testDB.Dialect().Name() == dialect.PG {
+			changes, err := migrations.MakeMigrations(context.Background(), testDB, shipped)
+			if err != nil {
+				t.Fatalf("Failed to diff models against the schema: %v", err)
+			}
+			for _, change := range changes {
+				t.Errorf("Expected no schema changes, models want to %s", change.Description)
+			}
+		}
+
+		// editing a migration once it went out has to be caught
+		tampered := append([]migrations.Migration{}, shipped...)
+		tampered[0].Up += "\n-- edited"
+		if _, err := migrations.NewMigrator(testDB, tampered).Up(context.Background()); !errors.Is(err, migrations.ErrChecksumMismatch) {
+			t.Errorf("Expected edited migration to fail with ErrChecksumMismatch, got %v", err)
+		}
+		// so does editing the SQL that reverts it
+		tampered[0] = shipped[0]
+		tampered[0].Down += "\n-- edited"
+		if _, err := migrations.NewMigrator(testDB, tampered).Up(context.Background()); !errors.Is(err, migrations.ErrChecksumMismatch) {
+			t.Errorf("Expected edited down migration to fail with ErrChecksumMismatch, got %v", err)
+		}
+	})
+
+	t.Run("Step Through Migrations", func(t *testing.T) {
+		ctx := context.Background()
+
+		shipped, err := migrations.DefaultMigrations(testDB.Dialect().Name())
+		if err != nil {
+			t.Fatalf("Failed to load migrations: %v", err)
+		}
+
+		// a throwaway migration on top of ours, so stepping back and forth leaves our tables alone
+		scratch := migrations.Migration{
+			Version: shipped[len(shipped)-1].Version + 1,
+			Name:    "scratch",
+			Up:      "CREATE TABLE migrate_scratch (id INT PRIMARY KEY);",
+			Down:    "DROP TABLE migrate_scratch;",
+		}
+		migrator := migrations.NewMigrator(testDB, append(append([]migrations.Migration{}, shipped...), scratch))
+
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			t.Fatalf("Failed to read migration status: %v", err)
+		}
+		if last := statuses[len(statuses)-1]; last.Migration.Version != scratch.Version || !last.Pending() {
+			t.Errorf("Expected %s to be listed as pending, got %+v", scratch, last)
+		}
+
+		var printed strings.Builder
+		steps, err := migrator.DryRun(&printed).Up(ctx)
+		if err != nil || len(steps) != 1 {
+			t.Fatalf("Expected a dry run to plan a single step, got %v, %v", steps, err)
+		}
+		if !strings.Contains(printed.String(), scratch.Up) {
+			t.Errorf("Expected the dry run to print the migration SQL, got %q", printed.String())
+		}
+		if applied, _ := migrator.Applied(ctx); len(applied) != len(shipped) {
+			t.Errorf("Expected a dry run to apply nothing, %d migrations are applied", len(applied))
+		}
+
+		if _, err := migrator.Goto(ctx, scratch.Version); err != nil {
+			t.Fatalf("Failed to migrate to %s: %v", scratch, err)
+		}
+		steps, err = migrator.Redo(ctx)
+		if err != nil {
+			t.Fatalf("Failed to redo %s: %v", scratch, err)
+		}
+		if len(steps) != 2 || !steps[0].Down || steps[1].Down || steps[0].Migration.Version != scratch.Version {
+			t.Errorf("Expected redo to revert and reapply %s, got %v", scratch, steps)
+		}
+
+		steps, err = migrator.Down(ctx, 1)
+		if err != nil || len(steps) != 1 || steps[0].Migration.Version != scratch.Version {
+			t.Fatalf("Expected down to revert only %s, got %v, %v", scratch, steps, err)
+		}
+		if applied, _ := migrator.Applied(ctx); len(applied) != len(shipped) {
+			t.Errorf("Expected only our own migrations to remain applied, got %d", len(applied))
+		}
+
+		if _, err := migrator.Goto(ctx, scratch.Version+1); err == nil {
+			t.Errorf("Expected going to an unknown version to fail")
+		}
+	})
+
+	t.Run("Hand Over Unowned Tasks", func(t *testing.T) {
+		ctx := context.Background()
+
+		bob, _ := NewUserRepository(testDB).GetUserByEmail(ctx, "bob@example.com")
+		asBob := auth.WithUserID(ctx, bob.ID)
+		kept := "kept-" + uuid.New().String()[:8]
+		loose := "loose-" + uuid.New().String()[:8]
+
+		bobsTask := &models.Task{Title: "Bob's own task", Tags: []*models.Tag{{Name: kept}}}
+		if err := repo.CreateTask(asBob, bobsTask); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		unowned := &models.Task{Title: "From before we had users", Tags: []*models.Tag{{Name: kept}, {Name: loose}}}
+		if err := repo.CreateTask(ctx, unowned); err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		if err := migrations.CheckOwners(ctx, testDB); !errors.Is(err, migrations.ErrUnownedTasks) {
+			t.Errorf("Expected unowned tasks to fail with ErrUnownedTasks, got %v", err)
+		}
+
+		if _, err := migrations.AssignOwner(ctx, testDB, "nobody@example.com"); err == nil {
+			t.Error("Expected handing tasks to an unknown user to fail")
+		}
+		assigned, err := migrations.AssignOwner(ctx, testDB, "Bob@example.com")
+		if err != nil || assigned == 0 {
+			t.Fatalf("Expected unowned tasks to be handed over, got %d, %v", assigned, err)
+		}
+
+		if _, err := repo.GetTask(asBob, unowned.ID); err != nil {
+			t.Errorf("Expected the new owner to see the task: %v", err)
+		}
+		// its tags go along, merged into the ones of the same name the owner had
+		tags, err := repo.ListTags(asBob)
+		if err != nil {
+			t.Fatalf("Failed to list tags: %v", err)
+		}
+		counts := map[string]int{}
+		for _, tag := range tags {
+			counts[tag.Name] = tag.TaskCount
+		}
+		if counts[kept] != 2 || counts[loose] != 1 {
+			t.Errorf("Expected the owner's tags to carry the handed over task, got %v", counts)
+		}
+		if err := migrations.CheckOwners(ctx, testDB); err != nil {
+			t.Errorf("Expected no unowned tasks to be left, got %v", err)
+		}
+	})
+
+	t.Run("Behave Like Every TaskStore", func(t *testing.T) {
+		testTaskStore(t, repo)
+	})
+}
+
+// Checks that users only ever see and touch their own tags, even when
+// another user has tags of the same names
+func testTagOwnership(t *testing.T, store TaskStore, aliceID, bobID string) {
+	asAlice := auth.WithUserID(context.Background(), aliceID)
+	asBob := auth.WithUserID(context.Background(), bobID)
+	shared := "shared-" + uuid.New().String()[:8]
+	private := "private-" + uuid.New().String()[:8]
+
+	alicesTask := &models.Task{Title: "Alice's tagged task", Tags: []*models.Tag{{Name: shared}, {Name: private}}}
+	if err := store.CreateTask(asAlice, alicesTask); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	bobsTask := &models.Task{Title: "Bob's tagged task", Tags: []*models.Tag{{Name: shared}}}
+	if err := store.CreateTask(asBob, bobsTask); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+	if alicesTask.Tags[1].ID == bobsTask.Tags[0].ID {
+		t.Errorf("Expected Bob to get a tag of their own rather than Alice's")
+	}
+
+	tags, err := store.ListTags(asBob)
+	if err != nil {
+		t.Fatalf("Failed to list tags: %v", err)
+	}
+	if names := models.TagNames(tags); len(names) != 1 || names[0] != shared || tags[0].TaskCount != 1 {
+		t.Errorf("Expected Bob to list only their own tag carried by 1 task, got %v", names)
+	}
+
+	if _, err := store.RenameTag(asBob, private, "stolen"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected renaming another user's tag to fail with ErrNotFound, got %v", err)
+	}
+	// renaming onto a name only another user has is no conflict
+	if _, err := store.RenameTag(asBob, shared, private); err != nil {
+		t.Errorf("Expected Bob to rename their tag to a name only Alice uses: %v", err)
+	}
+	if _, err := store.MergeTags(asBob, []string{shared}, "merged"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected merging another user's tag to fail with ErrNotFound, got %v", err)
+	}
+	if err := store.DeleteTag(asBob, shared); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleting another user's tag to fail with ErrNotFound, got %v", err)
+	}
+
+	saved, err := store.GetTask(asAlice, alicesTask.ID)
+	if err != nil {
+		t.Fatalf("Failed to get task: %v", err)
+	}
+	if names := models.TagNames(saved.Tags); len(names) != 2 || names[0] != private || names[1] != shared {
+		t.Errorf("Expected Alice's tags to be left alone, got %v", names)
+	}
+}
+
+func containsTask(tasks []*models.Task, id string) bool {
+	for _, task := range tasks {
+		if task.ID == id {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+)
+
+// Everything our services need from wherever tasks and their tags are
+// kept. TaskRepository keeps them in the database, MemoryTaskStore in
+// memory for running and testing without one.
+//
+// Implementations share the same semantics: errors wrap our domain errors,
+// lists come back in the same order and every task operation is scoped to
+// the user on ctx, see ownedBy. Tasks in the trash are left out of
+// everything but the trash operations.
+type TaskStore interface {
+	// Inserts the task, filling in its ID, and creates any tag it carries
+	// that we have not seen before
+	CreateTask(ctx context.Context, task *models.Task) error
+	GetTask(ctx context.Context, id string) (*models.Task, error)
+	// Lists a single page of the tasks matching filter, along with the
+	// token to fetch the page after it. The token is empty on the last page.
+	ListTasks(ctx context.Context, filter TaskFilter, page TaskPage) ([]*models.Task, string, error)
+	// Saves the task, replacing its tags with the ones it currently carries.
+	// Given columns, only those are written and the tags are only replaced
+	// when TagsColumn is among them.
+	UpdateTask(ctx context.Context, task *models.Task, columns ...string) error
+	// Moves the task to the trash along with every subtask below it, as
+	// long as the task is still at version; ErrStale otherwise. AnyVersion
+	// trashes it whichever version it is at.
+	DeleteTask(ctx context.Context, id string, version int64) error
+	// Searches the titles and descriptions of tasks for the words of query,
+	// best matches first. Words can be "quoted phrases" and end in * to
+	// match them as a prefix; an empty or malformed query is rejected with
+	// ErrInvalidArgument.
+	SearchTasks(ctx context.Context, query string, page TaskPage) ([]*TaskMatch, string, error)
+	// Counts the tasks matching filter and, unless groupBy is empty, how
+	// many fall into each group; groupBy is one of the models.GroupBy
+	// constants. Groups come back in the order sortTaskGroups puts them in.
+	CountTasks(ctx context.Context, filter TaskFilter, groupBy string) (int, []*TaskGroup, error)
+
+	// Lists a single page of the tasks in the trash, most recently deleted
+	// first. Subtasks whose parent is in the trash too are left out, they
+	// come back along with it.
+	ListTrash(ctx context.Context, page TaskPage) ([]*models.Task, string, error)
+	// Takes the task out of the trash along with every subtask below it,
+	// stamping them as updated at now
+	RestoreTask(ctx context.Context, id string, now time.Time) error
+	// Deletes a task in the trash for good, along with every subtask below it
+	PermanentlyDeleteTask(ctx context.Context, id string) error
+	// Deletes every task that went into the trash before the given time for
+	// good and returns how many there were. Meant for our purge job, which
+	// runs without a user on ctx and so empties everyone's trash.
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+
+	// Calls emit with every event on the caller's tasks after the one cursor
+	// names, oldest first, then with every new event as it happens, until
+	// ctx is done or emit fails. An empty cursor starts with the next event;
+	// one whose events have been pruned fails with ErrCursorExpired.
+	//
+	// Events come with their task as it was right before and right after
+	// the change, and are left out unless it matched filter on either
+	// side, its Today kept at the current day. Updates that take a task out
+	// of the filter go out as TaskLeft instead.
+	WatchTasks(ctx context.Context, filter TaskFilter, cursor string, emit func(*models.TaskEvent) error) error
+	// Fails the way WatchTasks would when starting from cursor, without
+	// watching. Lets callers turn a bad cursor away before they commit to
+	// streaming, though it may still expire before they start watching.
+	CheckEventCursor(ctx context.Context, cursor string) error
+	// Deletes the task events written before the given time, but for the
+	// latest one, and returns how many there were. Like PurgeTrash it is
+	// meant for our maintenance jobs and ignores the user on ctx.
+	PruneTaskEvents(ctx context.Context, before time.Time) (int, error)
+
+	// Lists every task below the given one, however deep, in creation order
+	ListDescendants(ctx context.Context, id string) ([]*models.Task, error)
+	// Counts the tasks below the given one that are neither done nor cancelled
+	CountOpenDescendants(ctx context.Context, id string) (int, error)
+	// Saves the task like UpdateTask and marks every open task below it as
+	// done along with it, in one go: when the task is stale or gone, its
+	// subtasks are left alone too
+	CompleteTaskTree(ctx context.Context, task *models.Task, now time.Time, columns ...string) error
+
+	// Lists the caller's tags by name along with the number of tasks
+	// carrying each, leaving out the ones in the trash
+	ListTags(ctx context.Context) ([]*models.Tag, error)
+	// Renaming, merging or deleting a tag changes the tasks carrying it,
+	// which get a new version and are stamped as updated along with it
+	RenameTag(ctx context.Context, name, newName string) (*models.Tag, error)
+	// Moves every task tagged with one of the sources over to target and
+	// removes the sources. The target tag is created when missing.
+	MergeTags(ctx context.Context, sources []string, target string) (*models.Tag, error)
+	DeleteTag(ctx context.Context, name string) error
+}
+
+// Stands in for a task's tags among the columns passed to UpdateTask
+const TagsColumn = "tags"
+
+// Passed to DeleteTask in place of a version, for callers that do not
+// mind which version they delete. Tasks start out at version 1.
+const AnyVersion int64 = 0
+
+var (
+	_ TaskStore = (*TaskRepository)(nil)
+	_ TaskStore = (*MemoryTaskStore)(nil)
+)
+
+// Splits the columns passed to UpdateTask into the ones to write and
+// whether the tags are to be replaced. No columns means all of them.
+func splitTagsColumn(columns []string) ([]string, bool) {
+	if len(columns) == 0 {
+		return nil, true
+	}
+
+	var written []string
+	tags := false
+	for _, column := range columns {
+		if column == TagsColumn {
+			tags = true
+			continue
+		}
+		written = append(written, column)
+	}
+	return written, tags
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+)
+
+// the trash lists the most recently deleted tasks first
+var trashOrder = []TaskSort{{Column: "deleted_at", Desc: true}}
+
+// Lists a single page of the tasks in the trash, most recently deleted
+// first. Subtasks whose parent is in the trash too are left out.
+func (r *TaskRepository) ListTrash(ctx context.Context, page TaskPage) ([]*models.Task, string, error) {
+	var tasks []*models.Task
+
+	page.OrderBy = trashOrder
+	q := r.db.NewSelect().
+		Model(&tasks).
+		Relation("Tags", orderTagsByName).
+		WhereDeleted().
+		Where("t.parent_id IS NULL OR t.parent_id IN (" + untrashedTaskIDsQuery + ")").
+		ApplyQueryBuilder(ownedBy(ctx, "t.owner_id"))
+	q, paginate, err := page.apply(q)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidArgument, err)
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return nil, "", translateError(err)
+	}
+
+	tasks, nextPageToken := paginate(tasks)
+	return tasks, nextPageToken, nil
+}
+
+// Takes the task out of the trash along with every subtask below it. A
+// subtask cannot come back on its own while its parent is still in there.
+func (r *TaskRepository) RestoreTask(ctx context.Context, id string, now time.Time) error {
+	if err := checkUUID(id); err != nil {
+		return err
+	}
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		task, err := findTask(ctx, tx, id, true)
+		if err != nil {
+			return err
+		}
+		if task.ParentID != "" {
+			if _, err := findTask(ctx, tx, task.ParentID, false); err != nil {
+				return fmt.Errorf("%w: parent task %s is in the trash, restore it first", ErrInvalidArgument, task.ParentID)
+			}
+		}
+
+		var subtree []string
+		if err := tx.NewRaw(descendantIDsQuery, id).Scan(ctx, &subtree); err != nil {
+			return err
+		}
+		subtree = append(subtree, id)
+
+		_, err = tx.NewUpdate().
+			Model((*models.Task)(nil)).
+			Set("deleted_at = NULL").
+			Set("updated_at = ?", now).
+			Set("version = version + 1").
+			Where("id IN (?)", bun.In(subtree)).
+			WhereDeleted().
+			ApplyQueryBuilder(ownedBy(ctx, "owner_id")).
+			Exec(ctx)
+		return err
+	})
+	return translateError(err)
+}
+
+// Deletes a task in the trash for good, along with every subtask below it
+func (r *TaskRepository) PermanentlyDeleteTask(ctx context.Context, id string) error {
+	if err := checkUUID(id); err != nil {
+		return err
+	}
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := findTask(ctx, tx, id, true); err != nil {
+			return err
+		}
+
+		var subtree []string
+		if err := tx.NewRaw(descendantIDsQuery, id).Scan(ctx, &subtree); err != nil {
+			return err
+		}
+		return purgeTasks(ctx, tx, append(subtree, id))
+	})
+	return translateError(err)
+}
+
+// Deletes every task that went into the trash before the given time for
+// good. Subtasks always go into the trash with their parent, or before it,
+// so whole subtrees expire at once.
+func (r *TaskRepository) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	var expired []string
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().
+			Model((*models.Task)(nil)).
+			Column("t.id").
+			WhereDeleted().
+			Where("t.deleted_at < ?", before).
+			ApplyQueryBuilder(ownedBy(ctx, "t.owner_id")).
+			Scan(ctx, &expired)
+		if err != nil || len(expired) == 0 {
+			return err
+		}
+		return purgeTasks(ctx, tx, expired)
+	})
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return len(expired), nil
+}
+
+// Hard deletes the tasks along with their tags, in a single statement so
+// the parent_id foreign key only sees the end result
+func purgeTasks(ctx context.Context, tx bun.Tx, ids []string) error {
+	if _, err := tx.NewDelete().Model((*models.TaskTag)(nil)).Where("task_id IN (?)", bun.In(ids)).Exec(ctx); err != nil {
+		return err
+	}
+
+	_, err := tx.NewDelete().
+		Model((*models.Task)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		ApplyQueryBuilder(ownedBy(ctx, "owner_id")).
+		ForceDelete().
+		Exec(ctx)
+	return err
+}
+
+// Looks up one of the caller's tasks, either in or out of the trash
+func findTask(ctx context.Context, db bun.IDB, id string, trashed bool) (*models.Task, error) {
+	task := new(models.Task)
+
+	q := db.NewSelect().
+		Model(task).
+		Where("t.id = ?", id).
+		ApplyQueryBuilder(ownedBy(ctx, "t.owner_id"))
+	if trashed {
+		q = q.WhereDeleted()
+	}
+
+	err := q.Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		if trashed {
+			return nil, fmt.Errorf("%w: task %s is not in the trash", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("%w: task %s does not exist", ErrNotFound, id)
+	}
+	return task, err
+}
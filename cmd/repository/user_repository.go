@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type UserRepository struct {
+	db *bun.DB
+}
+
+func NewUserRepository(db *bun.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// Inserts the user, failing with ErrConflict when the email is taken
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	user.ID = uuid.New().String()
+
+	_, err := r.db.NewInsert().Model(user).Exec(ctx)
+	if err = translateError(err); errors.Is(err, ErrConflict) {
+		return fmt.Errorf("%w: a user with email %s already exists", ErrConflict, user.Email)
+	}
+	return err
+}
+
+func (r *UserRepository) GetUser(ctx context.Context, id string) (*models.User, error) {
+	return r.getUserWhere(ctx, "u.id = ?", id)
+}
+
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.getUserWhere(ctx, "u.email = ?", email)
+}
+
+func (r *UserRepository) getUserWhere(ctx context.Context, where string, value string) (*models.User, error) {
+	user := new(models.User)
+	err := r.db.NewSelect().Model(user).Where(where, value).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: user %s does not exist", ErrNotFound, value)
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return user, nil
+}
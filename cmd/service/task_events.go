@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/shared/models"
+)
+
+// How long we keep task events for watchers to resume from, unless
+// configured otherwise
+const DefaultEventRetention = 24 * time.Hour
+
+// Hands every event on the caller's tasks matching filter to emit, starting
+// after the event cursor names, or with the next one when it is empty, until
+// ctx is done or emit fails. See TaskStore.WatchTasks.
+func (s *TaskService) WatchTasks(ctx context.Context, filter repository.TaskFilter, cursor string, emit func(*models.TaskEvent) error) error {
+	if err := authorize(ctx); err != nil {
+		return err
+	}
+	return s.store.WatchTasks(ctx, filter, cursor, emit)
+}
+
+// Fails the way WatchTasks would when starting from cursor, without
+// watching, see TaskStore.CheckEventCursor
+func (s *TaskService) CheckEventCursor(ctx context.Context, cursor string) error {
+	if err := authorize(ctx); err != nil {
+		return err
+	}
+	return s.store.CheckEventCursor(ctx, cursor)
+}
+
+// Deletes the task events older than retention, whoever they belong to,
+// and returns how many went. Like PurgeTrash it is only ever called by our
+// own maintenance jobs.
+func (s *TaskService) PruneTaskEvents(ctx context.Context, retention time.Duration) (int, error) {
+	return s.store.PruneTaskEvents(ctx, s.now().Add(-retention))
+}
+
+// Prunes task events every interval until ctx is done. Failed prunes are
+// logged and retried on the next tick.
+func (s *TaskService) RunEventPrune(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := s.PruneTaskEvents(ctx, retention)
+		switch {
+		case err != nil:
+			log.Printf("[Events] Failed to prune task events: %v", err)
+		case pruned > 0:
+			log.Printf("[Events] Pruned %d task events older than %s", pruned, retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
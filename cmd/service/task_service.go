@@ -1 +1,512 @@
+// Package service holds the rules our tasks live by, whichever transport
+// a request comes in through. Handlers translate their wire format into
+// calls on TaskService and its errors back out, and leave the deciding to it.
 package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/shared/auth"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	MaxTitleLength       = 200
+	MaxDescriptionLength = 10000
+)
+
+var (
+	// Returned when a task's fields are not fit to be stored, i.e. an empty title
+	ErrInvalidTask = errors.New("invalid task")
+
+	// Returned when completing a task while subtasks below it are still open
+	ErrOpenSubtasks = errors.New("task has open subtasks")
+
+	// Returned when there is no user behind a request
+	ErrUnauthenticated = errors.New("unauthenticated")
+)
+
+// The parts of a task our clients get to set, both when creating a task
+// and when replacing one wholesale
+type TaskFields struct {
+	Title       string
+	Description string
+	// left empty, a new task starts as todo and an existing one keeps its status
+	Status   models.TaskStatus
+	Priority models.TaskPriority
+	StartAt  bun.NullTime
+	DueAt    bun.NullTime
+	Tags     []string
+	// empty for a top level task
+	ParentID string
+	// empty for a task in no project
+	ProjectID string
+}
+
+// The columns each field of an update mask writes to
+var maskColumns = map[string][]string{
+	"title":       {"title"},
+	"description": {"description"},
+	"status":      {"status", "completed_at"},
+	"priority":    {"priority"},
+	"start_at":    {"start_at"},
+	"due_at":      {"due_at"},
+	"tags":        {repository.TagsColumn},
+	"parent_id":   {"parent_id"},
+	"project_id":  {"project_id"},
+}
+
+// the columns moving a task through its lifecycle writes
+var statusColumns = []string{"status", "completed_at", "updated_at"}
+
+type TaskService struct {
+	store repository.TaskStore
+	// our clock, swapped out by tests
+	now func() time.Time
+}
+
+// Creates new instance of TaskService on top of any of our task stores
+func NewTaskService(store repository.TaskStore) *TaskService {
+	return &TaskService{store: store, now: time.Now}
+}
+
+// Creates a task for the user behind ctx
+func (s *TaskService) CreateTask(ctx context.Context, fields TaskFields) (*models.Task, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	task := &models.Task{Status: models.StatusTodo, CreatedAt: now}
+	if err := s.apply(ctx, task, fields, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.CreateTask(ctx, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// Fetches one of the caller's tasks, optionally along with its whole subtree
+func (s *TaskService) GetTask(ctx context.Context, id string, includeSubtree bool) (*models.Task, error) {
+	task, err := s.getTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if includeSubtree {
+		descendants, err := s.store.ListDescendants(ctx, task.ID)
+		if err != nil {
+			return nil, err
+		}
+		models.BuildTaskTree(task, descendants)
+	}
+	return task, nil
+}
+
+// Lists a page of the caller's tasks matching filter
+func (s *TaskService) ListTasks(ctx context.Context, filter repository.TaskFilter, page repository.TaskPage) ([]*models.Task, string, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, "", err
+	}
+	return s.store.ListTasks(ctx, filter, page)
+}
+
+// Searches the titles and descriptions of the caller's tasks, best matches
+// first, see TaskStore.SearchTasks for the query syntax
+func (s *TaskService) SearchTasks(ctx context.Context, query string, page repository.TaskPage) ([]*repository.TaskMatch, string, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, "", err
+	}
+	return s.store.SearchTasks(ctx, query, page)
+}
+
+// Counts the caller's tasks matching filter, along with how many fall into
+// each group when groupBy is one of the models.GroupBy constants
+func (s *TaskService) CountTasks(ctx context.Context, filter repository.TaskFilter, groupBy string) (int, []*repository.TaskGroup, error) {
+	if err := authorize(ctx); err != nil {
+		return 0, nil, err
+	}
+	return s.store.CountTasks(ctx, filter, groupBy)
+}
+
+// Lists a page of the direct subtasks of a task
+func (s *TaskService) ListSubtasks(ctx context.Context, parentID string, page repository.TaskPage) ([]*models.Task, string, error) {
+	// we look the parent up first so a missing parent is not mistaken for a leaf
+	parent, err := s.getTask(ctx, parentID)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.store.ListTasks(ctx, repository.TaskFilter{ParentID: parent.ID}, page)
+}
+
+// Updates the fields of a task named in mask, i.e. "title" or "due_at",
+// leaving the others as they are. An empty mask replaces every field with
+// the given ones, keeping the current status when fields.Status is empty.
+//
+// Given the etag the caller last read the task at, the update only goes
+// ahead if the task is still at it.
+func (s *TaskService) UpdateTask(ctx context.Context, id string, fields TaskFields, mask []string, etag string) (*models.Task, error) {
+	task, err := s.getTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkETag(task, etag); err != nil {
+		return nil, err
+	}
+
+	fields, columns, err := maskFields(fieldsOf(task), fields, mask)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	if err := s.apply(ctx, task, fields, now); err != nil {
+		return nil, err
+	}
+	task.UpdatedAt = bun.NullTime{Time: now}
+
+	if err := s.store.UpdateTask(ctx, task, columns...); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// Moves a task to the trash along with every subtask below it, as long as
+// the task is still at etag when one is given
+func (s *TaskService) DeleteTask(ctx context.Context, id string, etag string) error {
+	if err := authorize(ctx); err != nil {
+		return err
+	}
+
+	if etag == "" {
+		return s.store.DeleteTask(ctx, id, repository.AnyVersion)
+	}
+	task, err := s.getTask(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := checkETag(task, etag); err != nil {
+		return err
+	}
+	// the store checks again, in case the task changes in the meantime
+	return s.store.DeleteTask(ctx, id, task.Version)
+}
+
+// Marks a task as done.
+//
+// A task with open subtasks is only completed when forced, in which case
+// its open subtasks are completed along with it.
+func (s *TaskService) CompleteTask(ctx context.Context, id string, force bool) (*models.Task, error) {
+	task, err := s.getTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	if err := task.TransitionTo(models.StatusDone, now); err != nil {
+		return nil, err
+	}
+
+	task.UpdatedAt = bun.NullTime{Time: now}
+	if force {
+		// the subtasks only close if the task itself does
+		if err := s.store.CompleteTaskTree(ctx, task, now, statusColumns...); err != nil {
+			return nil, err
+		}
+		return task, nil
+	}
+
+	if err := s.checkSubtasksClosed(ctx, task.ID); err != nil {
+		return nil, err
+	}
+	if err := s.store.UpdateTask(ctx, task, statusColumns...); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// Moves a done or cancelled task back to todo
+func (s *TaskService) ReopenTask(ctx context.Context, id string) (*models.Task, error) {
+	task, err := s.getTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !task.Status.IsClosed() {
+		return nil, fmt.Errorf("%w: task is %q, only done or cancelled tasks can be reopened", models.ErrInvalidTransition, task.Status)
+	}
+
+	now := s.now()
+	if err := task.TransitionTo(models.StatusTodo, now); err != nil {
+		return nil, err
+	}
+
+	task.UpdatedAt = bun.NullTime{Time: now}
+	if err := s.store.UpdateTask(ctx, task, statusColumns...); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// Lists the caller's tags along with how many tasks carry each
+func (s *TaskService) ListTags(ctx context.Context) ([]*models.Tag, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.ListTags(ctx)
+}
+
+// Renames a tag, keeping it on every task that carries it
+func (s *TaskService) RenameTag(ctx context.Context, name, newName string) (*models.Tag, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	name, err := models.NormalizeTag(name)
+	if err != nil {
+		return nil, err
+	}
+	newName, err = models.NormalizeTag(newName)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.RenameTag(ctx, name, newName)
+}
+
+// Folds the source tags into target, which is created when missing
+func (s *TaskService) MergeTags(ctx context.Context, sources []string, target string) (*models.Tag, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	sources, err := models.NormalizeTags(sources)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("%w: at least one source tag is required", models.ErrInvalidTag)
+	}
+
+	target, err = models.NormalizeTag(target)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.MergeTags(ctx, sources, target)
+}
+
+// Deletes a tag, untagging every task carrying it
+func (s *TaskService) DeleteTag(ctx context.Context, name string) error {
+	if err := authorize(ctx); err != nil {
+		return err
+	}
+
+	name, err := models.NormalizeTag(name)
+	if err != nil {
+		return err
+	}
+	return s.store.DeleteTag(ctx, name)
+}
+
+// Looks up a task, making sure it belongs to the caller
+func (s *TaskService) getTask(ctx context.Context, id string) (*models.Task, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	task, err := s.store.GetTask(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// our stores already scope by owner; this keeps a store that does not
+	// from handing someone else's task out. We answer as if it was missing
+	// so task ids cannot be probed for.
+	if userID, _ := auth.UserID(ctx); task.OwnerID != "" && task.OwnerID != userID {
+		return nil, fmt.Errorf("%w: task %s does not exist", repository.ErrNotFound, id)
+	}
+	return task, nil
+}
+
+// Validates fields and copies them onto task, moving it into a new status
+// if one was asked for
+func (s *TaskService) apply(ctx context.Context, task *models.Task, fields TaskFields, now time.Time) error {
+	title, err := normalizeTitle(fields.Title)
+	if err != nil {
+		return err
+	}
+	description, err := normalizeDescription(fields.Description)
+	if err != nil {
+		return err
+	}
+
+	if !fields.Priority.IsValid() {
+		return fmt.Errorf("%w: unknown priority %v", ErrInvalidTask, fields.Priority)
+	}
+
+	if !fields.StartAt.IsZero() && !fields.DueAt.IsZero() && fields.StartAt.After(fields.DueAt.Time) {
+		return fmt.Errorf("%w: start_at must not be after due_at", ErrInvalidTask)
+	}
+
+	names, err := models.NormalizeTags(fields.Tags)
+	if err != nil {
+		return err
+	}
+
+	if fields.Status != "" && fields.Status != task.Status {
+		if err := task.Status.ValidateTransition(fields.Status); err != nil {
+			return err
+		}
+		// only an existing task can have subtasks to check
+		if fields.Status == models.StatusDone && task.ID != "" {
+			if err := s.checkSubtasksClosed(ctx, task.ID); err != nil {
+				return err
+			}
+		}
+		if err := task.TransitionTo(fields.Status, now); err != nil {
+			return err
+		}
+	}
+
+	task.Title = title
+	task.Description = description
+	task.Priority = fields.Priority
+	task.StartAt = fields.StartAt
+	task.DueAt = fields.DueAt
+	task.Tags = make([]*models.Tag, 0, len(names))
+	for _, name := range names {
+		task.Tags = append(task.Tags, &models.Tag{Name: name})
+	}
+	task.ParentID = fields.ParentID
+	task.ProjectID = fields.ProjectID
+	return nil
+}
+
+// Rejects acting on a task that has moved on from the etag the caller
+// last read it at. An empty etag matches any version.
+func checkETag(task *models.Task, etag string) error {
+	if etag == "" || etag == task.ETag() {
+		return nil
+	}
+	return fmt.Errorf("%w: task %s is at %s, not %s", repository.ErrStale, task.ID, task.ETag(), etag)
+}
+
+// Takes the fields named in mask from update and the rest from current,
+// returning the columns they write. An empty mask takes every field from
+// update and leaves the columns empty, meaning all of them.
+func maskFields(current, update TaskFields, mask []string) (TaskFields, []string, error) {
+	if len(mask) == 0 {
+		return update, nil, nil
+	}
+
+	fields := current
+	columns := []string{"updated_at"}
+	for _, path := range mask {
+		written, ok := maskColumns[path]
+		if !ok {
+			return fields, nil, fmt.Errorf("%w: %q is not a field that can be updated", ErrInvalidTask, path)
+		}
+		for _, column := range written {
+			if !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+
+		switch path {
+		case "title":
+			fields.Title = update.Title
+		case "description":
+			fields.Description = update.Description
+		case "status":
+			if update.Status == "" {
+				return fields, nil, fmt.Errorf("%w: status cannot be cleared", ErrInvalidTask)
+			}
+			fields.Status = update.Status
+		case "priority":
+			fields.Priority = update.Priority
+		case "start_at":
+			fields.StartAt = update.StartAt
+		case "due_at":
+			fields.DueAt = update.DueAt
+		case "tags":
+			fields.Tags = update.Tags
+		case "parent_id":
+			fields.ParentID = update.ParentID
+		case "project_id":
+			fields.ProjectID = update.ProjectID
+		}
+	}
+	return fields, columns, nil
+}
+
+// The fields of a task as they currently are
+func fieldsOf(task *models.Task) TaskFields {
+	return TaskFields{
+		Title:       task.Title,
+		Description: task.Description,
+		Status:      task.Status,
+		Priority:    task.Priority,
+		StartAt:     task.StartAt,
+		DueAt:       task.DueAt,
+		Tags:        models.TagNames(task.Tags),
+		ParentID:    task.ParentID,
+		ProjectID:   task.ProjectID,
+	}
+}
+
+// Rejects completing a task while any subtask below it is still open
+func (s *TaskService) checkSubtasksClosed(ctx context.Context, id string) error {
+	open, err := s.store.CountOpenDescendants(ctx, id)
+	if err != nil {
+		return err
+	}
+	if open > 0 {
+		return fmt.Errorf("%w: %d subtasks are still open, complete them first or force completion", ErrOpenSubtasks, open)
+	}
+	return nil
+}
+
+// Every task belongs to someone, so we refuse to act for nobody. Which
+// tasks the user then gets to see is down to the scoping of our stores.
+func authorize(ctx context.Context) error {
+	if _, ok := auth.UserID(ctx); !ok {
+		return fmt.Errorf("%w: tasks can only be managed on behalf of a user", ErrUnauthenticated)
+	}
+	return nil
+}
+
+// Trims the title and checks it is a single line of a sensible length
+func normalizeTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+
+	if title == "" {
+		return "", fmt.Errorf("%w: title is required", ErrInvalidTask)
+	}
+	if utf8.RuneCountInString(title) > MaxTitleLength {
+		return "", fmt.Errorf("%w: title is longer than %d characters", ErrInvalidTask, MaxTitleLength)
+	}
+	if strings.IndexFunc(title, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("%w: title must be a single line without control characters", ErrInvalidTask)
+	}
+	return title, nil
+}
+
+// Trims the description, which may be empty but not endless
+func normalizeDescription(description string) (string, error) {
+	description = strings.TrimSpace(description)
+
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		return "", fmt.Errorf("%w: description is longer than %d characters", ErrInvalidTask, MaxDescriptionLength)
+	}
+	return description, nil
+}
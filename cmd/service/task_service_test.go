@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/shared/auth"
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+func TestTaskService(t *testing.T) {
+	store := repository.NewMemoryTaskStore()
+	tasks := NewTaskService(store)
+
+	now := time.Date(2025, 3, 19, 8, 58, 10, 0, time.UTC)
+	tasks.now = func() time.Time { return now }
+
+	ctx := auth.WithUserID(context.Background(), uuid.New().String())
+
+	t.Run("Validate Fields", func(t *testing.T) {
+		task, err := tasks.CreateTask(ctx, TaskFields{Title: "  Buy groceries\t", Description: "\nMilk, Bread, Eggs  "})
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		if task.Title != "Buy groceries" || task.Description != "Milk, Bread, Eggs" {
+			t.Errorf("Expected trimmed fields, got %q and %q", task.Title, task.Description)
+		}
+		if !task.CreatedAt.Equal(now) || task.Status != models.StatusTodo {
+			t.Errorf("Expected a todo task created at %v, got %q created at %v", now, task.Status, task.CreatedAt)
+		}
+
+		invalid := []TaskFields{
+			{Title: "   "},
+			{Title: strings.Repeat("a", MaxTitleLength+1)},
+			{Title: "Two\nlines"},
+			{Title: "Essay", Description: strings.Repeat("a", MaxDescriptionLength+1)},
+			{Title: "Backwards", StartAt: bun.NullTime{Time: now}, DueAt: bun.NullTime{Time: now.Add(-time.Hour)}},
+		}
+		for _, fields := range invalid {
+			if _, err := tasks.CreateTask(ctx, fields); !errors.Is(err, ErrInvalidTask) {
+				t.Errorf("Expected ErrInvalidTask for %+v, got %v", fields, err)
+			}
+		}
+
+		if _, err := tasks.CreateTask(ctx, TaskFields{Title: "Tagged", Tags: []string{" "}}); !errors.Is(err, models.ErrInvalidTag) {
+			t.Errorf("Expected ErrInvalidTag, got %v", err)
+		}
+	})
+
+	t.Run("Stamp Updates", func(t *testing.T) {
+		task, err := tasks.CreateTask(ctx, TaskFields{Title: "Write report"})
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+
+		now = now.Add(time.Hour)
+		updated, err := tasks.UpdateTask(ctx, task.ID, TaskFields{Title: "Write the report", Status: models.StatusDone}, nil, "")
+		if err != nil {
+			t.Fatalf("Failed to update task: %v", err)
+		}
+		if !updated.UpdatedAt.Equal(now) || !updated.CompletedAt.Equal(now) {
+			t.Errorf("Expected the task updated and completed at %v, got %v and %v", now, updated.UpdatedAt, updated.CompletedAt)
+		}
+		if !updated.CreatedAt.Equal(task.CreatedAt) {
+			t.Errorf("Expected CreatedAt to be left alone, got %v", updated.CreatedAt)
+		}
+
+		if _, err := tasks.UpdateTask(ctx, task.ID, TaskFields{Title: "Write the report", Status: models.StatusBlocked}, nil, ""); !errors.Is(err, models.ErrInvalidTransition) {
+			t.Errorf("Expected ErrInvalidTransition moving a done task to blocked, got %v", err)
+		}
+	})
+
+	t.Run("Update Masked Fields", func(t *testing.T) {
+		due := bun.NullTime{Time: now.Add(24 * time.Hour)}
+		task, err := tasks.CreateTask(ctx, TaskFields{Title: "Plan sprint", Description: "Keep me", DueAt: due, Tags: []string{"planning"}})
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+
+		updated, err := tasks.UpdateTask(ctx, task.ID, TaskFields{Title: " Plan the sprint "}, []string{"title"}, "")
+		if err != nil {
+			t.Fatalf("Failed to update the title: %v", err)
+		}
+		if updated.Title != "Plan the sprint" || updated.Description != "Keep me" || !updated.DueAt.Equal(due.Time) {
+			t.Errorf("Expected only the title to change, got %q, %q due %v", updated.Title, updated.Description, updated.DueAt)
+		}
+		if names := models.TagNames(updated.Tags); len(names) != 1 || names[0] != "planning" {
+			t.Errorf("Expected the tags left alone, got %v", names)
+		}
+
+		cleared, err := tasks.UpdateTask(ctx, task.ID, TaskFields{}, []string{"due_at", "tags"}, "")
+		if err != nil {
+			t.Fatalf("Failed to clear the deadline: %v", err)
+		}
+		if !cleared.DueAt.IsZero() || len(cleared.Tags) != 0 || cleared.Title != "Plan the sprint" {
+			t.Errorf("Expected the deadline and tags cleared and the title kept, got %v, %v and %q", cleared.DueAt, models.TagNames(cleared.Tags), cleared.Title)
+		}
+
+		if _, err := tasks.UpdateTask(ctx, task.ID, TaskFields{}, []string{"owner_id"}, ""); !errors.Is(err, ErrInvalidTask) {
+			t.Errorf("Expected ErrInvalidTask for a field that cannot be updated, got %v", err)
+		}
+		if _, err := tasks.UpdateTask(ctx, task.ID, TaskFields{}, []string{"title"}, ""); !errors.Is(err, ErrInvalidTask) {
+			t.Errorf("Expected ErrInvalidTask clearing the title, got %v", err)
+		}
+	})
+
+	t.Run("Honour ETags", func(t *testing.T) {
+		task, err := tasks.CreateTask(ctx, TaskFields{Title: "Contested"})
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		read := task.ETag()
+
+		updated, err := tasks.UpdateTask(ctx, task.ID, TaskFields{Title: "Mine"}, []string{"title"}, read)
+		if err != nil {
+			t.Fatalf("Failed to update at the current etag: %v", err)
+		}
+		if updated.ETag() == read {
+			t.Errorf("Expected the etag to change with the task, still %s", read)
+		}
+
+		if _, err := tasks.UpdateTask(ctx, task.ID, TaskFields{Title: "Theirs"}, []string{"title"}, read); !errors.Is(err, repository.ErrStale) {
+			t.Errorf("Expected ErrStale updating at an old etag, got %v", err)
+		}
+		if err := tasks.DeleteTask(ctx, task.ID, read); !errors.Is(err, repository.ErrStale) {
+			t.Errorf("Expected ErrStale deleting at an old etag, got %v", err)
+		}
+		if err := tasks.DeleteTask(ctx, task.ID, updated.ETag()); err != nil {
+			t.Errorf("Failed to delete at the current etag: %v", err)
+		}
+	})
+
+	t.Run("Empty The Trash", func(t *testing.T) {
+		task, err := tasks.CreateTask(ctx, TaskFields{Title: "Throwaway"})
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+
+		if err := tasks.DeleteTask(ctx, task.ID, ""); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		if _, err := tasks.GetTask(ctx, task.ID, false); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a task in the trash, got %v", err)
+		}
+		trash, _, err := tasks.ListTrash(ctx, repository.TaskPage{})
+		if err != nil || len(trash) == 0 || trash[0].ID != task.ID {
+			t.Errorf("Expected the task at the top of the trash, got %d tasks and %v", len(trash), err)
+		}
+
+		restored, err := tasks.RestoreTask(ctx, task.ID)
+		if err != nil {
+			t.Fatalf("Failed to restore task: %v", err)
+		}
+		if !restored.UpdatedAt.Equal(now) {
+			t.Errorf("Expected the restored task updated at %v, got %v", now, restored.UpdatedAt)
+		}
+
+		if err := tasks.DeleteTask(ctx, task.ID, ""); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		if purged, err := tasks.PurgeTrash(context.Background(), DefaultTrashRetention); err != nil || purged != 0 {
+			t.Errorf("Expected nothing purged within the retention window, got %d and %v", purged, err)
+		}
+
+		// the store stamps tasks with the actual time they were trashed at
+		saved := now
+		now = time.Now().Add(DefaultTrashRetention + time.Minute)
+		defer func() { now = saved }()
+
+		if purged, err := tasks.PurgeTrash(context.Background(), DefaultTrashRetention); err != nil || purged == 0 {
+			t.Errorf("Expected the trash purged once the retention window passed, got %d and %v", purged, err)
+		}
+		if _, err := tasks.RestoreTask(ctx, task.ID); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected the task gone for good, got %v", err)
+		}
+	})
+
+	t.Run("Guard Completion", func(t *testing.T) {
+		parent, err := tasks.CreateTask(ctx, TaskFields{Title: "Release"})
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+		child, err := tasks.CreateTask(ctx, TaskFields{Title: "Tag the release", ParentID: parent.ID})
+		if err != nil {
+			t.Fatalf("Failed to create subtask: %v", err)
+		}
+
+		if _, err := tasks.CompleteTask(ctx, parent.ID, false); !errors.Is(err, ErrOpenSubtasks) {
+			t.Errorf("Expected ErrOpenSubtasks, got %v", err)
+		}
+		if _, err := tasks.UpdateTask(ctx, parent.ID, TaskFields{Title: "Release", Status: models.StatusDone}, nil, ""); !errors.Is(err, ErrOpenSubtasks) {
+			t.Errorf("Expected ErrOpenSubtasks, got %v", err)
+		}
+		if _, err := tasks.ReopenTask(ctx, parent.ID); !errors.Is(err, models.ErrInvalidTransition) {
+			t.Errorf("Expected ErrInvalidTransition reopening an open task, got %v", err)
+		}
+
+		if _, err := tasks.CompleteTask(ctx, parent.ID, true); err != nil {
+			t.Fatalf("Failed to force completion: %v", err)
+		}
+		if saved, _ := tasks.GetTask(ctx, child.ID, false); saved.Status != models.StatusDone {
+			t.Errorf("Expected the subtask completed along with its parent, got %q", saved.Status)
+		}
+
+		reopened, err := tasks.ReopenTask(ctx, parent.ID)
+		if err != nil {
+			t.Fatalf("Failed to reopen task: %v", err)
+		}
+		if reopened.Status != models.StatusTodo || !reopened.CompletedAt.IsZero() {
+			t.Errorf("Expected a todo task without CompletedAt, got %q completed at %v", reopened.Status, reopened.CompletedAt)
+		}
+	})
+
+	t.Run("Authorize Callers", func(t *testing.T) {
+		task, err := tasks.CreateTask(ctx, TaskFields{Title: "Private"})
+		if err != nil {
+			t.Fatalf("Failed to create task: %v", err)
+		}
+
+		if _, err := tasks.GetTask(context.Background(), task.ID, false); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Expected ErrUnauthenticated without a user, got %v", err)
+		}
+		if _, err := tasks.CreateTask(context.Background(), TaskFields{Title: "Anonymous"}); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Expected ErrUnauthenticated without a user, got %v", err)
+		}
+		if _, _, err := tasks.ListTrash(context.Background(), repository.TaskPage{}); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Expected ErrUnauthenticated without a user, got %v", err)
+		}
+		if _, _, err := tasks.SearchTasks(context.Background(), "private", repository.TaskPage{}); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Expected ErrUnauthenticated without a user, got %v", err)
+		}
+		if _, _, err := tasks.CountTasks(context.Background(), repository.TaskFilter{}, ""); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Expected ErrUnauthenticated without a user, got %v", err)
+		}
+		watch := func(*models.TaskEvent) error { return nil }
+		if err := tasks.WatchTasks(context.Background(), repository.TaskFilter{}, "", watch); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Expected ErrUnauthenticated without a user, got %v", err)
+		}
+
+		someoneElse := auth.WithUserID(context.Background(), uuid.New().String())
+		if _, err := tasks.GetTask(someoneElse, task.ID, false); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for another user's task, got %v", err)
+		}
+		if err := tasks.DeleteTask(someoneElse, task.ID, ""); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound deleting another user's task, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/50-Course/notes-tracker/cmd/repository"
+	"github.com/50-Course/notes-tracker/shared/models"
+)
+
+// How long deleted tasks stay in the trash before our purge removes them
+// for good, unless configured otherwise
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// Lists a page of the caller's trash, most recently deleted first
+func (s *TaskService) ListTrash(ctx context.Context, page repository.TaskPage) ([]*models.Task, string, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, "", err
+	}
+	return s.store.ListTrash(ctx, page)
+}
+
+// Takes a task out of the trash along with its subtasks and returns it
+func (s *TaskService) RestoreTask(ctx context.Context, id string) (*models.Task, error) {
+	if err := authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.store.RestoreTask(ctx, id, s.now()); err != nil {
+		return nil, err
+	}
+	return s.getTask(ctx, id)
+}
+
+// Deletes a task in the trash for good, along with every subtask below it
+func (s *TaskService) PermanentlyDeleteTask(ctx context.Context, id string) error {
+	if err := authorize(ctx); err != nil {
+		return err
+	}
+	return s.store.PermanentlyDeleteTask(ctx, id)
+}
+
+// Deletes everything that has sat in the trash for longer than retention,
+// whoever it belongs to, and returns how many tasks went. Unlike the rest
+// of TaskService it acts for nobody in particular, so it is only ever
+// called by our own maintenance jobs.
+func (s *TaskService) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	return s.store.PurgeTrash(ctx, s.now().Add(-retention))
+}
+
+// Purges the trash every interval until ctx is done. Failed purges are
+// logged and retried on the next tick.
+func (s *TaskService) RunTrashPurge(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeTrash(ctx, retention)
+		switch {
+		case err != nil:
+			log.Printf("[Trash] Failed to purge the trash: %v", err)
+		case purged > 0:
+			log.Printf("[Trash] Purged %d tasks deleted more than %s ago", purged, retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/50-Course/notes-tracker/scripts/migrations"
+	"github.com/50-Course/notes-tracker/shared/utils"
+	"github.com/joho/godotenv"
+	"github.com/uptrace/bun"
+)
+
+const usage = `Manages the database schema of the notes tracker.
+
+Usage:
+
+	go run ./scripts/migrate.go <command> [flags] [arguments]
+
+Commands:
+
+	status               list every migration and whether it has been applied
+	up                   apply every pending migration
+	down [N]             revert the last N applied migrations, 1 by default
+	redo                 revert the last applied migration and apply it again
+	goto VERSION         apply or revert migrations until VERSION is the latest applied, 0 reverts everything
+	create NAME          write an empty migration to fill in by hand
+	makemigrations       diff our models against the database and write the migration bringing them in line
+	assign-owner EMAIL   hand every task without an owner to the user signed up with EMAIL
+
+Flags:
+`
+
+// Heavily inspired by Django's manage.py migrate and makemigrations.
+//
+// up, down, redo and goto run the migrations built into the binary, so the
+// image can migrate a database on its own before the core starts:
+//
+//	go run ./scripts/migrate.go status
+//	go run ./scripts/migrate.go down --dry-run 2
+//	go run ./scripts/migrate.go makemigrations -name add_task_estimates
+//
+// With --dry-run the SQL is printed rather than executed. makemigrations
+// -check writes nothing and exits non-zero when our models and migrations
+// disagree, which is what CI runs after applying migrations.
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	dryRun := flags.Bool("dry-run", false, "print the SQL instead of executing it")
+	dir := flags.String("dir", "scripts/migrations/sql", "directory create and makemigrations write migration files to")
+	check := flags.Bool("check", false, "makemigrations only reports whether a migration is needed, failing if so")
+	name := flags.String("name", "auto", "name of the migration makemigrations generates, i.e. add_task_estimates")
+	args := parseInterspersed(flags, os.Args[2:])
+
+	// creating a migration is the one command that needs no database
+	if command == "create" {
+		if len(args) != 1 {
+			log.Fatal("usage: migrate create NAME")
+		}
+		migration, err := migrations.CreateMigration(*dir, args[0], time.Now())
+		if err != nil {
+			log.Fatalf("[Migrations] Failed to create migration: %v", err)
+		}
+		log.Printf("[Migrations] Created %s/%s.{up,down}.sql", *dir, migration)
+		return
+	}
+
+	if err := godotenv.Load("config/.env"); err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	db, err := utils.ConnectFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if command == "makemigrations" {
+		makeMigrations(ctx, db, *dir, *name, *check)
+		return
+	}
+
+	if command == "assign-owner" {
+		if len(args) != 1 {
+			log.Fatal("usage: migrate assign-owner EMAIL")
+		}
+		assigned, err := migrations.AssignOwner(ctx, db, args[0])
+		if err != nil {
+			log.Fatalf("[Migrations] Failed to assign tasks to %s: %v", args[0], err)
+		}
+		log.Printf("[Migrations] Handed %d tasks over to %s", assigned, args[0])
+		return
+	}
+
+	shipped, err := migrations.DefaultMigrations(db.Dialect().Name())
+	if err != nil {
+		log.Fatalf("[Migrations] Failed to load migrations: %v", err)
+	}
+
+	migrator := migrations.NewMigrator(db, shipped)
+	if *dryRun {
+		migrator = migrator.DryRun(os.Stdout)
+	}
+
+	var steps []migrations.Step
+	switch command {
+	case "status":
+		printStatus(ctx, migrator)
+		return
+	case "up":
+		steps, err = migrator.Up(ctx)
+	case "down":
+		steps, err = migrator.Down(ctx, countArg(args, 1))
+	case "redo":
+		steps, err = migrator.Redo(ctx)
+	case "goto":
+		if len(args) != 1 {
+			log.Fatal("usage: migrate goto VERSION")
+		}
+		version, parseErr := strconv.ParseInt(args[0], 10, 64)
+		if parseErr != nil || version < 0 {
+			log.Fatalf("VERSION must be a migration version, got %q", args[0])
+		}
+		steps, err = migrator.Goto(ctx, version)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("[Migrations] %s failed: %v", command, err)
+	}
+
+	switch {
+	case len(steps) == 0:
+		log.Println("[Migrations] Nothing to do, the database is already there")
+	case *dryRun:
+		log.Printf("[Migrations] Dry run, %d steps printed and none executed", len(steps))
+	default:
+		log.Printf("[Migrations] Done, %d steps taken", len(steps))
+	}
+}
+
+func printStatus(ctx context.Context, migrator *migrations.Migrator) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		log.Fatalf("[Migrations] Failed to read migration status: %v", err)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+
+	pending := 0
+	for _, status := range statuses {
+		state, appliedAt := "applied", status.AppliedAt.Local().Format(time.DateTime)
+		switch {
+		case status.Pending():
+			state, appliedAt = "pending", "-"
+			pending++
+		case status.Unknown:
+			state = "applied, no file"
+		case status.Modified:
+			state = "applied, modified since"
+		}
+		fmt.Fprintf(out, "%04d\t%s\t%s\t%s\n", status.Migration.Version, status.Migration.Name, state, appliedAt)
+	}
+	out.Flush()
+
+	log.Printf("\n[Migrations] %d migrations, %d pending", len(statuses), pending)
+}
+
+func makeMigrations(ctx context.Context, db *bun.DB, dir, name string, check bool) {
+	existing, err := migrations.LoadMigrations(os.DirFS(dir), db.Dialect().Name())
+	if err != nil {
+		log.Fatalf("[Migrations] Failed to load migrations from %s: %v", dir, err)
+	}
+
+	changes, err := migrations.MakeMigrations(ctx, db, existing)
+	if err != nil {
+		log.Fatalf("[Migrations] Failed to compare models with the database: %v", err)
+	}
+
+	if len(changes) == 0 {
+		log.Println("[Migrations] No changes detected, models and migrations agree")
+		return
+	}
+
+	for _, change := range changes {
+		fmt.Printf("  - %s\n", change.Description)
+	}
+
+	if check {
+		log.Fatalf("[Migrations] %d changes are missing a migration, run makemigrations to generate one", len(changes))
+	}
+
+	migration, err := migrations.WriteMigration(dir, name, changes, time.Now())
+	if err != nil {
+		log.Fatalf("[Migrations] Failed to write migration: %v", err)
+	}
+	log.Printf("[Migrations] Wrote %s/%s.{up,down}.sql", dir, migration)
+}
+
+// Parses flags wherever they appear, so `down 2 --dry-run` works just as
+// well as `down --dry-run 2`. Returns the positional arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Reads the optional count argument, i.e. the N of `down N`
+func countArg(args []string, fallback int) int {
+	if len(args) == 0 {
+		return fallback
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		log.Fatalf("expected a positive count, got %q", args[0])
+	}
+	return n
+}
@@ -0,0 +1,318 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// A single difference between our models and the live schema, along with
+// the SQL bringing the schema in line and the SQL undoing that
+type Change struct {
+	Description string
+	Up          string
+	Down        string
+}
+
+// Compares the live schema against our models, returning the changes a
+// new migration needs to make. Tables without a model are left alone.
+func Diff(db *bun.DB, models, live *Schema) []Change {
+	var changes []Change
+
+	for _, model := range installedSchemas {
+		table := models.Tables[db.Table(reflectType(model)).Name]
+
+		current, ok := live.Tables[table.Name]
+		if !ok {
+			changes = append(changes, Change{
+				Description: fmt.Sprintf("create table %s", table.Name),
+				Up:          db.NewCreateTable().Model(table.model).WithForeignKeys().String() + ";",
+				Down:        fmt.Sprintf("DROP TABLE %q;", table.Name),
+			})
+			continue
+		}
+
+		changes = append(changes, diffColumns(table, current)...)
+	}
+
+	return append(changes, diffIndexes(models, live)...)
+}
+
+func diffColumns(table, current *Table) []Change {
+	var changes []Change
+
+	for _, column := range table.Columns {
+		existing, ok := current.column(column.Name)
+		if !ok {
+			changes = append(changes, Change{
+				Description: fmt.Sprintf("add column %s.%s", table.Name, column.Name),
+				Up:          fmt.Sprintf("ALTER TABLE %q ADD COLUMN %s;", table.Name, columnDefinition(column)),
+				Down:        fmt.Sprintf("ALTER TABLE %q DROP COLUMN %q;", table.Name, column.Name),
+			})
+			continue
+		}
+		changes = append(changes, alterColumn(table.Name, existing, column)...)
+	}
+
+	for _, column := range current.Columns {
+		if _, ok := table.column(column.Name); ok {
+			continue
+		}
+		// whatever the column held is gone for good, the down step only brings the column back
+		column.NotNull = false
+		changes = append(changes, Change{
+			Description: fmt.Sprintf("drop column %s.%s", table.Name, column.Name),
+			Up:          fmt.Sprintf("ALTER TABLE %q DROP COLUMN %q;", table.Name, column.Name),
+			Down:        fmt.Sprintf("ALTER TABLE %q ADD COLUMN %s;", table.Name, columnDefinition(column)),
+		})
+	}
+	return changes
+}
+
+// works out the statements moving a column from what it is to what it should be
+func alterColumn(table string, from, to Column) []Change {
+	var changes []Change
+	alter := fmt.Sprintf("ALTER TABLE %q ALTER COLUMN %q", table, to.Name)
+
+	if from.Type != to.Type {
+		changes = append(changes, Change{
+			Description: fmt.Sprintf("change type of %s.%s from %s to %s", table, to.Name, from.Type, to.Type),
+			Up:          fmt.Sprintf("%s TYPE %s USING %q::%s;", alter, to.Type, to.Name, to.Type),
+			Down:        fmt.Sprintf("%s TYPE %s USING %q::%s;", alter, from.Type, to.Name, from.Type),
+		})
+	}
+
+	if from.Default != to.Default {
+		changes = append(changes, Change{
+			Description: fmt.Sprintf("change default of %s.%s", table, to.Name),
+			Up:          setDefault(alter, to.Default),
+			Down:        setDefault(alter, from.Default),
+		})
+	}
+
+	if from.NotNull != to.NotNull {
+		changes = append(changes, Change{
+			Description: fmt.Sprintf("change nullability of %s.%s", table, to.Name),
+			Up:          setNotNull(alter, to.NotNull),
+			Down:        setNotNull(alter, from.NotNull),
+		})
+	}
+	return changes
+}
+
+func diffIndexes(models, live *Schema) []Change {
+	var changes []Change
+
+	for _, name := range sortedKeys(models.Indexes) {
+		index := models.Indexes[name]
+		if existing, ok := live.Indexes[name]; ok && slices.Equal(existing.Columns, index.Columns) {
+			continue
+		}
+		if _, ok := live.Indexes[name]; ok {
+			// same name, different columns: rebuild it
+			changes = append(changes, dropIndex(live.Indexes[name]))
+		}
+		changes = append(changes, createIndex(index))
+	}
+
+	for _, name := range sortedKeys(live.Indexes) {
+		index := live.Indexes[name]
+		if _, ok := models.Indexes[name]; ok {
+			continue
+		}
+		// indexes on tables we do not manage are none of our business
+		if _, ok := models.Tables[index.Table]; !ok {
+			continue
+		}
+		changes = append(changes, dropIndex(index))
+	}
+	return changes
+}
+
+func createIndex(index Index) Change {
+	return Change{
+		Description: fmt.Sprintf("create index %s", index.Name),
+		Up:          indexDefinition(index),
+		Down:        fmt.Sprintf("DROP INDEX %q;", index.Name),
+	}
+}
+
+func dropIndex(index Index) Change {
+	return Change{
+		Description: fmt.Sprintf("drop index %s", index.Name),
+		Up:          fmt.Sprintf("DROP INDEX %q;", index.Name),
+		Down:        indexDefinition(index),
+	}
+}
+
+func indexDefinition(index Index) string {
+	columns := make([]string, 0, len(index.Columns))
+	for _, column := range index.Columns {
+		columns = append(columns, fmt.Sprintf("%q", column))
+	}
+	using := ""
+	if index.Using != "" {
+		using = " USING " + index.Using
+	}
+	return fmt.Sprintf("CREATE INDEX %q ON %q%s (%s);", index.Name, index.Table, using, strings.Join(columns, ", "))
+}
+
+func columnDefinition(column Column) string {
+	definition := fmt.Sprintf("%q %s", column.Name, column.Type)
+	if column.NotNull {
+		definition += " NOT NULL"
+	}
+	if column.Default != "" {
+		definition += " DEFAULT " + column.Default
+	}
+	if column.References != "" {
+		definition += " REFERENCES " + column.References
+	}
+	return definition
+}
+
+func setDefault(alter, value string) string {
+	if value == "" {
+		return alter + " DROP DEFAULT;"
+	}
+	return fmt.Sprintf("%s SET DEFAULT %s;", alter, value)
+}
+
+func setNotNull(alter string, notNull bool) string {
+	if notNull {
+		return alter + " SET NOT NULL;"
+	}
+	return alter + " DROP NOT NULL;"
+}
+
+func sortedKeys(indexes map[string]Index) []string {
+	keys := make([]string, 0, len(indexes))
+	for key := range indexes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// names given to generated migrations have to fit our file name pattern
+var migrationNamePattern = regexp.MustCompile(`^\w+$`)
+
+// Writes changes out as the next numbered migration in dir, returning it.
+// The down file reverts the changes in the opposite order. The SQL is
+// Postgres', so the files are written for Postgres alone.
+func WriteMigration(dir, name string, changes []Change, now time.Time) (Migration, error) {
+	migration, err := nextMigration(dir, name)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	header := fmt.Sprintf("-- Generated by makemigrations on %s, review before applying.\n", now.UTC().Format(time.RFC3339))
+	header += fmt.Sprintf("-- Written for Postgres, SQLite needs %s.sqlite.{up,down}.sql of its own.\n", migration)
+	up, down := []string{header}, []string{header}
+	for i := range changes {
+		up = append(up, fmt.Sprintf("-- %s\n%s\n", changes[i].Description, changes[i].Up))
+		reverse := changes[len(changes)-1-i]
+		down = append(down, fmt.Sprintf("-- undo %s\n%s\n", reverse.Description, reverse.Down))
+	}
+	migration.Up = strings.Join(up, "\n")
+	migration.Down = strings.Join(down, "\n")
+
+	return migration, writeMigration(dir, migration, dialect.PG)
+}
+
+// Writes an empty pair of migration files under the next version, for
+// the changes makemigrations cannot work out by itself such as data fixes
+func CreateMigration(dir, name string, now time.Time) (Migration, error) {
+	migration, err := nextMigration(dir, name)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	created := now.UTC().Format(time.RFC3339)
+	migration.Up = fmt.Sprintf("-- %s, created on %s.\n", name, created)
+	migration.Down = fmt.Sprintf("-- Reverts %s, created on %s.\n", name, created)
+
+	return migration, writeMigration(dir, migration, dialect.Invalid)
+}
+
+// Names the migration following the ones already in dir, whichever
+// database they were written for
+func nextMigration(dir, name string) (Migration, error) {
+	if !migrationNamePattern.MatchString(name) {
+		return Migration{}, fmt.Errorf("migration name %q may only contain letters, digits and underscores", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return Migration{}, err
+	}
+
+	migration := Migration{Version: 1, Name: name}
+	for _, entry := range entries {
+		if match := migrationFilePattern.FindStringSubmatch(entry.Name()); match != nil {
+			version, _ := strconv.ParseInt(match[1], 10, 64)
+			migration.Version = max(migration.Version, version+1)
+		}
+	}
+	return migration, nil
+}
+
+// Writes the migration's files into dir, as the files of a single database
+// when one is given and as the files every database shares otherwise
+func writeMigration(dir string, migration Migration, db dialect.Name) error {
+	prefix := migration.String()
+	if suffix, ok := dialectSuffixes[db]; ok {
+		prefix += "." + suffix
+	}
+
+	for direction, contents := range map[string]string{"up": migration.Up, "down": migration.Down} {
+		file := filepath.Join(dir, fmt.Sprintf("%s.%s.sql", prefix, direction))
+		if err := os.WriteFile(file, []byte(contents), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Inspects the database behind db and diffs it against our models. Every
+// migration in migrations has to be applied first, or we would end up
+// generating what they already do.
+//
+// Only Postgres can be inspected; SQLite cannot alter columns in place, so
+// its migrations are written by hand.
+func MakeMigrations(ctx context.Context, db *bun.DB, migrations []Migration) ([]Change, error) {
+	if db.Dialect().Name() != dialect.PG {
+		return nil, fmt.Errorf("makemigrations needs a Postgres database, not %s", db.Dialect().Name())
+	}
+
+	applied, err := NewMigrator(db, migrations).Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration.String())
+		}
+	}
+	if len(pending) > 0 {
+		return nil, fmt.Errorf("apply the pending migrations %s first", strings.Join(pending, ", "))
+	}
+
+	live, err := InspectSchema(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(db, ModelSchema(db), live), nil
+}
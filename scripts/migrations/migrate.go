@@ -13,28 +13,52 @@ import (
 	_ "github.com/uptrace/bun/driver/pgdriver"
 )
 
-// Heavily inspired by Django's makemigrations command
-// This function will create the migrations for the models or tables
-// that are not yet migrated
+// the models our migrations are expected to keep the schema in line with
+var installedSchemas = []interface{}{
+	// Add your models here
+	// referenced tables have to come before the tables referencing them
+	(*models.User)(nil),
+	(*models.APIKey)(nil),
+	(*models.Project)(nil),
+	(*models.Task)(nil),
+	(*models.Tag)(nil),
+	(*models.TaskTag)(nil),
+	(*models.SavedView)(nil),
+	(*models.TaskEvent)(nil),
+}
+
+// the secondary indexes our migrations are expected to create, mostly
+// covering the foreign keys we join and filter on
+var installedIndexes = []Index{
+	{Table: "api_keys", Name: "api_keys_user_id_idx", Columns: []string{"user_id"}},
+	{Table: "projects", Name: "projects_owner_id_idx", Columns: []string{"owner_id"}},
+	{Table: "tasks", Name: "tasks_parent_id_idx", Columns: []string{"parent_id"}},
+	{Table: "tasks", Name: "tasks_owner_id_idx", Columns: []string{"owner_id"}},
+	{Table: "tasks", Name: "tasks_project_id_idx", Columns: []string{"project_id"}},
+	{Table: "tasks", Name: "tasks_deleted_at_idx", Columns: []string{"deleted_at"}},
+	{Table: "tasks", Name: "tasks_search_vector_idx", Columns: []string{"search_vector"}, Using: "GIN"},
+	{Table: "task_tags", Name: "task_tags_tag_id_idx", Columns: []string{"tag_id"}},
+	{Table: "task_events", Name: "task_events_created_at_idx", Columns: []string{"created_at"}},
+	{Table: "task_events", Name: "task_events_txid_id_idx", Columns: []string{"txid", "id"}},
+}
+
+// Heavily inspired by Django's migrate command
+// This function applies every migration in sql/ that the database has
+// not seen yet, in order. See Migrator for the details.
 func RunMigrations(db *bun.DB) error {
 	ctx := context.Background()
 
-	// here we keep trrrack of the models to migrate
-	installedSchemas := []interface{}{
-		// Add your models here
-		(*models.Task)(nil),
+	migrations, err := DefaultMigrations(db.Dialect().Name())
+	if err != nil {
+		return err
 	}
 
-	for _, model := range installedSchemas {
-		if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
-			// log.Panicf("[Migrations] Failed to apply migrations: %v", err)
-			return err
-		}
-
-		log.Printf("[Migrations] Migrated model: %T", model)
+	applied, err := NewMigrator(db, migrations).Up(ctx)
+	if err != nil {
+		// log.Panicf("[Migrations] Failed to apply migrations: %v", err)
+		return err
 	}
 
-	log.Println("[Migrations] Migrations completed successfully")
+	log.Printf("[Migrations] Migrations completed successfully, %d applied", len(applied))
 	return nil
 }
-
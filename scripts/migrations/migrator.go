@@ -0,0 +1,521 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+//go:embed sql/*.sql
+var embeddedMigrations embed.FS
+
+// migration files are named <version>_<name>[.<database>].<up|down>.sql,
+// i.e. 0001_initial.up.sql or 0001_initial.sqlite.up.sql
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+?)(?:\.(postgres|sqlite))?\.(up|down)\.sql$`)
+
+// how the databases we support are named in migration files
+var dialectSuffixes = map[dialect.Name]string{
+	dialect.PG:     "postgres",
+	dialect.SQLite: "sqlite",
+}
+
+// the key our migrators serialize on through pg_advisory_lock, so two
+// instances starting at once do not both try to apply the same migration
+const advisoryLockID int64 = 0x6e6f746573 // "notes"
+
+var (
+	// an applied migration no longer matches its file
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	// the database has a migration applied that we have no file for
+	ErrUnknownMigration = errors.New("unknown migration applied")
+)
+
+// A single versioned schema change along with the SQL to revert it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Identifies the migration's up and down SQL, so we notice when an applied
+// file is edited after the fact. Each is prefixed with its length, so SQL
+// moving from one file to the other changes it too.
+func (m Migration) Checksum() string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d:%s%d:%s", len(m.Up), m.Up, len(m.Down), m.Down)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// A row of our schema_migrations tracking table
+type AppliedMigration struct {
+	bun.BaseModel `bun:"table:schema_migrations,alias:sm"`
+
+	Version   int64     `bun:",pk"`
+	Name      string    `bun:",notnull"`
+	Checksum  string    `bun:",notnull"`
+	AppliedAt time.Time `bun:",notnull,default:current_timestamp"`
+}
+
+// The up and down SQL found for a migration in one flavour of its files
+type migrationFiles struct {
+	up, down *string
+}
+
+func (f migrationFiles) found() bool {
+	return f.up != nil || f.down != nil
+}
+
+// Reads the migrations in fsys meant for the given database, ordered by
+// version. Every migration needs both an up and a down file.
+//
+// Files like 0001_initial.up.sql are shared by every database. Where the
+// SQL has to differ, 0001_initial.sqlite.up.sql and its down file take
+// their place on SQLite; a migration may also exist for one database only.
+func LoadMigrations(fsys fs.FS, db dialect.Name) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		name             string
+		shared, specific migrationFiles
+	}
+	byVersion := map[int64]*candidate{}
+
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		found, ok := byVersion[version]
+		if !ok {
+			found = &candidate{name: match[2]}
+			byVersion[version] = found
+		}
+		if found.name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %q and %q", version, found.name, match[2])
+		}
+
+		files := &found.shared
+		switch match[3] {
+		case "":
+		case dialectSuffixes[db]:
+			files = &found.specific
+		default:
+			// written for another database
+			continue
+		}
+
+		raw, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		contents := string(raw)
+		if match[4] == "up" {
+			files.up = &contents
+		} else {
+			files.down = &contents
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for version, found := range byVersion {
+		files := found.specific
+		if !files.found() {
+			files = found.shared
+		}
+		if !files.found() {
+			continue
+		}
+
+		migration := Migration{Version: version, Name: found.name}
+		if files.up == nil || files.down == nil {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+		migration.Up, migration.Down = *files.up, *files.down
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Returns the migrations shipped with this build for the given database
+func DefaultMigrations(db dialect.Name) ([]Migration, error) {
+	sub, err := fs.Sub(embeddedMigrations, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(sub, db)
+}
+
+// A single migration being applied, or reverted when Down is set
+type Step struct {
+	Migration Migration
+	Down      bool
+}
+
+// The SQL this step runs
+func (s Step) SQL() string {
+	if s.Down {
+		return s.Migration.Down
+	}
+	return s.Migration.Up
+}
+
+func (s Step) String() string {
+	if s.Down {
+		return fmt.Sprintf("revert %s", s.Migration)
+	}
+	return fmt.Sprintf("apply %s", s.Migration)
+}
+
+// Where a single migration stands against the database
+type MigrationStatus struct {
+	Migration Migration
+	// zero while the migration is still pending
+	AppliedAt time.Time
+	// the migration was edited after it was applied
+	Modified bool
+	// the database has the migration applied but we have no file for it
+	Unknown bool
+}
+
+func (s MigrationStatus) Pending() bool {
+	return s.AppliedAt.IsZero()
+}
+
+// Applies and reverts a set of migrations, keeping track of them in the
+// schema_migrations table. Every migration runs in its own transaction.
+type Migrator struct {
+	db         *bun.DB
+	migrations []Migration
+	dryRun     io.Writer
+}
+
+func NewMigrator(db *bun.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Returns a migrator that writes the SQL of every step to w instead of
+// running it, leaving the database untouched
+func (m *Migrator) DryRun(w io.Writer) *Migrator {
+	dry := *m
+	dry.dryRun = w
+	return &dry
+}
+
+// Applies every pending migration in order, returning the steps it took
+func (m *Migrator) Up(ctx context.Context) ([]Step, error) {
+	return m.migrate(ctx, func(done map[int64]AppliedMigration) ([]Step, error) {
+		var steps []Step
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; !ok {
+				steps = append(steps, Step{Migration: migration})
+			}
+		}
+		return steps, nil
+	})
+}
+
+// Reverts the last n applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, n int) ([]Step, error) {
+	return m.migrate(ctx, func(done map[int64]AppliedMigration) ([]Step, error) {
+		var steps []Step
+		for i := len(m.migrations) - 1; i >= 0 && len(steps) < n; i-- {
+			if _, ok := done[m.migrations[i].Version]; ok {
+				steps = append(steps, Step{Migration: m.migrations[i], Down: true})
+			}
+		}
+		return steps, nil
+	})
+}
+
+// Reverts the newest applied migration and applies it again, handy while
+// still working on that migration
+func (m *Migrator) Redo(ctx context.Context) ([]Step, error) {
+	return m.migrate(ctx, func(done map[int64]AppliedMigration) ([]Step, error) {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if _, ok := done[m.migrations[i].Version]; ok {
+				return []Step{
+					{Migration: m.migrations[i], Down: true},
+					{Migration: m.migrations[i]},
+				}, nil
+			}
+		}
+		return nil, errors.New("no migration has been applied yet")
+	})
+}
+
+// Moves the database to version, applying the pending migrations up to it
+// and reverting the applied ones after it. Version 0 reverts everything.
+func (m *Migrator) Goto(ctx context.Context, version int64) ([]Step, error) {
+	known := version == 0
+	for _, migration := range m.migrations {
+		known = known || migration.Version == version
+	}
+	if !known {
+		return nil, fmt.Errorf("no migration has version %d", version)
+	}
+
+	return m.migrate(ctx, func(done map[int64]AppliedMigration) ([]Step, error) {
+		var steps []Step
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; ok && migration.Version > version {
+				steps = append(steps, Step{Migration: migration, Down: true})
+			}
+		}
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; !ok && migration.Version <= version {
+				steps = append(steps, Step{Migration: migration})
+			}
+		}
+		return steps, nil
+	})
+}
+
+// Lists every migration we know of along with every one the database has
+// applied, ordered by version. Unlike the other operations it does not fail
+// on modified or unknown migrations, it reports them.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	done, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Migration: migration}
+		if applied, ok := done[migration.Version]; ok {
+			status.AppliedAt = applied.AppliedAt
+			status.Modified = applied.Checksum != migration.Checksum()
+			delete(done, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, applied := range done {
+		statuses = append(statuses, MigrationStatus{
+			Migration: Migration{Version: applied.Version, Name: applied.Name},
+			AppliedAt: applied.AppliedAt,
+			Unknown:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Migration.Version < statuses[j].Migration.Version
+	})
+	return statuses, nil
+}
+
+// Lists the migrations applied so far, keyed by version. A database we
+// never migrated has none.
+func (m *Migrator) Applied(ctx context.Context) (map[int64]AppliedMigration, error) {
+	exists, err := m.trackingTableExists(ctx, m.db)
+	if err != nil || !exists {
+		return map[int64]AppliedMigration{}, err
+	}
+	return m.applied(ctx, m.db)
+}
+
+// Works out the steps to take with plan, then takes them one by one while
+// holding our lock. The steps returned are the ones taken, which on error
+// stops short of the plan.
+func (m *Migrator) migrate(ctx context.Context, plan func(done map[int64]AppliedMigration) ([]Step, error)) ([]Step, error) {
+	var taken []Step
+
+	err := m.withLock(ctx, func(conn bun.Conn) error {
+		done, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		steps, err := plan(done)
+		if err != nil {
+			return err
+		}
+
+		for _, step := range steps {
+			if err := m.run(ctx, conn, step); err != nil {
+				return err
+			}
+			taken = append(taken, step)
+		}
+		return nil
+	})
+	return taken, err
+}
+
+func (m *Migrator) run(ctx context.Context, conn bun.Conn, step Step) error {
+	if m.dryRun != nil {
+		_, err := fmt.Fprintf(m.dryRun, "-- %s\n%s\n", step, strings.TrimSpace(step.SQL()))
+		return err
+	}
+	if step.Down {
+		return m.revert(ctx, conn, step.Migration)
+	}
+	return m.apply(ctx, conn, step.Migration)
+}
+
+func (m *Migrator) apply(ctx context.Context, conn bun.Conn, migration Migration) error {
+	err := conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// straight to database/sql, bun would take any ? in the file for a placeholder
+		if _, err := tx.Tx.ExecContext(ctx, migration.Up); err != nil {
+			return err
+		}
+
+		_, err := tx.NewInsert().Model(&AppliedMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  migration.Checksum(),
+			AppliedAt: time.Now(),
+		}).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("applying migration %s: %w", migration, err)
+	}
+
+	log.Printf("[Migrations] Applied %s", migration)
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, conn bun.Conn, migration Migration) error {
+	err := conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.Tx.ExecContext(ctx, migration.Down); err != nil {
+			return err
+		}
+
+		_, err := tx.NewDelete().Model((*AppliedMigration)(nil)).Where("version = ?", migration.Version).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("reverting migration %s: %w", migration, err)
+	}
+
+	log.Printf("[Migrations] Reverted %s", migration)
+	return nil
+}
+
+// Makes sure every applied migration still matches its file, returning
+// the applied ones
+func (m *Migrator) verify(ctx context.Context, conn bun.Conn) (map[int64]AppliedMigration, error) {
+	exists, err := m.trackingTableExists(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		// a dry run leaves even the tracking table alone
+		if m.dryRun != nil {
+			return map[int64]AppliedMigration{}, nil
+		}
+		if err := m.createTrackingTable(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	done, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = migration
+	}
+
+	for version, applied := range done {
+		migration, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: version %d (%s) has no migration file", ErrUnknownMigration, version, applied.Name)
+		}
+		if applied.Checksum != migration.Checksum() {
+			return nil, fmt.Errorf("%w: %s was changed after it was applied", ErrChecksumMismatch, migration)
+		}
+	}
+	return done, nil
+}
+
+func (m *Migrator) createTrackingTable(ctx context.Context, db bun.IDB) error {
+	_, err := db.NewCreateTable().Model((*AppliedMigration)(nil)).IfNotExists().Exec(ctx)
+	return err
+}
+
+func (m *Migrator) trackingTableExists(ctx context.Context, db bun.IDB) (bool, error) {
+	if m.db.Dialect().Name() == dialect.SQLite {
+		return db.NewSelect().
+			TableExpr("sqlite_master").
+			Where("type = 'table'").
+			Where("name = ?", "schema_migrations").
+			Exists(ctx)
+	}
+	return db.NewSelect().
+		TableExpr("information_schema.tables").
+		Where("table_schema = current_schema()").
+		Where("table_name = ?", "schema_migrations").
+		Exists(ctx)
+}
+
+func (m *Migrator) applied(ctx context.Context, db bun.IDB) (map[int64]AppliedMigration, error) {
+	var rows []AppliedMigration
+	if err := db.NewSelect().Model(&rows).Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	done := make(map[int64]AppliedMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+// Runs fn while holding our advisory lock. The lock belongs to a database
+// session, so everything in fn has to go through conn.
+//
+// SQLite has no advisory locks; it only ever lets a single writer in, and a
+// second instance applying the same migration fails on its version instead.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn bun.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.db.Dialect().Name() != dialect.PG {
+		return fn(conn)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(?)", advisoryLockID); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer func() {
+		// the lock is released with the session anyway, should this fail
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(?)", advisoryLockID); err != nil {
+			log.Printf("[Migrations] Failed to release migration lock: %v", err)
+		}
+	}()
+
+	return fn(conn)
+}
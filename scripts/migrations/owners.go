@@ -0,0 +1,98 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+)
+
+// Returned by CheckOwners when tasks are left without an owner
+var ErrUnownedTasks = errors.New("tasks without an owner")
+
+// Fails with ErrUnownedTasks when tasks from before we had users are still
+// without an owner, hidden from everyone, even though there is a user to
+// hand them to. Our migrations never guess who that should be, handing
+// them over is up to whoever runs the tracker, with `migrate assign-owner`.
+func CheckOwners(ctx context.Context, db bun.IDB) error {
+	anyUser, err := db.NewSelect().Model((*models.User)(nil)).Exists(ctx)
+	if err != nil || !anyUser {
+		return err
+	}
+
+	unowned, err := db.NewSelect().
+		Model((*models.Task)(nil)).
+		Where("owner_id IS NULL").
+		WhereAllWithDeleted().
+		Count(ctx)
+	if err != nil {
+		return err
+	}
+	if unowned > 0 {
+		return fmt.Errorf("%w: %d tasks belong to nobody and no user gets to see them, hand them over with `migrate assign-owner EMAIL`", ErrUnownedTasks, unowned)
+	}
+	return nil
+}
+
+// Hands every task without an owner, trashed ones included, to the user
+// signed up with email and returns how many there were. Their tags go
+// along, merged into the user's own tags of the same names, as do the
+// projects they are filed under.
+func AssignOwner(ctx context.Context, db bun.IDB, email string) (int, error) {
+	email, err := models.NormalizeEmail(email)
+	if err != nil {
+		return 0, err
+	}
+
+	var ownerID string
+	err = db.NewSelect().Model((*models.User)(nil)).Column("id").Where("email = ?", email).Scan(ctx, &ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("no user signed up with %s", email)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var assigned int64
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().
+			Model((*models.Task)(nil)).
+			Set("owner_id = ?", ownerID).
+			Where("owner_id IS NULL").
+			WhereAllWithDeleted().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if assigned, err = result.RowsAffected(); err != nil {
+			return err
+		}
+
+		// only unowned tasks carry unowned tags, and those have just been handed over
+		_, err = tx.NewRaw(`UPDATE task_tags SET tag_id = (
+				SELECT own.id FROM tags AS own JOIN tags AS loose ON loose.name = own.name
+				WHERE loose.id = task_tags.tag_id AND own.owner_id = ?
+			)
+			WHERE tag_id IN (SELECT id FROM tags WHERE owner_id IS NULL AND name IN (SELECT name FROM tags WHERE owner_id = ?))`,
+			ownerID, ownerID,
+		).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.NewRaw(
+			"DELETE FROM tags WHERE owner_id IS NULL AND name IN (SELECT name FROM tags WHERE owner_id = ?)", ownerID,
+		).Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.NewRaw("UPDATE tags SET owner_id = ? WHERE owner_id IS NULL", ownerID).Exec(ctx); err != nil {
+			return err
+		}
+		_, err = tx.NewRaw("UPDATE projects SET owner_id = ? WHERE owner_id IS NULL", ownerID).Exec(ctx)
+		return err
+	})
+	return int(assigned), err
+}
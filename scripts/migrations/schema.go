@@ -0,0 +1,222 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/50-Course/notes-tracker/shared/models"
+	"github.com/uptrace/bun"
+	bunschema "github.com/uptrace/bun/schema"
+)
+
+// A column as both our models and the live schema describe it
+type Column struct {
+	Name    string
+	Type    string
+	NotNull bool
+	Default string
+
+	// the column a foreign key points at, i.e. "users" ("id"). Only known
+	// for our models, as we do not compare foreign keys.
+	References string
+}
+
+// A table along with its columns, in declaration order
+type Table struct {
+	Name    string
+	Columns []Column
+
+	// the model the table comes from, nil for tables read from the database
+	model interface{}
+}
+
+func (t *Table) column(name string) (Column, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// A secondary, non-unique index. Unique and primary key indexes come with
+// their constraints and are left out.
+type Index struct {
+	Table   string
+	Name    string
+	Columns []string
+	// the index method when not a btree, i.e. GIN. Only known for the
+	// indexes we install, as we do not compare methods.
+	Using string
+}
+
+// The tables and indexes of a schema, keyed by name
+type Schema struct {
+	Tables  map[string]*Table
+	Indexes map[string]Index
+}
+
+// Postgres reports types by their internal names, i.e. int2 for SMALLINT,
+// so we compare every type in that form
+var sqlTypeAliases = map[string]string{
+	"varchar":                  "varchar",
+	"character varying":        "varchar",
+	"text":                     "text",
+	"uuid":                     "uuid",
+	"boolean":                  "bool",
+	"bool":                     "bool",
+	"smallint":                 "int2",
+	"integer":                  "int4",
+	"int":                      "int4",
+	"bigint":                   "int8",
+	"bigserial":                "int8",
+	"serial":                   "int4",
+	"real":                     "float4",
+	"double precision":         "float8",
+	"timestamptz":              "timestamptz",
+	"timestamp with time zone": "timestamptz",
+	"timestamp":                "timestamp",
+	"jsonb":                    "jsonb",
+	"json":                     "json",
+	"bytea":                    "bytea",
+	"tsvector":                 "tsvector",
+}
+
+// casts Postgres adds onto defaults when it stores them, i.e. 'todo'::character varying
+var defaultCastPattern = regexp.MustCompile(`::[a-z ]+(\[\])?$`)
+
+// the struct type behind one of our (*Model)(nil) entries
+func reflectType(model interface{}) reflect.Type {
+	return reflect.TypeOf(model).Elem()
+}
+
+func normalizeType(sqlType string) string {
+	sqlType = strings.ToLower(strings.TrimSpace(sqlType))
+	if alias, ok := sqlTypeAliases[sqlType]; ok {
+		return alias
+	}
+	return sqlType
+}
+
+func normalizeDefault(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	// serial columns draw their default from a sequence, which our models
+	// spell as the column's type instead
+	if strings.HasPrefix(value, "nextval(") {
+		return ""
+	}
+	return defaultCastPattern.ReplaceAllString(value, "")
+}
+
+// Describes the schema our models expect, from their bun tags
+func ModelSchema(db *bun.DB) *Schema {
+	schema := &Schema{Tables: map[string]*Table{}, Indexes: map[string]Index{}}
+
+	// join models have to be registered before bun can resolve m2m relations
+	db.RegisterModel((*models.TaskTag)(nil))
+
+	for _, model := range installedSchemas {
+		table := db.Table(reflectType(model))
+
+		described := &Table{Name: table.Name, model: model}
+		for _, field := range table.Fields {
+			described.Columns = append(described.Columns, Column{
+				Name:    field.Name,
+				Type:    normalizeType(field.CreateTableSQLType),
+				NotNull: field.NotNull || field.IsPK,
+				Default: normalizeDefault(field.SQLDefault),
+			})
+		}
+
+		for _, relation := range table.Relations {
+			if relation.Type != bunschema.BelongsToRelation || len(relation.BasePKs) != 1 {
+				continue
+			}
+			for i := range described.Columns {
+				if described.Columns[i].Name == relation.BasePKs[0].Name {
+					described.Columns[i].References = fmt.Sprintf("%q (%q)", relation.JoinTable.Name, relation.JoinPKs[0].Name)
+				}
+			}
+		}
+		schema.Tables[described.Name] = described
+	}
+
+	for _, index := range installedIndexes {
+		schema.Indexes[index.Name] = index
+	}
+	return schema
+}
+
+// Reads the tables and secondary indexes of the current schema from the
+// database
+func InspectSchema(ctx context.Context, db bun.IDB) (*Schema, error) {
+	schema := &Schema{Tables: map[string]*Table{}, Indexes: map[string]Index{}}
+
+	var columns []struct {
+		TableName     string
+		ColumnName    string
+		UdtName       string
+		IsNullable    string
+		ColumnDefault *string
+	}
+	// generated columns, i.e. tasks.search_vector, are computed by the
+	// database from the others and have no model field to compare with
+	err := db.NewRaw(`SELECT table_name, column_name, udt_name, is_nullable, column_default
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND is_generated = 'NEVER'
+		ORDER BY table_name, ordinal_position`).Scan(ctx, &columns)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting columns: %w", err)
+	}
+
+	for _, column := range columns {
+		table, ok := schema.Tables[column.TableName]
+		if !ok {
+			table = &Table{Name: column.TableName}
+			schema.Tables[column.TableName] = table
+		}
+
+		described := Column{
+			Name:    column.ColumnName,
+			Type:    normalizeType(column.UdtName),
+			NotNull: column.IsNullable == "NO",
+		}
+		if column.ColumnDefault != nil {
+			described.Default = normalizeDefault(*column.ColumnDefault)
+		}
+		table.Columns = append(table.Columns, described)
+	}
+
+	// information_schema knows nothing of indexes, so we go to the catalog
+	var indexes []struct {
+		TableName string
+		IndexName string
+		Columns   string
+	}
+	err = db.NewRaw(`SELECT tbl.relname AS table_name, idx.relname AS index_name,
+			array_to_string(ARRAY(
+				SELECT a.attname FROM unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			), ',') AS columns
+		FROM pg_index ix
+		JOIN pg_class idx ON idx.oid = ix.indexrelid
+		JOIN pg_class tbl ON tbl.oid = ix.indrelid
+		JOIN pg_namespace ns ON ns.oid = tbl.relnamespace
+		WHERE ns.nspname = current_schema() AND NOT ix.indisunique AND NOT ix.indisprimary`).Scan(ctx, &indexes)
+	if err != nil {
+		return nil, fmt.Errorf("inspecting indexes: %w", err)
+	}
+
+	for _, index := range indexes {
+		schema.Indexes[index.IndexName] = Index{
+			Table:   index.TableName,
+			Name:    index.IndexName,
+			Columns: strings.Split(index.Columns, ","),
+		}
+	}
+	return schema, nil
+}
@@ -0,0 +1,94 @@
+-- Our baseline schema.
+--
+-- Databases created before versioned migrations were set up by bun's
+-- CREATE TABLE IF NOT EXISTS, which never added columns to existing tables.
+-- Everything here is written to be a no-op on whatever those already have,
+-- so the baseline can be applied on top of them as well as on empty ones.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" uuid NOT NULL DEFAULT gen_random_uuid(),
+    "email" VARCHAR NOT NULL,
+    "password_hash" VARCHAR NOT NULL,
+    "created_at" TIMESTAMPTZ DEFAULT current_timestamp,
+    PRIMARY KEY ("id"),
+    UNIQUE ("email")
+);
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" uuid NOT NULL DEFAULT gen_random_uuid(),
+    "user_id" uuid NOT NULL,
+    "name" VARCHAR NOT NULL,
+    "prefix" VARCHAR NOT NULL,
+    "hash" VARCHAR NOT NULL,
+    "scopes" JSONB NOT NULL,
+    "created_at" TIMESTAMPTZ DEFAULT current_timestamp,
+    "last_used_at" TIMESTAMPTZ,
+    "revoked_at" TIMESTAMPTZ,
+    PRIMARY KEY ("id"),
+    UNIQUE ("hash"),
+    FOREIGN KEY ("user_id") REFERENCES "users" ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "api_keys_user_id_idx" ON "api_keys" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "projects" (
+    "id" uuid NOT NULL DEFAULT gen_random_uuid(),
+    "name" VARCHAR NOT NULL,
+    "description" VARCHAR,
+    "color" VARCHAR,
+    "archived" BOOLEAN NOT NULL DEFAULT false,
+    "created_at" TIMESTAMPTZ DEFAULT current_timestamp,
+    "updated_at" TIMESTAMPTZ,
+    "owner_id" uuid,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("owner_id") REFERENCES "users" ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "projects_owner_id_idx" ON "projects" ("owner_id");
+
+-- the tasks table as it was first released, the columns added since follow
+CREATE TABLE IF NOT EXISTS "tasks" (
+    "id" uuid NOT NULL DEFAULT gen_random_uuid(),
+    "title" VARCHAR NOT NULL,
+    "description" VARCHAR,
+    "created_at" TIMESTAMPTZ DEFAULT current_timestamp,
+    "updated_at" TIMESTAMPTZ,
+    PRIMARY KEY ("id")
+);
+
+ALTER TABLE "tasks"
+    ADD COLUMN IF NOT EXISTS "status" VARCHAR NOT NULL DEFAULT 'todo',
+    ADD COLUMN IF NOT EXISTS "priority" SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS "completed_at" TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS "start_at" TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS "due_at" TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS "parent_id" uuid REFERENCES "tasks" ("id"),
+    ADD COLUMN IF NOT EXISTS "owner_id" uuid REFERENCES "users" ("id"),
+    ADD COLUMN IF NOT EXISTS "project_id" uuid REFERENCES "projects" ("id");
+
+CREATE INDEX IF NOT EXISTS "tasks_parent_id_idx" ON "tasks" ("parent_id");
+CREATE INDEX IF NOT EXISTS "tasks_owner_id_idx" ON "tasks" ("owner_id");
+CREATE INDEX IF NOT EXISTS "tasks_project_id_idx" ON "tasks" ("project_id");
+
+CREATE TABLE IF NOT EXISTS "tags" (
+    "id" uuid NOT NULL DEFAULT gen_random_uuid(),
+    "owner_id" uuid,
+    "name" VARCHAR NOT NULL,
+    "created_at" TIMESTAMPTZ DEFAULT current_timestamp,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("owner_id") REFERENCES "users" ("id")
+);
+
+-- tag names are unique per owner, the tags of unowned tasks counting as one
+-- more owner, see repository.tagConflict
+CREATE UNIQUE INDEX IF NOT EXISTS "tags_owner_id_name_key" ON "tags" (coalesce("owner_id", '00000000-0000-0000-0000-000000000000'), "name");
+
+CREATE TABLE IF NOT EXISTS "task_tags" (
+    "task_id" uuid NOT NULL,
+    "tag_id" uuid NOT NULL,
+    PRIMARY KEY ("task_id", "tag_id"),
+    FOREIGN KEY ("task_id") REFERENCES "tasks" ("id"),
+    FOREIGN KEY ("tag_id") REFERENCES "tags" ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "task_tags_tag_id_idx" ON "task_tags" ("tag_id");
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "task_tags";
+DROP TABLE IF EXISTS "tags";
+DROP TABLE IF EXISTS "tasks";
+DROP TABLE IF EXISTS "projects";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "users";
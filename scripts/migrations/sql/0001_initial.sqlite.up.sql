@@ -0,0 +1,90 @@
+-- Our baseline schema on SQLite.
+--
+-- SQLite has no uuid, jsonb or timestamptz types, so ids are kept as text
+-- and timestamps as the UTC text bun writes, which sorts chronologically.
+-- Every id is generated by us rather than the database.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" TEXT NOT NULL,
+    "email" VARCHAR NOT NULL,
+    "password_hash" VARCHAR NOT NULL,
+    "created_at" TIMESTAMP DEFAULT current_timestamp,
+    PRIMARY KEY ("id"),
+    UNIQUE ("email")
+);
+
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" TEXT NOT NULL,
+    "user_id" TEXT NOT NULL,
+    "name" VARCHAR NOT NULL,
+    "prefix" VARCHAR NOT NULL,
+    "hash" VARCHAR NOT NULL,
+    "scopes" TEXT NOT NULL,
+    "created_at" TIMESTAMP DEFAULT current_timestamp,
+    "last_used_at" TIMESTAMP,
+    "revoked_at" TIMESTAMP,
+    PRIMARY KEY ("id"),
+    UNIQUE ("hash"),
+    FOREIGN KEY ("user_id") REFERENCES "users" ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "api_keys_user_id_idx" ON "api_keys" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "projects" (
+    "id" TEXT NOT NULL,
+    "name" VARCHAR NOT NULL,
+    "description" VARCHAR,
+    "color" VARCHAR,
+    "archived" BOOLEAN NOT NULL DEFAULT false,
+    "created_at" TIMESTAMP DEFAULT current_timestamp,
+    "updated_at" TIMESTAMP,
+    "owner_id" TEXT,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("owner_id") REFERENCES "users" ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "projects_owner_id_idx" ON "projects" ("owner_id");
+
+CREATE TABLE IF NOT EXISTS "tasks" (
+    "id" TEXT NOT NULL,
+    "title" VARCHAR NOT NULL,
+    "description" VARCHAR,
+    "status" VARCHAR NOT NULL DEFAULT 'todo',
+    "priority" INTEGER NOT NULL DEFAULT 0,
+    "created_at" TIMESTAMP DEFAULT current_timestamp,
+    "updated_at" TIMESTAMP,
+    "completed_at" TIMESTAMP,
+    "start_at" TIMESTAMP,
+    "due_at" TIMESTAMP,
+    "parent_id" TEXT REFERENCES "tasks" ("id"),
+    "owner_id" TEXT REFERENCES "users" ("id"),
+    "project_id" TEXT REFERENCES "projects" ("id"),
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "tasks_parent_id_idx" ON "tasks" ("parent_id");
+CREATE INDEX IF NOT EXISTS "tasks_owner_id_idx" ON "tasks" ("owner_id");
+CREATE INDEX IF NOT EXISTS "tasks_project_id_idx" ON "tasks" ("project_id");
+
+CREATE TABLE IF NOT EXISTS "tags" (
+    "id" TEXT NOT NULL,
+    "owner_id" TEXT,
+    "name" VARCHAR NOT NULL,
+    "created_at" TIMESTAMP DEFAULT current_timestamp,
+    PRIMARY KEY ("id"),
+    FOREIGN KEY ("owner_id") REFERENCES "users" ("id")
+);
+
+-- tag names are unique per owner, the tags of unowned tasks counting as one
+-- more owner, see repository.tagConflict
+CREATE UNIQUE INDEX IF NOT EXISTS "tags_owner_id_name_key" ON "tags" (coalesce("owner_id", '00000000-0000-0000-0000-000000000000'), "name");
+
+CREATE TABLE IF NOT EXISTS "task_tags" (
+    "task_id" TEXT NOT NULL,
+    "tag_id" TEXT NOT NULL,
+    PRIMARY KEY ("task_id", "tag_id"),
+    FOREIGN KEY ("task_id") REFERENCES "tasks" ("id"),
+    FOREIGN KEY ("tag_id") REFERENCES "tags" ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "task_tags_tag_id_idx" ON "task_tags" ("tag_id");
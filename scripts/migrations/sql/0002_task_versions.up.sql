@@ -0,0 +1,3 @@
+-- Every task starts out at version 1, which each update then bumps so
+-- concurrent writers cannot silently overwrite each other.
+ALTER TABLE "tasks" ADD COLUMN "version" BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "tasks_deleted_at_idx";
+
+-- whatever is still in the trash would otherwise come back to life
+DELETE FROM "task_tags" WHERE "task_id" IN (SELECT "id" FROM "tasks" WHERE "deleted_at" IS NOT NULL);
+DELETE FROM "tasks" WHERE "deleted_at" IS NOT NULL;
+
+ALTER TABLE "tasks" DROP COLUMN "deleted_at";
@@ -0,0 +1,5 @@
+-- Deleted tasks go to the trash rather than away for good: deleted_at is
+-- set while they sit there, until they are restored or purged.
+ALTER TABLE "tasks" ADD COLUMN "deleted_at" TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS "tasks_deleted_at_idx" ON "tasks" ("deleted_at");
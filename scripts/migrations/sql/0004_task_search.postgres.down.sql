@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "tasks_search_vector_idx";
+
+ALTER TABLE "tasks" DROP COLUMN "search_vector";
@@ -0,0 +1,8 @@
+-- Full-text search over tasks. Titles weigh more than descriptions, and
+-- Postgres keeps the vector in step with both on every write.
+ALTER TABLE "tasks" ADD COLUMN "search_vector" tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce("title", '')), 'A') ||
+    setweight(to_tsvector('english', coalesce("description", '')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS "tasks_search_vector_idx" ON "tasks" USING GIN ("search_vector");
@@ -0,0 +1,26 @@
+-- Full-text search over tasks. SQLite has no tsvector, so an FTS5 index
+-- over the tasks table stands in for it, kept in step by triggers.
+CREATE VIRTUAL TABLE IF NOT EXISTS "tasks_fts" USING fts5(
+    "title", "description",
+    content = 'tasks', content_rowid = 'rowid', tokenize = 'porter unicode61'
+);
+
+INSERT INTO "tasks_fts" ("rowid", "title", "description")
+    SELECT "rowid", "title", coalesce("description", '') FROM "tasks";
+
+CREATE TRIGGER IF NOT EXISTS "tasks_fts_insert" AFTER INSERT ON "tasks" BEGIN
+    INSERT INTO "tasks_fts" ("rowid", "title", "description")
+        VALUES (new."rowid", new."title", coalesce(new."description", ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS "tasks_fts_delete" AFTER DELETE ON "tasks" BEGIN
+    INSERT INTO "tasks_fts" ("tasks_fts", "rowid", "title", "description")
+        VALUES ('delete', old."rowid", old."title", coalesce(old."description", ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS "tasks_fts_update" AFTER UPDATE OF "title", "description" ON "tasks" BEGIN
+    INSERT INTO "tasks_fts" ("tasks_fts", "rowid", "title", "description")
+        VALUES ('delete', old."rowid", old."title", coalesce(old."description", ''));
+    INSERT INTO "tasks_fts" ("rowid", "title", "description")
+        VALUES (new."rowid", new."title", coalesce(new."description", ''));
+END;
@@ -0,0 +1,15 @@
+-- Filters our users saved under a name, see models.SavedView
+CREATE TABLE IF NOT EXISTS "saved_views" (
+    "id" uuid NOT NULL DEFAULT gen_random_uuid(),
+    "owner_id" uuid NOT NULL,
+    "name" VARCHAR NOT NULL,
+    "query" VARCHAR,
+    "order_by" VARCHAR,
+    "group_by" VARCHAR,
+    "time_zone" VARCHAR,
+    "created_at" TIMESTAMPTZ DEFAULT current_timestamp,
+    "updated_at" TIMESTAMPTZ,
+    PRIMARY KEY ("id"),
+    UNIQUE ("owner_id", "name"),
+    FOREIGN KEY ("owner_id") REFERENCES "users" ("id")
+);
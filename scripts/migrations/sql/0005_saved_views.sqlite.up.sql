@@ -0,0 +1,15 @@
+-- Filters our users saved under a name, see models.SavedView
+CREATE TABLE IF NOT EXISTS "saved_views" (
+    "id" TEXT NOT NULL,
+    "owner_id" TEXT NOT NULL,
+    "name" VARCHAR NOT NULL,
+    "query" VARCHAR,
+    "order_by" VARCHAR,
+    "group_by" VARCHAR,
+    "time_zone" VARCHAR,
+    "created_at" TIMESTAMP DEFAULT current_timestamp,
+    "updated_at" TIMESTAMP,
+    PRIMARY KEY ("id"),
+    UNIQUE ("owner_id", "name"),
+    FOREIGN KEY ("owner_id") REFERENCES "users" ("id")
+);
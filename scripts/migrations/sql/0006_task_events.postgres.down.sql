@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS "tags_refresh_events" ON "tags";
+DROP TRIGGER IF EXISTS "task_tags_refresh_events" ON "task_tags";
+DROP TRIGGER IF EXISTS "tasks_record_event" ON "tasks";
+
+DROP FUNCTION IF EXISTS "refresh_task_events"();
+DROP FUNCTION IF EXISTS "refresh_task_event"(UUID);
+DROP FUNCTION IF EXISTS "record_task_event"();
+DROP FUNCTION IF EXISTS "task_snapshot"("tasks");
+
+DROP TABLE IF EXISTS "task_events";
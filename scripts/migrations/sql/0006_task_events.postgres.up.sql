@@ -0,0 +1,107 @@
+-- Every change to a task is recorded in task_events, where WatchTasks
+-- picks it up. The trigger also notifies the task_events channel with the
+-- event's id, so watchers on every core instance hear of it straight away.
+--
+-- Events note down the task as it was right before and right after the
+-- change, so watchers filter on what the change did rather than on what
+-- became of the task since.
+--
+-- Ids are handed out as events are written rather than as they commit, so
+-- each event also records the id of the transaction that wrote it, which
+-- watchers read events in the order of. See TaskRepository.readEvents.
+CREATE TABLE IF NOT EXISTS "task_events" (
+    "id" BIGSERIAL NOT NULL,
+    "txid" BIGINT NOT NULL,
+    "task_id" UUID NOT NULL,
+    "owner_id" UUID,
+    "type" VARCHAR NOT NULL,
+    "task" JSONB,
+    "previous" JSONB,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT current_timestamp,
+    PRIMARY KEY ("id")
+);
+
+CREATE INDEX IF NOT EXISTS "task_events_created_at_idx" ON "task_events" ("created_at");
+CREATE INDEX IF NOT EXISTS "task_events_txid_id_idx" ON "task_events" ("txid", "id");
+
+-- a task's row the way events note it down, along with its tag names
+CREATE OR REPLACE FUNCTION "task_snapshot"(task "tasks") RETURNS JSONB AS $$
+    SELECT (to_jsonb(task) - 'search_vector') || jsonb_build_object('tags', coalesce((
+        SELECT jsonb_agg(tg."name")
+        FROM "task_tags" AS tt JOIN "tags" AS tg ON tg."id" = tt."tag_id"
+        WHERE tt."task_id" = task."id"
+    ), '[]'::jsonb));
+$$ LANGUAGE sql STABLE;
+
+-- trashing a task is a delete to its watchers and restoring it a create;
+-- tasks purged from the trash were already deleted as far as they know
+CREATE OR REPLACE FUNCTION "record_task_event"() RETURNS trigger AS $$
+DECLARE
+    task RECORD;
+    event_type VARCHAR;
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        task := OLD;
+    ELSE
+        task := NEW;
+    END IF;
+
+    event_type := CASE
+        WHEN TG_OP = 'INSERT' THEN 'created'
+        WHEN TG_OP = 'DELETE' THEN CASE WHEN OLD."deleted_at" IS NULL THEN 'deleted' END
+        WHEN NEW."deleted_at" IS NULL THEN CASE WHEN OLD."deleted_at" IS NULL THEN 'updated' ELSE 'created' END
+        WHEN OLD."deleted_at" IS NULL THEN 'deleted'
+    END;
+    IF event_type IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    -- a task's tags are written after it, see refresh_task_event
+    INSERT INTO "task_events" ("txid", "task_id", "owner_id", "type", "task", "previous")
+        VALUES (
+            pg_current_xact_id()::text::bigint, task."id", task."owner_id", event_type,
+            CASE WHEN TG_OP <> 'DELETE' THEN "task_snapshot"(NEW) END,
+            CASE WHEN TG_OP <> 'INSERT' THEN "task_snapshot"(OLD) END
+        )
+        RETURNING "id" INTO event_id;
+    PERFORM pg_notify('task_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "tasks_record_event" AFTER INSERT OR UPDATE OR DELETE ON "tasks"
+    FOR EACH ROW EXECUTE FUNCTION "record_task_event"();
+
+-- Tags are written once the task carrying them is, so whenever they change
+-- they bring the task's latest event of the same transaction up to date.
+-- Events of tasks going away keep the tags they had.
+CREATE OR REPLACE FUNCTION "refresh_task_event"(changed_task UUID) RETURNS void AS $$
+    UPDATE "task_events" SET "task" = "task_snapshot"(t)
+        FROM "tasks" AS t
+        WHERE t."id" = changed_task
+            AND "task_events"."type" <> 'deleted'
+            AND "task_events"."id" = (
+                SELECT max(e."id") FROM "task_events" AS e
+                WHERE e."task_id" = changed_task AND e."txid" = pg_current_xact_id()::text::bigint
+            );
+$$ LANGUAGE sql;
+
+CREATE OR REPLACE FUNCTION "refresh_task_events"() RETURNS trigger AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'tags' THEN
+        PERFORM "refresh_task_event"(tt."task_id") FROM "task_tags" AS tt WHERE tt."tag_id" = NEW."id";
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM "refresh_task_event"(OLD."task_id");
+    ELSE
+        PERFORM "refresh_task_event"(NEW."task_id");
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "task_tags_refresh_events" AFTER INSERT OR DELETE ON "task_tags"
+    FOR EACH ROW EXECUTE FUNCTION "refresh_task_events"();
+
+CREATE TRIGGER "tags_refresh_events" AFTER UPDATE OF "name" ON "tags"
+    FOR EACH ROW EXECUTE FUNCTION "refresh_task_events"();
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS "tags_event_rename";
+DROP TRIGGER IF EXISTS "task_tags_event_delete";
+DROP TRIGGER IF EXISTS "task_tags_event_insert";
+DROP TRIGGER IF EXISTS "tasks_event_delete";
+DROP TRIGGER IF EXISTS "tasks_event_update";
+DROP TRIGGER IF EXISTS "tasks_event_insert";
+
+DROP VIEW IF EXISTS "task_snapshots";
+
+DROP TABLE IF EXISTS "task_events";
@@ -0,0 +1,113 @@
+-- Every change to a task is recorded in task_events, where WatchTasks
+-- picks it up. SQLite has no LISTEN/NOTIFY, so watchers poll the table.
+--
+-- Writes to SQLite take turns, so ids already follow commit order and
+-- every event is left in transaction 0.
+--
+-- Events note down the task as it was right before and right after the
+-- change, so watchers filter on what the change did rather than on what
+-- became of the task since.
+CREATE TABLE IF NOT EXISTS "task_events" (
+    "id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "txid" INTEGER NOT NULL DEFAULT 0,
+    "task_id" TEXT NOT NULL,
+    "owner_id" TEXT,
+    "type" VARCHAR NOT NULL,
+    "task" TEXT,
+    "previous" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS "task_events_created_at_idx" ON "task_events" ("created_at");
+CREATE INDEX IF NOT EXISTS "task_events_txid_id_idx" ON "task_events" ("txid", "id");
+
+-- every task's row the way events note it down, along with its tag names.
+-- Triggers spell the same out for old rows, which are gone from here.
+CREATE VIEW IF NOT EXISTS "task_snapshots" AS SELECT t."id", json_object(
+    'id', t."id", 'title', t."title", 'description', t."description",
+    'status', t."status", 'priority', t."priority", 'version', t."version",
+    'created_at', t."created_at", 'updated_at', t."updated_at", 'completed_at', t."completed_at",
+    'start_at', t."start_at", 'due_at', t."due_at", 'deleted_at', t."deleted_at",
+    'parent_id', t."parent_id", 'owner_id', t."owner_id", 'project_id', t."project_id",
+    'tags', json((
+        SELECT json_group_array(tg."name")
+        FROM "task_tags" AS tt JOIN "tags" AS tg ON tg."id" = tt."tag_id"
+        WHERE tt."task_id" = t."id"
+    ))
+) AS "task" FROM "tasks" AS t;
+
+-- trashing a task is a delete to its watchers and restoring it a create;
+-- tasks purged from the trash were already deleted as far as they know
+CREATE TRIGGER IF NOT EXISTS "tasks_event_insert" AFTER INSERT ON "tasks" BEGIN
+    INSERT INTO "task_events" ("task_id", "owner_id", "type", "task")
+        SELECT new."id", new."owner_id", 'created', s."task"
+        FROM "task_snapshots" AS s WHERE s."id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "tasks_event_update" AFTER UPDATE ON "tasks"
+    WHEN old."deleted_at" IS NULL OR new."deleted_at" IS NULL
+BEGIN
+    INSERT INTO "task_events" ("task_id", "owner_id", "type", "task", "previous")
+        SELECT new."id", new."owner_id", CASE
+            WHEN new."deleted_at" IS NOT NULL THEN 'deleted'
+            WHEN old."deleted_at" IS NOT NULL THEN 'created'
+            ELSE 'updated'
+        END, s."task", json_object(
+            'id', old."id", 'title', old."title", 'description', old."description",
+            'status', old."status", 'priority', old."priority", 'version', old."version",
+            'created_at', old."created_at", 'updated_at', old."updated_at", 'completed_at', old."completed_at",
+            'start_at', old."start_at", 'due_at', old."due_at", 'deleted_at', old."deleted_at",
+            'parent_id', old."parent_id", 'owner_id', old."owner_id", 'project_id', old."project_id",
+            -- the task's tags are only written once it is
+            'tags', json((
+                SELECT json_group_array(tg."name")
+                FROM "task_tags" AS tt JOIN "tags" AS tg ON tg."id" = tt."tag_id"
+                WHERE tt."task_id" = old."id"
+            ))
+        )
+        FROM "task_snapshots" AS s WHERE s."id" = new."id";
+END;
+
+CREATE TRIGGER IF NOT EXISTS "tasks_event_delete" AFTER DELETE ON "tasks"
+    WHEN old."deleted_at" IS NULL
+BEGIN
+    INSERT INTO "task_events" ("task_id", "owner_id", "type", "previous")
+        VALUES (old."id", old."owner_id", 'deleted', json_object(
+            'id', old."id", 'title', old."title", 'description', old."description",
+            'status', old."status", 'priority', old."priority", 'version', old."version",
+            'created_at', old."created_at", 'updated_at', old."updated_at", 'completed_at', old."completed_at",
+            'start_at', old."start_at", 'due_at', old."due_at", 'deleted_at', old."deleted_at",
+            'parent_id', old."parent_id", 'owner_id', old."owner_id", 'project_id', old."project_id",
+            'tags', json((
+                SELECT json_group_array(tg."name")
+                FROM "task_tags" AS tt JOIN "tags" AS tg ON tg."id" = tt."tag_id"
+                WHERE tt."task_id" = old."id"
+            ))
+        ));
+END;
+
+-- Tags are written once the task carrying them is, so whenever they change
+-- they bring the task's latest event up to date, which is the one written
+-- along with them. Events of tasks going away keep the tags they had.
+CREATE TRIGGER IF NOT EXISTS "task_tags_event_insert" AFTER INSERT ON "task_tags" BEGIN
+    UPDATE "task_events" SET "task" = (SELECT s."task" FROM "task_snapshots" AS s WHERE s."id" = new."task_id")
+        WHERE "type" <> 'deleted'
+            AND "id" = (SELECT max(e."id") FROM "task_events" AS e WHERE e."task_id" = new."task_id");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "task_tags_event_delete" AFTER DELETE ON "task_tags" BEGIN
+    UPDATE "task_events" SET "task" = (SELECT s."task" FROM "task_snapshots" AS s WHERE s."id" = old."task_id")
+        WHERE "type" <> 'deleted'
+            AND "id" = (SELECT max(e."id") FROM "task_events" AS e WHERE e."task_id" = old."task_id");
+END;
+
+CREATE TRIGGER IF NOT EXISTS "tags_event_rename" AFTER UPDATE OF "name" ON "tags" BEGIN
+    UPDATE "task_events" SET "task" = (SELECT s."task" FROM "task_snapshots" AS s WHERE s."id" = "task_events"."task_id")
+        WHERE "type" <> 'deleted'
+            AND "id" IN (
+                SELECT max(e."id") FROM "task_events" AS e
+                JOIN "task_tags" AS tt ON tt."task_id" = e."task_id"
+                WHERE tt."tag_id" = new."id"
+                GROUP BY e."task_id"
+            );
+END;
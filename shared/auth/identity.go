@@ -0,0 +1,26 @@
+// Package auth carries the identity of the user behind a request from our
+// gateway through to the internal service.
+package auth
+
+import "context"
+
+// the gRPC metadata key our gateway forwards the authenticated user's id under
+const UserIDMetadataKey = "x-user-id"
+
+// the gRPC metadata key our gateway sends the token it shares with the
+// internal service under, on every call, so the service can tell it apart
+// from anyone else who reaches it
+const GatewayTokenMetadataKey = "x-gateway-token"
+
+type userIDKey struct{}
+
+// Returns a copy of ctx carrying the id of the user making the request
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// Returns the id of the user making the request, if there is one
+func UserID(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(string)
+	return userID, ok && userID != ""
+}
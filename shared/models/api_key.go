@@ -0,0 +1,134 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Scopes an API key can be granted
+const (
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+)
+
+// every scope we know of, which is also what a logged in user is granted
+var AllScopes = []string{ScopeTasksRead, ScopeTasksWrite}
+
+// every key we hand out starts with this, so leaked keys are easy to grep for
+const apiKeyPrefix = "nt_"
+
+// how much of the key we keep around in the clear to tell keys apart
+const apiKeyDisplayLength = len(apiKeyPrefix) + 8
+
+// Returned when an API key is requested without a name or with unknown scopes
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// Represents a long-lived credential our scripts and bots authenticate with.
+// Only a SHA-256 hash of the key itself is ever stored.
+type APIKey struct {
+	bun.BaseModel `bun:"table:api_keys,alias:k" swaggerignore:"true"`
+
+	ID     string   `bun:",pk,type:uuid,default:gen_random_uuid()"`
+	UserID string   `bun:",notnull,type:uuid"`
+	User   *User    `bun:"rel:belongs-to,join:user_id=id" swaggerignore:"true"`
+	Name   string   `bun:",notnull"`
+	Prefix string   `bun:",notnull"`
+	Hash   string   `bun:",notnull,unique"`
+	Scopes []string `bun:",notnull"`
+
+	CreatedAt  time.Time    `bun:",default:current_timestamp"`
+	LastUsedAt bun.NullTime `swaggertype:"string" format:"date-time"`
+	RevokedAt  bun.NullTime `swaggertype:"string" format:"date-time"`
+}
+
+// Generates a new random key, returning the key along with its model.
+// The key is only ever shown to the user once, right after creation.
+func NewAPIKey(name string, scopes []string) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidAPIKey)
+	}
+
+	scopes, err := NormalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	return &APIKey{
+		Name:   name,
+		Prefix: secret[:apiKeyDisplayLength],
+		Hash:   HashAPIKey(secret),
+		Scopes: scopes,
+	}, secret, nil
+}
+
+// Hashes a key the way it is stored. Our keys carry 256 bits of entropy,
+// so a plain SHA-256 is enough; there is nothing to brute force.
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Reports whether the key was revoked
+func (k *APIKey) IsRevoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// Checks every scope is one we know of, returning them deduplicated and sorted
+func NormalizeScopes(scopes []string) ([]string, error) {
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !slices.Contains(AllScopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q, expected one of %q", ErrInvalidAPIKey, scope, AllScopes)
+		}
+		if !slices.Contains(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKey)
+	}
+
+	slices.Sort(normalized)
+	return normalized, nil
+}
+
+// Defines the request payload for creating an API key.
+type APIKeyRequest struct {
+	Name   string   `json:"name" example:"ci-bot"`
+	Scopes []string `json:"scopes" example:"tasks:read,tasks:write" enums:"tasks:read,tasks:write"`
+}
+
+// Defines the response payload for returning an API key, without the key itself.
+type APIKeyResponse struct {
+	ID         string   `json:"id" example:"9d2c4f1a-7e3b-4a6c-8f5d-2b1e0c9a8d7f"`
+	Name       string   `json:"name" example:"ci-bot"`
+	Prefix     string   `json:"prefix" example:"nt_Xk3v9QaB"`
+	Scopes     []string `json:"scopes" example:"tasks:read"`
+	CreatedAt  string   `json:"created_at" example:"2025-03-19T08:58:10Z"`
+	LastUsedAt string   `json:"last_used_at,omitempty" example:"2025-03-20T10:02:44Z"`
+	RevokedAt  string   `json:"revoked_at,omitempty" example:"2025-03-21T17:30:00Z"`
+}
+
+// Defines the response payload right after creating an API key, the
+// only time the key itself is returned.
+type CreatedAPIKeyResponse struct {
+	Key    string         `json:"key" example:"nt_Xk3v9QaBz7..."`
+	APIKey APIKeyResponse `json:"api_key"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import "fmt"
+
+// Represents how urgent a task is. Higher values are more urgent, which
+// lets us order tasks by priority straight from the database.
+type TaskPriority int16
+
+const (
+	PriorityNone TaskPriority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = map[TaskPriority]string{
+	PriorityNone:   "none",
+	PriorityLow:    "low",
+	PriorityMedium: "medium",
+	PriorityHigh:   "high",
+	PriorityUrgent: "urgent",
+}
+
+// formats to pretty representation, i.e. "high"
+func (p TaskPriority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("TaskPriority(%d)", int16(p))
+}
+
+// Reports whether the priority is one of our known levels
+func (p TaskPriority) IsValid() bool {
+	_, ok := priorityNames[p]
+	return ok
+}
+
+// Parses a priority by name, i.e. "high"
+func ParsePriority(name string) (TaskPriority, error) {
+	for priority, candidate := range priorityNames {
+		if candidate == name {
+			return priority, nil
+		}
+	}
+	return PriorityNone, fmt.Errorf("unknown priority %q, expected one of none, low, medium, high or urgent", name)
+}
@@ -0,0 +1,15 @@
+package models
+
+// Defines the error payload returned by our HTTP API, following the
+// problem details format of RFC 9457 (application/problem+json).
+type Problem struct {
+	Type     string `json:"type" example:"about:blank"`
+	Title    string `json:"title" example:"Task not found"`
+	Status   int    `json:"status" example:"404"`
+	Detail   string `json:"detail,omitempty" example:"task 123e4567-e89b-12d3-a456-426614174000 does not exist"`
+	Instance string `json:"instance,omitempty" example:"/api/v1/tasks/123e4567-e89b-12d3-a456-426614174000"`
+	// the gRPC status code reported by the internal service, if any
+	Code string `json:"code,omitempty" example:"NOT_FOUND"`
+	// the current entity tag of the resource, when an If-Match on it failed
+	ETag string `json:"etag,omitempty" example:"\"4\""`
+}
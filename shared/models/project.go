@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Returned when a project is missing its name or carries a malformed color
+var ErrInvalidProject = errors.New("invalid project")
+
+// colors are stored as hex triplets, i.e. "#3b82f6"
+var projectColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Represents a list grouping related tasks together, i.e. one per team
+//
+// @Description: Project Model
+type Project struct {
+	bun.BaseModel `bun:"table:projects,alias:p" swaggerignore:"true"`
+
+	ID          string `bun:",pk,type:uuid,default:gen_random_uuid()"`
+	Name        string `bun:",notnull"`
+	Description string
+	Color       string
+	Archived    bool         `bun:",notnull,default:false"`
+	CreatedAt   time.Time    `bun:",default:current_timestamp"`
+	UpdatedAt   bun.NullTime `swaggertype:"string" format:"date-time"`
+
+	// the user the project belongs to, only they get to see it and file
+	// tasks under it
+	OwnerID string `bun:",type:uuid,nullzero"`
+	Owner   *User  `bun:"rel:belongs-to,join:owner_id=id" swaggerignore:"true"`
+}
+
+// formats to pretty representation
+func (p *Project) String() string {
+	return p.Name
+}
+
+// Trims the project's fields and checks they are fit to be stored
+func (p *Project) Normalize() error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Color = strings.ToLower(strings.TrimSpace(p.Color))
+
+	if p.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProject)
+	}
+	if p.Color != "" && !projectColorPattern.MatchString(p.Color) {
+		return fmt.Errorf("%w: color %q must be a hex color such as #3b82f6", ErrInvalidProject, p.Color)
+	}
+	return nil
+}
+
+// Defines the request payload for creating or updating a project.
+type ProjectRequest struct {
+	Name        string `json:"name" example:"Platform team"`
+	Description string `json:"description" example:"Everything the platform team is working on"`
+	Color       string `json:"color,omitempty" example:"#3b82f6"`
+	Archived    bool   `json:"archived" example:"false"`
+}
+
+// Defines the response payload for returning a project.
+type ProjectResponse struct {
+	ID          string `json:"id" example:"5f0c2a9e-1b7d-4e36-8a51-0d9c3e7b4f12"`
+	Name        string `json:"name" example:"Platform team"`
+	Description string `json:"description" example:"Everything the platform team is working on"`
+	Color       string `json:"color,omitempty" example:"#3b82f6"`
+	Archived    bool   `json:"archived" example:"false"`
+	CreatedAt   string `json:"created_at" example:"2025-03-19T08:58:10.605Z"`
+	UpdatedAt   string `json:"updated_at" example:"2025-03-19T08:58:10.605Z"`
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun"
+)
+
+const MaxViewNameLength = 100
+
+// What a view can count its tasks by. Tasks carrying several tags count
+// towards every one of them.
+const (
+	GroupByStatus   = "status"
+	GroupByPriority = "priority"
+	GroupByProject  = "project"
+	GroupByTag      = "tag"
+)
+
+var viewGroupings = []string{GroupByStatus, GroupByPriority, GroupByProject, GroupByTag}
+
+// Returned when a view is missing its name or groups by something we do not know
+var ErrInvalidView = errors.New("invalid view")
+
+// Represents a filter a user saved under a name to list their tasks by
+// again later, i.e. "my open backend bugs"
+type SavedView struct {
+	bun.BaseModel `bun:"table:saved_views,alias:v" swaggerignore:"true"`
+
+	ID string `bun:",pk,type:uuid,default:gen_random_uuid()"`
+	// the user the view belongs to; names are unique per user
+	OwnerID string `bun:",notnull,type:uuid,unique:owner_name"`
+	Owner   *User  `bun:"rel:belongs-to,join:owner_id=id" swaggerignore:"true"`
+	Name    string `bun:",notnull,unique:owner_name"`
+
+	// the filter query, i.e. status:open tag:backend, empty for every task
+	Query string
+	// the order_by of ListTasks, empty for oldest first
+	OrderBy string
+	// one of the GroupBy constants, empty for no grouping
+	GroupBy string
+	// the IANA time zone days in Query are taken in, empty for UTC
+	TimeZone string
+
+	CreatedAt time.Time    `bun:",default:current_timestamp"`
+	UpdatedAt bun.NullTime `swaggertype:"string" format:"date-time"`
+}
+
+// formats to pretty representation
+func (v *SavedView) String() string {
+	return v.Name
+}
+
+// Trims the view's fields and checks the ones we can check on our own.
+// The query, order and time zone are left to whoever runs the view.
+func (v *SavedView) Normalize() error {
+	v.Name = strings.TrimSpace(v.Name)
+	v.Query = strings.TrimSpace(v.Query)
+	v.OrderBy = strings.TrimSpace(v.OrderBy)
+	v.GroupBy = strings.ToLower(strings.TrimSpace(v.GroupBy))
+	v.TimeZone = strings.TrimSpace(v.TimeZone)
+
+	if v.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidView)
+	}
+	if utf8.RuneCountInString(v.Name) > MaxViewNameLength {
+		return fmt.Errorf("%w: name is longer than %d characters", ErrInvalidView, MaxViewNameLength)
+	}
+	if v.GroupBy != "" && !slices.Contains(viewGroupings, v.GroupBy) {
+		return fmt.Errorf("%w: cannot group by %q, expected one of status, priority, project or tag", ErrInvalidView, v.GroupBy)
+	}
+	return nil
+}
+
+// Defines the request payload for creating or updating a saved view.
+type SavedViewRequest struct {
+	Name     string `json:"name" example:"My open backend bugs"`
+	Query    string `json:"query" example:"status:open tag:backend tag:bug"`
+	OrderBy  string `json:"order_by,omitempty" example:"priority desc, due_at"`
+	GroupBy  string `json:"group_by,omitempty" example:"priority" enums:"status,priority,project,tag"`
+	TimeZone string `json:"time_zone,omitempty" example:"Europe/Berlin"`
+}
+
+// Defines the response payload for returning a saved view.
+type SavedViewResponse struct {
+	ID        string `json:"id" example:"0b7e3c1d-5a2f-4e8b-9c6d-1f2a3b4c5d6e"`
+	Name      string `json:"name" example:"My open backend bugs"`
+	Query     string `json:"query" example:"status:open tag:backend tag:bug"`
+	OrderBy   string `json:"order_by,omitempty" example:"priority desc, due_at"`
+	GroupBy   string `json:"group_by,omitempty" example:"priority"`
+	TimeZone  string `json:"time_zone,omitempty" example:"Europe/Berlin"`
+	CreatedAt string `json:"created_at" example:"2025-03-19T08:58:10Z"`
+	UpdatedAt string `json:"updated_at,omitempty" example:"2025-03-20T10:02:44Z"`
+}
+
+// Defines the number of a view's tasks falling into one of its groups
+type TaskGroupResponse struct {
+	// the status, priority, project id or tag name, empty for tasks
+	// without a project or tags
+	Key   string `json:"key" example:"high"`
+	Count int    `json:"count" example:"4"`
+}
+
+// Defines the response payload for running a saved view: a page of its
+// tasks along with how many there are in total and in each group.
+type SavedViewTasksResponse struct {
+	View          SavedViewResponse   `json:"view"`
+	Tasks         []TaskResponse      `json:"tasks"`
+	TotalCount    int                 `json:"total_count" example:"12"`
+	Groups        []TaskGroupResponse `json:"groups,omitempty"`
+	NextPageToken string              `json:"next_page_token,omitempty" example:"eyJzIjoiY3JlYXRlZF9hdCJ9"`
+	Next          string              `json:"next,omitempty" example:"/api/v1/views/0b7e3c1d-5a2f-4e8b-9c6d-1f2a3b4c5d6e/tasks?page_token=eyJzIjoiY3JlYXRlZF9hdCJ9"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Represents where a task currently sits within its lifecycle
+type TaskStatus string
+
+const (
+	StatusTodo       TaskStatus = "todo"
+	StatusInProgress TaskStatus = "in_progress"
+	StatusBlocked    TaskStatus = "blocked"
+	StatusDone       TaskStatus = "done"
+	StatusCancelled  TaskStatus = "cancelled"
+)
+
+// Returned whenever a task is asked to move into a status it cannot reach
+// from where it currently is
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// our lifecycle graph; a status maps to every status it may move into.
+// moving back out of done or cancelled is what we call "reopening"
+var statusTransitions = map[TaskStatus][]TaskStatus{
+	StatusTodo:       {StatusInProgress, StatusBlocked, StatusDone, StatusCancelled},
+	StatusInProgress: {StatusTodo, StatusBlocked, StatusDone, StatusCancelled},
+	StatusBlocked:    {StatusTodo, StatusInProgress, StatusCancelled},
+	StatusDone:       {StatusTodo, StatusInProgress},
+	StatusCancelled:  {StatusTodo},
+}
+
+// Reports whether the status is one of our known lifecycle states
+func (s TaskStatus) IsValid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// Reports whether the task is finished with, either done or cancelled
+func (s TaskStatus) IsClosed() bool {
+	return s == StatusDone || s == StatusCancelled
+}
+
+// Reports whether a task in this status is allowed to move into next.
+// Staying in the same status is always allowed.
+func (s TaskStatus) CanTransitionTo(next TaskStatus) bool {
+	if s == next {
+		return s.IsValid()
+	}
+
+	for _, candidate := range statusTransitions[s] {
+		if candidate == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Validates that the transition is allowed, then wraps it with a
+// descriptive error that callers can still match with errors.Is
+func (s TaskStatus) ValidateTransition(next TaskStatus) error {
+	if !next.IsValid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidTransition, next)
+	}
+	if !s.CanTransitionTo(next) {
+		return fmt.Errorf("%w: cannot move task from %q to %q", ErrInvalidTransition, s, next)
+	}
+	return nil
+}
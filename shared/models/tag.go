@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun"
+)
+
+const MaxTagLength = 64
+
+// Returned when a tag name is empty or too long
+var ErrInvalidTag = errors.New("invalid tag")
+
+// Represents a label we can attach to any number of tasks,
+// i.e. "backend", "urgent" or "customer-x"
+type Tag struct {
+	bun.BaseModel `bun:"table:tags,alias:tg" swaggerignore:"true"`
+
+	ID string `bun:",pk,type:uuid,default:gen_random_uuid()"`
+
+	// the user the tag belongs to, along with the tasks carrying it; names
+	// are unique per user
+	OwnerID string `bun:",type:uuid,nullzero"`
+	Owner   *User  `bun:"rel:belongs-to,join:owner_id=id" swaggerignore:"true"`
+
+	Name      string    `bun:",notnull"`
+	CreatedAt time.Time `bun:",default:current_timestamp"`
+
+	// number of tasks carrying the tag, only filled in when listing tags
+	TaskCount int `bun:",scanonly"`
+}
+
+// Join table backing the many-to-many relation between tasks and tags
+type TaskTag struct {
+	bun.BaseModel `bun:"table:task_tags,alias:tt" swaggerignore:"true"`
+
+	TaskID string `bun:",pk,type:uuid"`
+	Task   *Task  `bun:"rel:belongs-to,join:task_id=id"`
+	TagID  string `bun:",pk,type:uuid"`
+	Tag    *Tag   `bun:"rel:belongs-to,join:tag_id=id"`
+}
+
+// Normalizes a tag name so "Backend " and "backend" are the same tag
+func NormalizeTag(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if name == "" {
+		return "", fmt.Errorf("%w: tag names must not be empty", ErrInvalidTag)
+	}
+	if utf8.RuneCountInString(name) > MaxTagLength {
+		return "", fmt.Errorf("%w: tag %q is longer than %d characters", ErrInvalidTag, name, MaxTagLength)
+	}
+	return name, nil
+}
+
+// Normalizes a set of tag names, dropping duplicates while keeping
+// the order they were given in
+func NormalizeTags(names []string) ([]string, error) {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		tag, err := NormalizeTag(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// Returns the names of the tags, in the order they were loaded
+func TagNames(tags []*Tag) []string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+// Defines the response payload for returning a tag.
+type TagResponse struct {
+	ID        string `json:"id" example:"8c1d6f7e-5a0b-4c1e-9d8e-2f3a4b5c6d7e"`
+	Name      string `json:"name" example:"backend"`
+	TaskCount int    `json:"task_count" example:"12"`
+}
+
+// Defines the request payload for renaming a tag.
+type RenameTagRequest struct {
+	Name string `json:"name" example:"api"`
+}
+
+// Defines the request payload for merging tags into one another.
+type MergeTagsRequest struct {
+	Sources []string `json:"sources" example:"back-end,server"`
+	Target  string   `json:"target" example:"backend"`
+}
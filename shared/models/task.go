@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/uptrace/bun"
@@ -16,8 +17,38 @@ type Task struct {
 	ID          string `bun:",pk,type:uuid,default:gen_random_uuid()"`
 	Title       string `bun:",notnull"`
 	Description string
+	Status      TaskStatus   `bun:",notnull,default:'todo'" swaggertype:"string" enums:"todo,in_progress,blocked,done,cancelled"`
+	Priority    TaskPriority `bun:",notnull,default:0" swaggertype:"string" enums:"none,low,medium,high,urgent"`
 	CreatedAt   time.Time    `bun:",default:current_timestamp"`
 	UpdatedAt   bun.NullTime `swaggertype:"string" format:"date-time"`
+	CompletedAt bun.NullTime `swaggertype:"string" format:"date-time"`
+	StartAt     bun.NullTime `swaggertype:"string" format:"date-time"`
+	DueAt       bun.NullTime `swaggertype:"string" format:"date-time"`
+
+	// bumped on every update, so a writer can tell whether the task changed
+	// since they read it; see ETag
+	Version int64 `bun:",notnull,default:1"`
+
+	// set while the task sits in the trash. bun leaves trashed tasks out
+	// of every query unless told otherwise, see WhereDeleted
+	DeletedAt bun.NullTime `bun:",soft_delete,nullzero" swaggertype:"string" format:"date-time"`
+
+	// the task this one is a subtask of, empty for top level tasks
+	ParentID string `bun:",type:uuid,nullzero"`
+	Parent   *Task  `bun:"rel:belongs-to,join:parent_id=id" swaggerignore:"true"`
+
+	// the user the task belongs to, only they get to see it
+	OwnerID string `bun:",type:uuid,nullzero"`
+	Owner   *User  `bun:"rel:belongs-to,join:owner_id=id" swaggerignore:"true"`
+
+	// the project the task is filed under, empty for tasks in no project
+	ProjectID string   `bun:",type:uuid,nullzero"`
+	Project   *Project `bun:"rel:belongs-to,join:project_id=id" swaggerignore:"true"`
+
+	Tags []*Tag `bun:"m2m:task_tags,join:Task=Tag" swaggerignore:"true"`
+
+	// only filled in when a whole subtree is loaded, see BuildTaskTree
+	Subtasks []*Task `bun:"-" swaggerignore:"true"`
 }
 
 // formats to pretty representation
@@ -25,31 +56,118 @@ func (t *Task) String() string {
 	return t.Title
 }
 
-// does the pretty formating of our timestamps
-func (t *Task) AsTime(timestamp string) time.Time {
-	tm, _ := time.Parse(time.RFC3339, timestamp)
-	return tm
+// The entity tag of the task's current version, quoted the way HTTP
+// expects, i.e. "3"
+func (t *Task) ETag() string {
+	return strconv.Quote(strconv.FormatInt(t.Version, 10))
 }
 
 func (t *Task) GetCreatedAt() time.Time {
-	return t.AsTime(t.CreatedAt.String())
+	return t.CreatedAt
 }
 
+// the zero time until the task is first updated
 func (t *Task) GetUpdatedAt() time.Time {
-	return t.AsTime(t.UpdatedAt.String())
+	return t.UpdatedAt.Time
+}
+
+// Moves the task into the next status of its lifecycle, keeping
+// CompletedAt in sync: it is stamped on completion and cleared when
+// the task leaves the done state again
+func (t *Task) TransitionTo(next TaskStatus, now time.Time) error {
+	current := t.Status
+	if current == "" {
+		current = StatusTodo
+	}
+
+	if err := current.ValidateTransition(next); err != nil {
+		return err
+	}
+
+	if next == StatusDone && current != StatusDone {
+		t.CompletedAt = bun.NullTime{Time: now}
+	} else if next != StatusDone {
+		t.CompletedAt = bun.NullTime{}
+	}
+
+	t.Status = next
+	return nil
+}
+
+// Assembles descendants, in any order, into the subtree under root by
+// filling in the Subtasks of every task. Siblings keep their relative order.
+func BuildTaskTree(root *Task, descendants []*Task) {
+	children := make(map[string][]*Task, len(descendants))
+	for _, task := range descendants {
+		children[task.ParentID] = append(children[task.ParentID], task)
+	}
+
+	var attach func(task *Task)
+	attach = func(task *Task) {
+		task.Subtasks = children[task.ID]
+		for _, subtask := range task.Subtasks {
+			attach(subtask)
+		}
+	}
+	attach(root)
 }
 
 // Defines the request payload for creating a task.
 type TaskRequest struct {
-	Title       string `json:"title" example:"Buy groceries"`
-	Description string `json:"description" example:"Milk, Bread, Eggs"`
+	Title       string   `json:"title" example:"Buy groceries"`
+	Description string   `json:"description" example:"Milk, Bread, Eggs"`
+	Status      string   `json:"status,omitempty" example:"todo" enums:"todo,in_progress,blocked,done,cancelled"`
+	Priority    string   `json:"priority,omitempty" example:"high" enums:"none,low,medium,high,urgent"`
+	StartAt     string   `json:"start_at,omitempty" example:"2025-03-20T09:00:00Z"`
+	DueAt       string   `json:"due_at,omitempty" example:"2025-03-21T17:00:00Z"`
+	Tags        []string `json:"tags,omitempty" example:"backend,urgent"`
+	ParentID    string   `json:"parent_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	ProjectID   string   `json:"project_id,omitempty" example:"5f0c2a9e-1b7d-4e36-8a51-0d9c3e7b4f12"`
 }
 
 // Defines the response payload for returning a task.
 type TaskResponse struct {
-	ID          string `json:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
-	Title       string `json:"title" example:"Buy groceries"`
-	Description string `json:"description" example:"Milk, Bread, Eggs"`
-	CreatedAt   string `json:"created_at" example:"2025-03-19T08:58:10.605Z"`
-	UpdatedAt   string `json:"updated_at" example:"2025-03-19T08:58:10.605Z"`
+	ID          string   `json:"id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	Title       string   `json:"title" example:"Buy groceries"`
+	Description string   `json:"description" example:"Milk, Bread, Eggs"`
+	Status      string   `json:"status" example:"todo" enums:"todo,in_progress,blocked,done,cancelled"`
+	Priority    string   `json:"priority" example:"high" enums:"none,low,medium,high,urgent"`
+	CreatedAt   string   `json:"created_at" example:"2025-03-19T08:58:10.605Z"`
+	UpdatedAt   string   `json:"updated_at,omitempty" example:"2025-03-19T09:12:31.207Z"`
+	CompletedAt string   `json:"completed_at,omitempty" example:"2025-03-20T17:02:44.120Z"`
+	StartAt     string   `json:"start_at,omitempty" example:"2025-03-20T09:00:00Z"`
+	DueAt       string   `json:"due_at,omitempty" example:"2025-03-21T17:00:00Z"`
+	Tags        []string `json:"tags" example:"backend,urgent"`
+	ParentID    string   `json:"parent_id,omitempty" example:"123e4567-e89b-12d3-a456-426614174000"`
+	ProjectID   string   `json:"project_id,omitempty" example:"5f0c2a9e-1b7d-4e36-8a51-0d9c3e7b4f12"`
+	// send it back in If-Match to only change the task if nobody else did in the meantime
+	ETag string `json:"etag" example:"\"3\""`
+	// only present on tasks in the trash
+	DeletedAt string `json:"deleted_at,omitempty" example:"2025-03-22T10:15:00Z"`
+	// only present when the whole subtree was requested
+	Subtasks []TaskResponse `json:"subtasks,omitempty"`
+}
+
+// Defines the response payload for a page of tasks.
+type TaskListResponse struct {
+	Tasks         []TaskResponse `json:"tasks"`
+	NextPageToken string         `json:"next_page_token,omitempty" example:"eyJzIjoiY3JlYXRlZF9hdCJ9"`
+	Next          string         `json:"next,omitempty" example:"/api/v1/tasks?page_size=20&page_token=eyJzIjoiY3JlYXRlZF9hdCJ9"`
+}
+
+// Defines a task found by a search, with its matches wrapped in <mark></mark>
+type TaskMatchResponse struct {
+	Task TaskResponse `json:"task"`
+	// higher is better, though only comparable within a single search
+	Rank           float64 `json:"rank" example:"0.6"`
+	TitleHighlight string  `json:"title_highlight" example:"Plan the <mark>sprint</mark>"`
+	// an excerpt of the description around its matches
+	Snippet string `json:"snippet" example:"Agree on the <mark>sprint</mark> goal with the team"`
+}
+
+// Defines the response payload for a page of search results, best match first.
+type TaskSearchResponse struct {
+	Results       []TaskMatchResponse `json:"results"`
+	NextPageToken string              `json:"next_page_token,omitempty" example:"eyJzIjoicmFuayBkZXNjIiwibyI6MjB9"`
+	Next          string              `json:"next,omitempty" example:"/api/v1/tasks/search?q=sprint&page_token=eyJzIjoicmFuayBkZXNjIiwibyI6MjB9"`
 }
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// What happened to a task. Moving a task to the trash counts as deleting
+// it and restoring it as creating it again, as that is how it looks to
+// anyone listing tasks.
+type TaskEventType string
+
+const (
+	TaskCreated TaskEventType = "created"
+	TaskUpdated TaskEventType = "updated"
+	TaskDeleted TaskEventType = "deleted"
+	// an update that took the task out of a watcher's filter. Never
+	// recorded, only sent to the watchers it concerns in place of the update
+	TaskLeft TaskEventType = "left"
+)
+
+// Records a change to a task, for watchers to pick up. Events are written
+// by triggers on the tasks table, so they are never missed whichever way a
+// task is changed, and ordered by the transaction that wrote them, then by
+// id.
+//
+// They outlive the tasks they are about, hence no foreign key on TaskID.
+type TaskEvent struct {
+	bun.BaseModel `bun:"table:task_events,alias:e" swaggerignore:"true"`
+
+	ID int64 `bun:",pk,autoincrement"`
+	// id of the transaction that wrote the event, always 0 on SQLite
+	TxID    int64         `bun:"txid,notnull"`
+	TaskID  string        `bun:",notnull,type:uuid"`
+	OwnerID string        `bun:",type:uuid,nullzero"`
+	Type    TaskEventType `bun:",notnull"`
+	// when the change was written
+	CreatedAt time.Time `bun:",notnull,default:current_timestamp"`
+
+	// the task right after and right before the change, noted down by the
+	// triggers along with its tag names. The first is null for tasks
+	// deleted without going through the trash, the second for new ones.
+	TaskSnapshot     json.RawMessage `bun:"task"`
+	PreviousSnapshot json.RawMessage `bun:"previous"`
+
+	// the snapshots read back, for watchers to filter on what the change
+	// did rather than on what became of the task since
+	Task     *Task `bun:"-"`
+	Previous *Task `bun:"-"`
+}
+
+// Defines the payload of an event sent to watchers of /tasks/events.
+type TaskEventResponse struct {
+	// send it back in Last-Event-ID to resume after this event
+	ID     string `json:"id" example:"42"`
+	Type   string `json:"type" example:"updated" enums:"created,updated,deleted,left"`
+	TaskID string `json:"task_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+	// the task as it was right after the change, absent for tasks deleted
+	// without going through the trash
+	Task       *TaskResponse `json:"task,omitempty"`
+	OccurredAt string        `json:"occurred_at" example:"2025-03-19T09:12:31.207Z"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Shortest password we accept when registering
+const MinPasswordLength = 8
+
+// bcrypt only looks at the first 72 bytes, anything longer is rejected
+const MaxPasswordLength = 72
+
+// Returned when a user registers with a malformed email or a weak password
+var ErrInvalidUser = errors.New("invalid user")
+
+// Represents someone who signs in to the tracker and owns tasks
+type User struct {
+	bun.BaseModel `bun:"table:users,alias:u" swaggerignore:"true"`
+
+	ID           string    `bun:",pk,type:uuid,default:gen_random_uuid()"`
+	Email        string    `bun:",notnull,unique"`
+	PasswordHash string    `bun:",notnull"`
+	CreatedAt    time.Time `bun:",default:current_timestamp"`
+}
+
+// Lowercases and trims an email address, checking it is well formed
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", fmt.Errorf("%w: %q is not a valid email address", ErrInvalidUser, email)
+	}
+	return email, nil
+}
+
+// Hashes the password with bcrypt and stores the hash on the user
+func (u *User) SetPassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("%w: password must be at least %d characters long", ErrInvalidUser, MinPasswordLength)
+	}
+	if len(password) > MaxPasswordLength {
+		return fmt.Errorf("%w: password must be at most %d bytes long", ErrInvalidUser, MaxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	u.PasswordHash = string(hash)
+	return nil
+}
+
+// Reports whether password matches the one the user registered with
+func (u *User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// Defines the payload for registering and logging in.
+type CredentialsRequest struct {
+	Email    string `json:"email" example:"ada@example.com"`
+	Password string `json:"password" example:"correct horse battery staple"`
+}
+
+// Defines the response payload for returning a user.
+type UserResponse struct {
+	ID        string `json:"id" example:"0b6f7c1e-3a55-4d8e-9a3f-6c2d1e4b5a70"`
+	Email     string `json:"email" example:"ada@example.com"`
+	CreatedAt string `json:"created_at" example:"2025-03-19T08:58:10.605Z"`
+}
+
+// Defines the response payload of a successful login.
+type TokenResponse struct {
+	AccessToken string       `json:"access_token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	TokenType   string       `json:"token_type" example:"Bearer"`
+	ExpiresAt   string       `json:"expires_at" example:"2025-03-20T08:58:10Z"`
+	User        UserResponse `json:"user"`
+}
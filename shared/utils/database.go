@@ -8,9 +8,32 @@ import (
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
 	_ "github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/driver/sqliteshim"
 )
 
+// where the SQLite database lives unless DB_PATH says otherwise
+const DefaultSQLitePath = "notes-tracker.db"
+
+// Connects to the database DB_DRIVER names; Postgres, configured through
+// the POSTGRES_* variables, unless it is set to sqlite, in which case the
+// database is kept in the file at DB_PATH.
+func ConnectFromEnv() (*bun.DB, error) {
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "", "postgres":
+		return ConnectToDB(BuildDatabaseURL())
+	case "sqlite":
+		path := os.Getenv("DB_PATH")
+		if path == "" {
+			path = DefaultSQLitePath
+		}
+		return ConnectToSQLite(path)
+	default:
+		return nil, fmt.Errorf("[DB] Unknown DB_DRIVER %q, expected postgres or sqlite", driver)
+	}
+}
+
 // Constructs a databse connection string from environment variables.
 func BuildDatabaseURL() string {
 	user := os.Getenv("POSTGRES_USER")
@@ -42,3 +65,27 @@ func ConnectToDB(dbUrl string) (*bun.DB, error) {
 	log.Println("[DB] Connected to database successfully")
 	return bun.NewDB(db, pgdialect.New()), nil
 }
+
+// Opens, creating it if needed, the SQLite database at path
+func ConnectToSQLite(path string) (*bun.DB, error) {
+	log.Println("[DB] Opening SQLite database at", path)
+
+	// SQLite leaves foreign keys unchecked unless asked, and we would rather
+	// wait on a busy database for a bit than fail straight away
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+	db, err := sql.Open(sqliteshim.ShimName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("[DB] Error opening database: %v", err)
+	}
+
+	// a single writer is all SQLite allows anyway, and sharing one
+	// connection keeps transactions from tripping over each other
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		return nil, fmt.Errorf("[DB] Failed to open database: %v", err)
+	}
+
+	log.Println("[DB] Opened database successfully")
+	return bun.NewDB(db, sqlitedialect.New()), nil
+}